@@ -0,0 +1,67 @@
+package main
+
+import "github.com/charmbracelet/bubbles/list"
+
+// includedTracks returns the album tracks left switched on, in the edited order
+func (m model) includedTracks() []songItem {
+	var tracks []songItem
+	for _, t := range m.albumTracks {
+		if !m.albumExcluded[t.id] {
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
+}
+
+// selectedAlbumTrack returns the index in albumTracks of the highlighted track,
+// -1 for the album header
+func (m model) selectedAlbumTrack() int {
+	item, ok := m.albumTrackList.SelectedItem().(songItem)
+	if !ok || item.isAlbum {
+		return -1
+	}
+	for i, t := range m.albumTracks {
+		if t.id == item.id {
+			return i
+		}
+	}
+	return -1
+}
+
+// toggleAlbumTrack switches the highlighted track on or off for the album download
+func (m *model) toggleAlbumTrack() {
+	i := m.selectedAlbumTrack()
+	if i < 0 {
+		return
+	}
+	if m.albumExcluded == nil {
+		m.albumExcluded = map[string]bool{}
+	}
+	id := m.albumTracks[i].id
+	m.albumExcluded[id] = !m.albumExcluded[id]
+	m.refreshAlbumTracks()
+}
+
+// moveAlbumTrack moves the highlighted track up (-1) or down (+1) the tracklist
+func (m *model) moveAlbumTrack(delta int) {
+	// Positions in a filtered list don't map onto the tracklist
+	if m.albumTrackList.FilterState() != list.Unfiltered {
+		return
+	}
+	i := m.selectedAlbumTrack()
+	j := i + delta
+	if i < 0 || j < 0 || j >= len(m.albumTracks) {
+		return
+	}
+	tracks := append([]songItem{}, m.albumTracks...)
+	tracks[i], tracks[j] = tracks[j], tracks[i]
+	m.setAlbumTracks(tracks)
+	m.albumTrackList.Select(j + 1) // +1 for the album header
+}
+
+// refreshAlbumTracks redraws the tracklist, keeping the cursor in place
+func (m *model) refreshAlbumTracks() {
+	index := m.albumTrackList.Index()
+	m.setAlbumTracks(m.albumTracks)
+	m.albumTrackList.Select(index)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kkdai/youtube/v2"
+)
+
+// albumGap is a track of the album's tracklist without a file in its folder
+type albumGap struct {
+	index  int // Position in the tracklist, which numbers the file
+	track  songItem
+	status string // Empty until a fill is tried
+	filled bool
+}
+
+// gapFilledMsg reports a fill of the gap at index
+type gapFilledMsg struct {
+	index int
+	title string // The track it was filled with
+	err   error
+}
+
+// findAlbumGaps returns the tracks of the album without a file of any audio
+// format where the download puts them: in the album folder a file numbered
+// like the track, with download.template the path the template gives it
+func (m *model) findAlbumGaps(albumDir, albumName string) []albumGap {
+	ext := m.albumOptions().container().ext
+	listed := map[string][]string{} // Audio files by folder
+	var gaps []albumGap
+	for i, track := range m.downloadTracks {
+		path := m.albumTrackPath(albumDir, albumName, i, track.title, track.author, ext)
+		dir := filepath.Dir(path)
+		if _, ok := listed[dir]; !ok {
+			entries, _ := os.ReadDir(dir)
+			listed[dir] = []string{}
+			for _, e := range entries {
+				if !e.IsDir() && isAudioFile(e.Name()) {
+					listed[dir] = append(listed[dir], e.Name())
+				}
+			}
+		}
+		// Numbered files are named after the video title, which may differ from the track's
+		prefix := fmt.Sprintf("%02d - ", i+1)
+		stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		found := slices.ContainsFunc(listed[dir], func(name string) bool {
+			if cfg.Download.Template == "" {
+				return strings.HasPrefix(name, prefix)
+			}
+			return strings.TrimSuffix(name, filepath.Ext(name)) == stem
+		})
+		if !found {
+			gaps = append(gaps, albumGap{index: i, track: track})
+		}
+	}
+	return gaps
+}
+
+// describeGaps lists the gaps for the album summary
+func describeGaps(gaps []albumGap, total int) string {
+	lines := make([]string, len(gaps))
+	for i, gap := range gaps {
+		lines[i] = fmt.Sprintf("%02d - %s", gap.index+1, gap.track.title)
+	}
+	return fmt.Sprintf("\n  %s %d of %d tracks have no file:\n    %s",
+		errorStyle.Render("Missing:"), len(gaps), total, strings.Join(lines, "\n    "))
+}
+
+// fillGap searches for the track of the gap at i and downloads the best
+// match into the album under its number
+func (m *model) fillGap(i int) {
+	gap := m.albumGaps[i]
+	ctx := downloadJob.start()
+	go func() {
+		title, err := m.runFillGap(ctx, gap)
+		if ctx.Err() == nil {
+			m.program.Send(gapFilledMsg{index: i, title: title, err: err})
+		}
+	}()
+	m.albumGaps[i].status = "Searching..."
+}
+
+func (m *model) runFillGap(ctx context.Context, gap albumGap) (string, error) {
+	query := primaryArtist(gap.track.author) + " - " + cleanString(gap.track.title)
+	items, err := searchTracksCLI(query)
+	if err != nil {
+		return "", err
+	}
+	matches := rankTrackMatches(query, items)
+	if len(matches) == 0 || matches[0].score < minMatchScore {
+		return "", fmt.Errorf("no confident match for %q", query)
+	}
+	match := matches[0].item
+
+	albumName := albumDirName(m.currentAlbum)
+	albumDir := m.albumDir(albumName)
+	var albumThumb string
+	if m.currentAlbum.thumb != "" {
+		if albumThumb, err = m.cachedArt(ctx, m.currentAlbum.id, m.currentAlbum.thumb); err != nil {
+			return "", err
+		}
+	}
+	albumMeta, albumThumb := m.albumMetadata(ctx, albumThumb)
+	if err := m.downloadAlbumTrack(ctx, youtube.Client{}, gap.index, match, albumDir, albumName, albumThumb, albumMeta); err != nil {
+		return "", err
+	}
+	recordDownload(match.id)
+	return match.title, nil
+}
+
+// applyGapFilled shows how filling a gap went
+func (m *model) applyGapFilled(msg gapFilledMsg) {
+	if msg.index >= len(m.albumGaps) {
+		return
+	}
+	gap := &m.albumGaps[msg.index]
+	if msg.err != nil {
+		gap.status = "Failed: " + msg.err.Error()
+		return
+	}
+	gap.filled = true
+	gap.status = "Filled with " + msg.title
+}
+
+// filling reports whether a gap is being filled
+func (m model) filling() bool {
+	for _, gap := range m.albumGaps {
+		if gap.status == "Searching..." {
+			return true
+		}
+	}
+	return false
+}
+
+func (m model) updateFillGaps(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		m.gapCursor = (m.gapCursor - 1 + len(m.albumGaps)) % len(m.albumGaps)
+	case "down", "j":
+		m.gapCursor = (m.gapCursor + 1) % len(m.albumGaps)
+	case "enter":
+		if !m.filling() && !m.albumGaps[m.gapCursor].filled && m.requireNetwork("filling missing tracks") {
+			m.fillGap(m.gapCursor)
+			return m, m.spinner.Tick
+		}
+	case "esc", "q":
+		downloadJob.stop()
+		for i := range m.albumGaps {
+			if m.albumGaps[i].status == "Searching..." {
+				m.albumGaps[i].status = ""
+			}
+		}
+		m.state = stateFinished
+	}
+	return m, nil
+}
+
+func (m model) viewFillGaps() string {
+	rows := make([]string, len(m.albumGaps))
+	for i, gap := range m.albumGaps {
+		row := fmt.Sprintf("%02d - %s", gap.index+1, gap.track.title)
+		switch {
+		case gap.status == "Searching...":
+			row += "  " + m.spinner.View() + " " + m.progress.View()
+		case gap.filled:
+			row = "✓ " + row + helpStyle.Render("  "+gap.status)
+		case gap.status != "":
+			row += "  " + errorStyle.Render(gap.status)
+		}
+		if i == m.gapCursor {
+			rows[i] = "> " + statusStyle.Render(row)
+		} else {
+			rows[i] = "  " + row
+		}
+	}
+	return fmt.Sprintf("\n  %s\n  %s\n\n  %s\n\n  %s%s\n",
+		titleStyle.Render("Fill Missing Tracks"),
+		helpStyle.Render(strings.TrimPrefix(m.currentAlbum.title, "📀 ")+" - "+m.currentAlbum.author),
+		strings.Join(rows, "\n  "),
+		helpStyle.Render("↑/↓: Select  •  ENTER: Search & download into the album  •  ESC: Back"),
+		m.renderNotice(),
+	)
+}
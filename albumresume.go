@@ -0,0 +1,122 @@
+package main
+
+import "slices"
+
+// albumDownloadKey is the state entry of the album download being run
+const albumDownloadKey = "album_download"
+
+// savedTrack is the part of a songItem needed to download or play it again
+type savedTrack struct {
+	ID        string   `json:"id"`
+	Title     string   `json:"title"`
+	Author    string   `json:"author"`
+	Album     string   `json:"album,omitempty"`
+	Year      string   `json:"year,omitempty"`
+	Category  string   `json:"category,omitempty"`
+	Thumb     string   `json:"thumb,omitempty"`
+	Duration  int      `json:"duration,omitempty"`
+	Path      string   `json:"path,omitempty"` // Local file, for library tracks
+	ArtistIDs []string `json:"artist_ids,omitempty"`
+	AlbumID   string   `json:"album_id,omitempty"`
+}
+
+func newSavedTrack(i songItem) savedTrack {
+	return savedTrack{
+		ID:        i.id,
+		Title:     i.title,
+		Author:    i.author,
+		Album:     i.album,
+		Year:      i.year,
+		Category:  i.category,
+		Thumb:     i.thumb,
+		Duration:  i.duration,
+		Path:      i.path,
+		ArtistIDs: i.artistIDs,
+		AlbumID:   i.albumID,
+	}
+}
+
+func (t savedTrack) item() songItem {
+	return songItem{
+		id:        t.ID,
+		title:     t.Title,
+		author:    t.Author,
+		album:     t.Album,
+		year:      t.Year,
+		category:  t.Category,
+		thumb:     t.Thumb,
+		duration:  t.Duration,
+		path:      t.Path,
+		artistIDs: t.ArtistIDs,
+		albumID:   t.AlbumID,
+	}
+}
+
+// albumDownload records an album download in progress. It is rewritten after
+// every track and removed once the album finishes, so a record left behind
+// means gomusic crashed or was killed mid-album.
+type albumDownload struct {
+	Album     savedTrack   `json:"album"`
+	Tracks    []savedTrack `json:"tracks"`
+	Completed []string     `json:"completed"` // IDs of tracks already saved
+}
+
+func newAlbumDownload(album songItem, tracks []songItem) *albumDownload {
+	d := &albumDownload{Album: newSavedTrack(album), Completed: []string{}}
+	for _, t := range tracks {
+		d.Tracks = append(d.Tracks, newSavedTrack(t))
+	}
+	return d
+}
+
+// loadAlbumDownload returns the interrupted album download, nil if there is none
+func loadAlbumDownload() *albumDownload {
+	var d albumDownload
+	if err := loadState(albumDownloadKey, &d); err != nil || len(d.Tracks) == 0 {
+		return nil
+	}
+	return &d
+}
+
+// clone copies the record, so a download can update it while the UI still
+// shows the original
+func (d *albumDownload) clone() *albumDownload {
+	c := *d
+	c.Tracks = slices.Clone(d.Tracks)
+	c.Completed = slices.Clone(d.Completed)
+	return &c
+}
+
+// done reports whether the track was saved before the interruption
+func (d *albumDownload) done(id string) bool {
+	for _, c := range d.Completed {
+		if c == id {
+			return true
+		}
+	}
+	return false
+}
+
+// firstMissing returns the index of the first track still to download
+func (d *albumDownload) firstMissing() int {
+	for i, t := range d.Tracks {
+		if !d.done(t.ID) {
+			return i
+		}
+	}
+	return len(d.Tracks)
+}
+
+// complete marks a track as saved and persists the progress
+func (d *albumDownload) complete(id string) error {
+	d.Completed = append(d.Completed, id)
+	return saveState(albumDownloadKey, d)
+}
+
+func (d *albumDownload) tracks() []songItem {
+	var items []songItem
+	for _, t := range d.Tracks {
+		items = append(items, t.item())
+	}
+	return items
+}
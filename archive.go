@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Logs of earlier versions, now buckets of the state store; see importStateLogs
+const (
+	legacyArchiveFile = "download_archive.txt"
+	legacyHistoryFile = "history.jsonl"
+)
+
+// loadArchive returns the IDs of tracks downloaded before
+func loadArchive() map[string]bool {
+	ids := map[string]bool{}
+	withState(false, func(tx *bolt.Tx) error {
+		return tx.Bucket(archiveBucket).ForEach(func(id, _ []byte) error {
+			ids[string(id)] = true
+			return nil
+		})
+	})
+	return ids
+}
+
+// recordDownload adds a finished track to the archive
+func recordDownload(id string) {
+	err := withState(true, func(tx *bolt.Tx) error {
+		return archiveIDs(tx, []string{id}, time.Now())
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating download archive: %v\n", err)
+		return
+	}
+	markOwned(id)
+}
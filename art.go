@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	defaultArtRows = 20 // Cover height before the terminal size is known
+	maxArtRows     = 40
+	minArtRows     = 5  // Below this the cover is left out
+	cellPixels     = 16 // Rough cell height in pixels, for scaling the terminal image copy
+)
+
+// artConfig chooses how the album cover is shown while playing
+type artConfig struct {
+	Mode string `toml:"mode,omitempty"` // "auto" (terminal images where supported, else ASCII art; default), "ascii" or "off"
+}
+
+// artMode is the art mode in effect; low-bandwidth connections show no art
+func artMode() string {
+	if lowBandwidth() {
+		return "off"
+	}
+	return cfg.Art.Mode
+}
+
+func (c artConfig) validate() error {
+	switch c.Mode {
+	case "", "auto", "ascii", "off":
+		return nil
+	}
+	return fmt.Errorf("art: mode must be auto, ascii or off, got %q", c.Mode)
+}
+
+// resizeSettle is how long the terminal size must hold before the cover is redrawn
+const resizeSettle = 150 * time.Millisecond
+
+// resizeSettledMsg fires resizeSettle after the terminal reported this size
+type resizeSettledMsg struct {
+	width, height int
+}
+
+// artRenderedMsg carries the ASCII art of the cover of the track with key,
+// drawn cols wide and rows high
+type artRenderedMsg struct {
+	key        string
+	cols, rows int
+	ascii      string
+}
+
+// artSize fits the cover into the playing screen: up to two fifths of the
+// width next to the lyrics and the full height minus its border and caption.
+// Terminal cells are about twice as tall as wide, so a square cover is drawn
+// twice as many columns wide as it is rows high. It returns 0, 0 when the
+// terminal is too small for a cover.
+func artSize(width, height int) (cols, rows int) {
+	if width == 0 || height == 0 {
+		return 2 * defaultArtRows, defaultArtRows
+	}
+	// Border and padding take 4 columns; border and caption 3 rows, plus a spare line
+	maxCols := width*2/5 - 4
+	rows = min(min(height-4, maxCols/2), maxArtRows)
+	if rows < minArtRows {
+		return 0, 0
+	}
+	return 2 * rows, rows
+}
+
+// coverImageMsg carries the copy of the cover scaled for terminal image
+// display at a cover size of cols x rows
+type coverImageMsg struct {
+	key        string
+	cols, rows int
+	path       string
+}
+
+// renderArt converts the cover at coverPath to colorized ASCII art off the UI
+// goroutine, reusing art drawn earlier at the same size
+func renderArt(key, coverPath string, cols, rows int) tea.Cmd {
+	return func() tea.Msg {
+		return artRenderedMsg{key: key, cols: cols, rows: rows, ascii: cachedASCIIArt(coverPath, cols, rows)}
+	}
+}
+
+// scaleCoverImage scales the cover at coverPath to fill cols x rows cells on
+// terminals that can display images
+func scaleCoverImage(key, coverPath string, cols, rows int) tea.Cmd {
+	if artMode() == "ascii" || !isImageCapableTerminal() {
+		return nil
+	}
+	return func() tea.Msg {
+		size := rows * cellPixels
+		path, err := cachedResizedArt(key, coverPath, size, size)
+		if err != nil {
+			return nil
+		}
+		return coverImageMsg{key: key, cols: cols, rows: rows, path: path}
+	}
+}
+
+// refreshArt redraws the cover when the terminal size calls for a different one
+func (m *model) refreshArt() tea.Cmd {
+	if m.playback.coverPath == "" || artMode() == "off" {
+		return nil
+	}
+	cols, rows := artSize(m.width, m.height)
+	if cols == m.playback.artCols && rows == m.playback.artRows {
+		return nil
+	}
+	m.playback.artCols, m.playback.artRows = cols, rows
+	if rows == 0 {
+		m.playback.albumCover = ""
+		m.playback.resizedCoverPath = ""
+		m.playback.kittyImage = ""
+		return nil
+	}
+	key, coverPath := m.playback.trackKey, m.playback.coverPath
+	return tea.Batch(renderArt(key, coverPath, cols, rows), scaleCoverImage(key, coverPath, cols, rows))
+}
+
+// resized drops a cover that no longer fits at once, since it would be clipped,
+// and redraws it once the terminal has stopped resizing
+func (m *model) resized() tea.Cmd {
+	if _, rows := artSize(m.width, m.height); rows < m.playback.artRows {
+		m.playback.albumCover = ""
+		m.playback.artCols, m.playback.artRows = 0, 0
+	}
+	width, height := m.width, m.height
+	return tea.Tick(resizeSettle, func(time.Time) tea.Msg {
+		return resizeSettledMsg{width: width, height: height}
+	})
+}
+
+// applyArt shows freshly rendered art if it still fits the current track and size
+func (m *model) applyArt(msg artRenderedMsg) {
+	if msg.key != m.playback.trackKey || msg.cols != m.playback.artCols || msg.rows != m.playback.artRows {
+		return
+	}
+	m.playback.albumCover = msg.ascii
+}
+
+// applyCoverImage swaps in a terminal image copy that matches the current cover size
+func (m *model) applyCoverImage(msg coverImageMsg) {
+	if msg.key != m.playback.trackKey || msg.cols != m.playback.artCols || msg.rows != m.playback.artRows {
+		return
+	}
+	m.playback.resizedCoverPath = msg.path
+	// Only hand the image to the view once the playing screen is up
+	m.playback.kittyImage = "ready"
+	if m.state == statePlaying {
+		m.playback.kittyImage = msg.path
+	}
+}
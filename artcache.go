@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxCachedArt caps the art cache; the least recently used covers go first
+const maxCachedArt = 1000
+
+// artPath returns where the cover for key (a video or album ID) is cached
+func artPath(key, suffix string) string {
+	dir, err := cacheDir("art")
+	if err != nil {
+		// Fall back to the working directory like the old temp files
+		return "temp_cover_" + filepath.Base(key) + suffix + ".jpg"
+	}
+	return filepath.Join(dir, filepath.Base(key)+suffix+".jpg")
+}
+
+// cachedArt returns the cached cover for key, downloading it from url on
+// first use. The path is returned even on error so callers can hand it on.
+func (m *model) cachedArt(ctx context.Context, key, url string) (string, error) {
+	return fillArtCache(artPath(key, ""), func(tmp string) error {
+		return m.downloadThumb(ctx, url, tmp)
+	})
+}
+
+// cachedEmbeddedArt returns the cached copy of the art embedded in a local file
+func cachedEmbeddedArt(key, path string) (string, error) {
+	return fillArtCache(artPath(key, ""), func(tmp string) error {
+		return extractEmbeddedArt(path, tmp)
+	})
+}
+
+// cachedResizedArt returns a copy of the cover scaled down for terminal image
+// display, kept per size so resizing back and forth reuses earlier copies
+func cachedResizedArt(key, coverPath string, maxWidth, maxHeight int) (string, error) {
+	return fillArtCache(artPath(key, fmt.Sprintf("_%dx%d", maxWidth, maxHeight)), func(tmp string) error {
+		return resizeImage(coverPath, tmp, maxWidth, maxHeight)
+	})
+}
+
+// maxCachedASCII caps the ASCII art kept in memory; a few sizes of the last
+// covers cover replays and resizing back and forth
+const maxCachedASCII = 32
+
+// asciiKey identifies ASCII art by the cover file it was drawn from and its size
+type asciiKey struct {
+	path       string
+	modified   time.Time
+	cols, rows int
+}
+
+// asciiCache keeps recently drawn ASCII art, most recently used last
+var asciiCache struct {
+	sync.Mutex
+	keys []asciiKey
+	art  map[asciiKey]string
+}
+
+// cachedASCIIArt returns the cover at coverPath as ASCII art cols x rows,
+// drawing it only the first time that cover is shown at that size
+func cachedASCIIArt(coverPath string, cols, rows int) string {
+	info, err := os.Stat(coverPath)
+	if err != nil {
+		return ""
+	}
+	key := asciiKey{coverPath, info.ModTime(), cols, rows}
+
+	asciiCache.Lock()
+	art, ok := asciiCache.art[key]
+	if ok {
+		asciiCache.keys = append(slices.DeleteFunc(asciiCache.keys, func(k asciiKey) bool { return k == key }), key)
+	}
+	asciiCache.Unlock()
+	if ok {
+		return art
+	}
+
+	art = convertImageToASCII(coverPath, cols, rows)
+	if art == "" {
+		return ""
+	}
+	asciiCache.Lock()
+	defer asciiCache.Unlock()
+	if asciiCache.art == nil {
+		asciiCache.art = map[asciiKey]string{}
+	}
+	if _, ok := asciiCache.art[key]; !ok {
+		asciiCache.keys = append(asciiCache.keys, key)
+	}
+	asciiCache.art[key] = art
+	for len(asciiCache.keys) > maxCachedASCII {
+		delete(asciiCache.art, asciiCache.keys[0])
+		asciiCache.keys = asciiCache.keys[1:]
+	}
+	return art
+}
+
+// fillArtCache returns path if it is cached, else creates it with fill. fill
+// writes to a temporary name that is renamed into place, so an interrupted
+// download never leaves a broken cover behind.
+func fillArtCache(path string, fill func(tmp string) error) (string, error) {
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		os.Chtimes(path, now, now) // Mark as recently used
+		return path, nil
+	}
+	tmp := path + ".part.jpg"
+	if err := fill(tmp); err != nil {
+		os.Remove(tmp)
+		return path, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return path, err
+	}
+	pruneArtCache(filepath.Dir(path))
+	return path, nil
+}
+
+// pruneArtCache removes the least recently used covers beyond maxCachedArt
+func pruneArtCache(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) <= maxCachedArt {
+		return
+	}
+	type cached struct {
+		path string
+		used int64
+	}
+	var files []cached
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() {
+			continue
+		}
+		files = append(files, cached{filepath.Join(dir, e.Name()), info.ModTime().UnixNano()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].used < files[j].used })
+	for _, f := range files[:max(0, len(files)-maxCachedArt)] {
+		os.Remove(f.path)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/raitonoberu/ytmusic"
+)
+
+// Tags album downloads carry so same-named artists stay apart in the library
+const (
+	artistIDTag = "ytmusic_artist_id"
+	albumIDTag  = "ytmusic_album_id"
+)
+
+// getArtistIDs returns the YT Music channel IDs of artists, skipping unknown ones
+func getArtistIDs(artists []ytmusic.Artist) []string {
+	var ids []string
+	for _, a := range artists {
+		if a.ID != "" {
+			ids = append(ids, a.ID)
+		}
+	}
+	return ids
+}
+
+// primaryArtistID returns the first known artist ID of item
+func (i songItem) primaryArtistID() string {
+	if len(i.artistIDs) == 0 {
+		return ""
+	}
+	return i.artistIDs[0]
+}
+
+// sameArtist reports whether artists include the album's artist. Artist IDs
+// decide when both sides have them; the lower-cased name is only a fallback.
+func sameArtist(album songItem, artists []ytmusic.Artist, name string) bool {
+	if ids := getArtistIDs(artists); len(album.artistIDs) > 0 && len(ids) > 0 {
+		for _, a := range album.artistIDs {
+			for _, id := range ids {
+				if a == id {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return strings.Contains(strings.ToLower(strings.Join(getArtistNames(artists), ", ")), name)
+}
+
+// artistMatches reports whether track is by the album's artist
+func artistMatches(album songItem, track *ytmusic.TrackItem) bool {
+	artist := strings.ToLower(primaryArtist(album.author))
+	if sameArtist(album, track.Artists, artist) {
+		return true
+	}
+	// Without IDs, also accept a track credited to part of the album's name
+	names := strings.ToLower(strings.Join(getArtistNames(track.Artists), " "))
+	return len(album.artistIDs) == 0 && names != "" && strings.Contains(artist, names)
+}
+
+// albumMatches reports whether track belongs to album, by browse ID when the
+// search result carries one and by name otherwise
+func albumMatches(album songItem, title string, track *ytmusic.TrackItem) bool {
+	if strings.HasPrefix(album.id, "MPRE") && track.Album.ID != "" {
+		return track.Album.ID == album.id
+	}
+	want := strings.ToLower(title)
+	got := strings.ToLower(track.Album.Name)
+	return strings.Contains(got, want) || strings.Contains(want, got)
+}
+
+// idTagArgs returns the ffmpeg options that store item's artist and album IDs
+func idTagArgs(artistID, albumID string) []string {
+	var args []string
+	if artistID != "" {
+		args = append(args, "-metadata", artistIDTag+"="+artistID)
+	}
+	if strings.HasPrefix(albumID, "MPRE") {
+		args = append(args, "-metadata", albumIDTag+"="+albumID)
+	}
+	return args
+}
+
+// folderArtistID returns the artist ID tagged on the first track in dir
+func folderArtistID(dir string) (artist, artistID string) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return "", ""
+	}
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && isAudioFile(f.Name()) {
+			names = append(names, f.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", ""
+	}
+	sort.Strings(names)
+	tags, _, err := probeTags(filepath.Join(dir, names[0]))
+	if err != nil {
+		return "", ""
+	}
+	artist = tags["album_artist"]
+	if artist == "" {
+		artist = tags["artist"]
+	}
+	return artist, tags[artistIDTag]
+}
+
+// albumFolder picks the folder under root for an album download. When a
+// folder of the same name already holds another artist's album, the artist
+// is added to the name instead of mixing the two.
+func albumFolder(root, albumName string, album songItem) string {
+	dir := safeJoin(root, albumName)
+	id := album.primaryArtistID()
+	if id == "" {
+		return dir
+	}
+	if _, existing := folderArtistID(dir); existing != "" && existing != id {
+		return safeJoin(root, fmt.Sprintf("%s (%s)", albumName, primaryArtist(album.author)))
+	}
+	return dir
+}
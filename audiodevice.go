@@ -0,0 +1,36 @@
+package main
+
+import "fmt"
+
+// audioDeviceErr is why the sound card couldn't be opened at startup, e.g. on
+// a headless server or without ALSA. While it is set gomusic runs
+// download-only: searching and downloading work, playing leaves a notice.
+var audioDeviceErr error
+
+// noAudioHint tells how to play without a sound card
+const noAudioHint = `set output.mode = "pipe" to play into a FIFO or stdout, or playback.backend = "mpv" (Ctrl+T: Settings, then restart)`
+
+// errNoAudio explains why nothing can be played
+func errNoAudio() error {
+	return fmt.Errorf("no audio device (%v); %s", audioDeviceErr, noAudioHint)
+}
+
+// requireAudio reports whether playback may start. Without a sound card it
+// leaves a notice explaining why the key did nothing.
+func (m *model) requireAudio() bool {
+	if audioDeviceErr == nil {
+		return true
+	}
+	m.notice = "Download-only: " + errNoAudio().Error()
+	return false
+}
+
+// audioBanner marks the search screen while running download-only
+func audioBanner() string {
+	if audioDeviceErr == nil {
+		return ""
+	}
+	return "\n\n  " + errorStyle.Render("Download-only mode: the audio device could not be opened") +
+		"\n  " + helpStyle.Render(fmt.Sprintf("%v", audioDeviceErr)) +
+		"\n  " + helpStyle.Render("To play anyway, "+noAudioHint)
+}
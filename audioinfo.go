@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// audioInfo describes the source audio of what is playing
+type audioInfo struct {
+	codec      string
+	bitrate    int // Bits per second, 0 if unknown
+	sampleRate int // Hz, 0 if unknown
+	channels   string
+}
+
+// String formats the info as e.g. "opus • 160 kbps • 48.0 kHz • stereo"
+func (a audioInfo) String() string {
+	var parts []string
+	if a.codec != "" {
+		parts = append(parts, a.codec)
+	}
+	if a.bitrate > 0 {
+		parts = append(parts, fmt.Sprintf("%d kbps", a.bitrate/1000))
+	}
+	if a.sampleRate > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f kHz", float64(a.sampleRate)/1000))
+	}
+	if a.channels != "" {
+		parts = append(parts, a.channels)
+	}
+	return strings.Join(parts, " • ")
+}
+
+// channelLayout names a channel count the way ffprobe does
+func channelLayout(n int) string {
+	switch n {
+	case 0:
+		return ""
+	case 1:
+		return "mono"
+	case 2:
+		return "stereo"
+	}
+	return fmt.Sprintf("%d channels", n)
+}
+
+// probeAudioInfo reads codec, bitrate, sample rate and channel layout of the
+// first audio stream of a local file
+func probeAudioInfo(path string) (audioInfo, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=codec_name,bit_rate,sample_rate,channels,channel_layout:format=bit_rate",
+		"-of", "json",
+		path,
+	).Output()
+	if err != nil {
+		return audioInfo{}, err
+	}
+	var probe struct {
+		Streams []struct {
+			CodecName     string `json:"codec_name"`
+			BitRate       string `json:"bit_rate"`
+			SampleRate    string `json:"sample_rate"`
+			Channels      int    `json:"channels"`
+			ChannelLayout string `json:"channel_layout"`
+		} `json:"streams"`
+		Format struct {
+			BitRate string `json:"bit_rate"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return audioInfo{}, err
+	}
+	if len(probe.Streams) == 0 {
+		return audioInfo{}, fmt.Errorf("no audio stream in %s", path)
+	}
+	s := probe.Streams[0]
+	info := audioInfo{codec: s.CodecName, channels: s.ChannelLayout}
+	if info.channels == "" {
+		info.channels = channelLayout(s.Channels)
+	}
+	info.sampleRate, _ = strconv.Atoi(s.SampleRate)
+	info.bitrate, _ = strconv.Atoi(s.BitRate)
+	if info.bitrate == 0 {
+		// Some containers only report the overall bitrate
+		info.bitrate, _ = strconv.Atoi(probe.Format.BitRate)
+	}
+	return info, nil
+}
+
+// lossless reports whether the codec keeps the original samples, so the
+// file can be played bit-perfect
+func (a audioInfo) lossless() bool {
+	switch a.codec {
+	case "flac", "alac", "wavpack", "ape", "tta", "mlp", "truehd":
+		return true
+	}
+	return strings.HasPrefix(a.codec, "pcm_")
+}
+
+// mimeCodecs extracts the codec list from a MIME type like `audio/webm; codecs="opus"`
+var mimeCodecs = regexp.MustCompile(`codecs="([^"]+)"`)
+
+// formatAudioInfo describes a remote YouTube format without downloading it
+func formatAudioInfo(f *youtube.Format) audioInfo {
+	info := audioInfo{
+		bitrate:  f.AverageBitrate,
+		channels: channelLayout(f.AudioChannels),
+	}
+	if info.bitrate == 0 {
+		info.bitrate = f.Bitrate
+	}
+	if match := mimeCodecs.FindStringSubmatch(f.MimeType); match != nil {
+		info.codec = match[1]
+	} else {
+		info.codec = strings.SplitN(f.MimeType, ";", 2)[0]
+	}
+	info.sampleRate, _ = strconv.Atoi(f.AudioSampleRate)
+	return info
+}
+
+// streamQualities are the values of the stream_quality settings: the best
+// stream, one near mediumStreamBitrate, or the smallest
+var streamQualities = []string{"high", "medium", "low"}
+
+// mediumStreamBitrate is the bitrate the medium stream quality aims for
+const mediumStreamBitrate = 128_000
+
+// streamCodecs are the values of playback.stream_codec
+var streamCodecs = []string{"opus", "aac"}
+
+// playbackAudioFormat picks the stream to play: of playback.stream_codec if
+// it has one, nearest playback.stream_bitrate if set, and the smallest on
+// low-bandwidth connections
+func playbackAudioFormat(formats youtube.FormatList) *youtube.Format {
+	if codec := cfg.Playback.StreamCodec; codec != "" {
+		var matching youtube.FormatList
+		for _, f := range formats {
+			c := formatAudioInfo(&f).codec
+			if c == codec || codec == "aac" && strings.HasPrefix(c, "mp4a") {
+				matching = append(matching, f)
+			}
+		}
+		if len(matching) > 0 {
+			formats = matching
+		}
+	}
+	switch {
+	case lowBandwidth():
+		return bestAudioFormat(formats, "low")
+	case cfg.Playback.StreamBitrate > 0:
+		return nearestAudioFormat(formats, cfg.Playback.StreamBitrate*1000)
+	}
+	return bestAudioFormat(formats, cfg.Playback.StreamQuality)
+}
+
+// validStreamQuality checks a stream_quality setting of section
+func validStreamQuality(section, quality string) error {
+	if quality == "" || slices.Contains(streamQualities, quality) {
+		return nil
+	}
+	return fmt.Errorf("%s: stream_quality must be %s, got %q", section, strings.Join(streamQualities, ", "), quality)
+}
+
+// streamScore is how good a stream sounds: its bitrate, with Opus counted a
+// quarter higher than AAC since it sounds better at the same bitrate
+func streamScore(f *youtube.Format) float64 {
+	info := formatAudioInfo(f)
+	score := float64(info.bitrate)
+	if info.codec == "opus" {
+		score *= 1.25
+	}
+	return score
+}
+
+// bestAudioFormat picks the stream of the audio formats to play or download
+// for a stream_quality setting, "high" if empty. Dubbed audio tracks are
+// passed over for the original. It returns nil without audio formats.
+func bestAudioFormat(formats youtube.FormatList, quality string) *youtube.Format {
+	return pickAudioFormat(formats, func(f, best *youtube.Format) bool {
+		score, bestScore := streamScore(f), streamScore(best)
+		switch quality {
+		case "low":
+			return score < bestScore
+		case "medium":
+			d, bestD := math.Abs(score-mediumStreamBitrate), math.Abs(bestScore-mediumStreamBitrate)
+			return d < bestD || d == bestD && score > bestScore
+		}
+		return score > bestScore
+	})
+}
+
+// nearestAudioFormat picks the stream whose bitrate is nearest bitrate,
+// the better sounding one on a tie
+func nearestAudioFormat(formats youtube.FormatList, bitrate int) *youtube.Format {
+	return pickAudioFormat(formats, func(f, best *youtube.Format) bool {
+		d := math.Abs(float64(formatAudioInfo(f).bitrate - bitrate))
+		bestD := math.Abs(float64(formatAudioInfo(best).bitrate - bitrate))
+		return d < bestD || d == bestD && streamScore(f) > streamScore(best)
+	})
+}
+
+// pickAudioFormat returns the format better ranks first, passing over
+// dubbed audio tracks for the original
+func pickAudioFormat(formats youtube.FormatList, better func(f, best *youtube.Format) bool) *youtube.Format {
+	var best *youtube.Format
+	for i := range formats {
+		f := &formats[i]
+		if best == nil {
+			best = f
+			continue
+		}
+		if original, bestOriginal := f.AudioTrack == nil || f.AudioTrack.AudioIsDefault,
+			best.AudioTrack == nil || best.AudioTrack.AudioIsDefault; original != bestOriginal {
+			if original {
+				best = f
+			}
+			continue
+		}
+		if better(f, best) {
+			best = f
+		}
+	}
+	return best
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	badgeMusicVideo    = regexp.MustCompile(`(?i)\b(?:official (?:music )?video|music video|m/?v)\b`)
+	badgeOfficialAudio = regexp.MustCompile(`(?i)\bofficial audio\b`)
+	badgeLyricVideo    = regexp.MustCompile(`(?i)\blyrics? video\b`)
+	badgeRemaster      = regexp.MustCompile(`(?i)\bremaster(?:ed)?\b`)
+)
+
+// badges labels what kind of upload a result is, so a live bootleg or a
+// cover doesn't pass for the studio track
+func (i songItem) badges() []string {
+	var badges []string
+	if i.path != "" {
+		return nil // Local files are whatever the user saved
+	}
+	if i.isAlbum {
+		if albumVersionKind(i.title) == "live" {
+			badges = append(badges, "Live")
+		}
+	} else {
+		switch {
+		case badgeMusicVideo.MatchString(i.title):
+			badges = append(badges, "Music Video")
+		case badgeLyricVideo.MatchString(i.title):
+			badges = append(badges, "Lyric Video")
+		case i.video:
+			badges = append(badges, "Video")
+		case badgeOfficialAudio.MatchString(i.title) || i.album != "":
+			// YouTube Music only files songs released on an album under one
+			badges = append(badges, "Official Audio")
+		}
+		switch versionKind(i.title) {
+		case "live":
+			badges = append(badges, "Live")
+		case "cover":
+			badges = append(badges, "Cover")
+		}
+	}
+	if badgeRemaster.MatchString(i.title) {
+		badges = append(badges, "Remaster")
+	}
+	return badges
+}
+
+// renderBadges formats badges for a list description, e.g. " [Live] [Cover]"
+func renderBadges(badges []string) string {
+	if len(badges) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(badges, "] [") + "]"
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// twoPaneWidth is the narrowest terminal the search results share with the
+// details of the highlighted result
+const twoPaneWidth = 120
+
+// browseSettle is how long a result must stay highlighted before its details
+// are loaded, so scrolling through the list doesn't fire a lookup per row
+const browseSettle = 250 * time.Millisecond
+
+// browseTracklists keeps the tracklists of albums already previewed, by album ID
+var browseTracklists = map[string][]songItem{}
+
+// browseSettledMsg fires browseSettle after the result with key was highlighted
+type browseSettledMsg struct {
+	key string
+}
+
+// browseCoverMsg carries the ASCII cover of the result with key
+type browseCoverMsg struct {
+	key   string
+	cover string
+}
+
+// browseTracksMsg carries the tracklist of the album result with key
+type browseTracksMsg struct {
+	key    string
+	tracks []songItem
+	err    error
+}
+
+// twoPane reports whether the results and the details pane fit side by side
+func (m *model) twoPane() bool {
+	return m.width >= twoPaneWidth
+}
+
+// resultsWidth is the width of the results list
+func (m *model) resultsWidth() int {
+	if m.twoPane() {
+		return (m.width - 4) / 2
+	}
+	return m.width - 4
+}
+
+// sizeResults fits the results list to the terminal and the layout
+func (m *model) sizeResults() {
+	m.list.SetSize(m.resultsWidth(), m.height-8)
+}
+
+// browseCoverSize is the size of the cover in the details pane
+func (m *model) browseCoverSize() (cols, rows int) {
+	paneWidth := m.width - 4 - m.resultsWidth() - 2
+	rows = min(min(paneWidth/2, (m.height-8)/2), 16)
+	if rows < minArtRows {
+		return 0, 0
+	}
+	return 2 * rows, rows
+}
+
+// previewHighlighted starts loading the details of the highlighted result
+// once it has been highlighted for browseSettle
+func (m *model) previewHighlighted() tea.Cmd {
+	if m.state != stateSelecting || !m.twoPane() {
+		return nil
+	}
+	item, ok := m.list.SelectedItem().(songItem)
+	if !ok || item.id == m.browseKey {
+		return nil
+	}
+	m.browseKey = item.id
+	m.browseCover = ""
+	m.browseTracks, m.browseErr = browseTracklists[item.id], nil
+	m.browseLoading = false
+	previewJob.stop()
+	key := item.id
+	return tea.Tick(browseSettle, func(time.Time) tea.Msg {
+		return browseSettledMsg{key: key}
+	})
+}
+
+// loadBrowseDetails fetches the cover and, for albums, the tracklist of the
+// result the settled message is for, if it is still highlighted
+func (m *model) loadBrowseDetails(msg browseSettledMsg) tea.Cmd {
+	item, ok := m.list.SelectedItem().(songItem)
+	if m.state != stateSelecting || !ok || item.id != msg.key || msg.key != m.browseKey {
+		return nil
+	}
+	ctx := previewJob.start()
+	var cmds []tea.Cmd
+	if cols, rows := m.browseCoverSize(); rows > 0 && item.thumb != "" && artMode() != "off" {
+		cmds = append(cmds, m.browseCoverCmd(ctx, item, cols, rows))
+	}
+	if _, cached := browseTracklists[item.id]; item.isAlbum && !cached {
+		m.browseLoading = true
+		cmds = append(cmds, m.spinner.Tick, browseTracksCmd(ctx, item))
+	}
+	return tea.Batch(cmds...)
+}
+
+// browseCoverCmd draws the cover of item as ASCII art cols x rows
+func (m *model) browseCoverCmd(ctx context.Context, item songItem, cols, rows int) tea.Cmd {
+	return func() tea.Msg {
+		path, err := m.cachedArt(ctx, item.id, item.thumb)
+		if err != nil {
+			return nil
+		}
+		return browseCoverMsg{key: item.id, cover: cachedASCIIArt(path, cols, rows)}
+	}
+}
+
+// browseTracksCmd looks up the tracklist of album the way opening it does
+func browseTracksCmd(ctx context.Context, album songItem) tea.Cmd {
+	return func() tea.Msg {
+		switch msg := searchAlbumWithTracks(ctx, album)().(type) {
+		case albumTracksFetchedMsg:
+			return browseTracksMsg{key: album.id, tracks: msg.tracks}
+		case errMsg:
+			return browseTracksMsg{key: album.id, err: msg}
+		}
+		return nil // Cancelled
+	}
+}
+
+// applyBrowseCover shows the cover if its result is still highlighted
+func (m *model) applyBrowseCover(msg browseCoverMsg) {
+	if msg.key == m.browseKey {
+		m.browseCover = msg.cover
+	}
+}
+
+// applyBrowseTracks keeps the tracklist and shows it if its album is still highlighted
+func (m *model) applyBrowseTracks(msg browseTracksMsg) {
+	if msg.err == nil {
+		browseTracklists[msg.key] = msg.tracks
+	}
+	if msg.key == m.browseKey {
+		m.browseTracks, m.browseErr = msg.tracks, msg.err
+		m.browseLoading = false
+	}
+}
+
+// viewResults shows the search results, with the details of the highlighted
+// one beside them on wide terminals
+func (m model) viewResults() string {
+	help := helpStyle.Render("\n  ENTER: Browse Album/Download Song  •  O: Download Options  •  P: Play Song  •  A: Queue All  •  *: Pin  •  Q: Quit")
+	if !m.twoPane() {
+		return docStyle.Render(lipgloss.JoinVertical(lipgloss.Left, m.list.View(), help+m.renderNotice()))
+	}
+	panes := lipgloss.JoinHorizontal(lipgloss.Top,
+		lipgloss.NewStyle().Width(m.resultsWidth()).Render(m.list.View()),
+		"  ",
+		m.viewBrowseDetails(),
+	)
+	return docStyle.Render(lipgloss.JoinVertical(lipgloss.Left, panes, help+m.renderNotice()))
+}
+
+// viewBrowseDetails is the details pane of the highlighted result
+func (m model) viewBrowseDetails() string {
+	item, ok := m.list.SelectedItem().(songItem)
+	if !ok {
+		return ""
+	}
+	width := m.width - 4 - m.resultsWidth() - 2
+	height := m.height - 8
+	line := lipgloss.NewStyle().MaxWidth(width)
+
+	title := strings.TrimPrefix(item.title, "📀 ")
+	lines := []string{line.Render(titleStyle.Render(title)), "", line.Render(item.author)}
+	var facts []string
+	switch {
+	case item.isAlbum:
+		facts = append(facts, cmp.Or(item.category, "Album"))
+		if item.trackCount > 0 {
+			facts = append(facts, fmt.Sprintf("%d tracks", item.trackCount))
+		}
+	case item.video:
+		facts = append(facts, "Video")
+	default:
+		facts = append(facts, "Song")
+	}
+	if item.album != "" {
+		facts = append(facts, item.album)
+	}
+	if item.year != "" && !item.isAlbum {
+		facts = append(facts, item.year)
+	}
+	if item.duration > 0 {
+		facts = append(facts, formatDuration(time.Duration(item.duration)*time.Second))
+	}
+	lines = append(lines, line.Render(helpStyle.Render(strings.Join(facts, " • "))), "")
+
+	if m.browseCover != "" {
+		lines = append(lines, strings.Split(m.browseCover, "\n")...)
+		lines = append(lines, "")
+	}
+
+	if item.isAlbum {
+		switch {
+		case m.browseErr != nil:
+			lines = append(lines, line.Render(errorStyle.Render(m.browseErr.Error())))
+		case m.browseLoading:
+			lines = append(lines, m.spinner.View()+" Loading tracklist...")
+		case m.browseTracks != nil:
+			for i, t := range m.browseTracks {
+				if len(lines) >= height-1 {
+					lines = append(lines, helpStyle.Render(fmt.Sprintf("…and %d more", len(m.browseTracks)-i)))
+					break
+				}
+				row := fmt.Sprintf("%2d. %s", i+1, t.title)
+				if t.duration > 0 {
+					row += helpStyle.Render("  " + formatDuration(time.Duration(t.duration)*time.Second))
+				}
+				lines = append(lines, line.Render(row))
+			}
+		}
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return strings.Join(lines, "\n")
+}
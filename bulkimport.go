@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// youtubeLink matches the video ID in watch, short, embed, live and youtu.be
+// links, including HTML-escaped query strings from bookmark exports
+var youtubeLink = regexp.MustCompile(`(?:youtube\.com/(?:watch\?(?:[^\s"'<>]*?&)?v=|shorts/|embed/|live/)|youtu\.be/)([A-Za-z0-9_-]{11})`)
+
+// extractVideoIDs returns the YouTube video IDs linked from a bookmarks HTML
+// export or a plain list of URLs, in order and without repeats
+func extractVideoIDs(text string) []string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, match := range youtubeLink.FindAllStringSubmatch(html.UnescapeString(text), -1) {
+		if id := match[1]; !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// videoItem looks up a video's title, channel and thumbnail for download or playback
+func videoItem(client youtube.Client, id string) (songItem, error) {
+	video, err := client.GetVideo(id)
+	if err != nil {
+		return songItem{}, err
+	}
+	item := songItem{
+		id:       id,
+		title:    video.Title,
+		author:   video.Author,
+		duration: int(video.Duration.Seconds()),
+	}
+	if n := len(video.Thumbnails); n > 0 {
+		item.thumb = video.Thumbnails[n-1].URL
+	}
+	return item, nil
+}
+
+// runImport implements `gomusic import [--yes] [--playlist queue.json] FILE`:
+// the YouTube links in FILE are downloaded, skipping tracks already in the
+// download archive, or saved as a queue file to play with --queue
+func runImport(args []string) int {
+	yes := false
+	playlist := ""
+	var files []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--yes", "-y":
+			yes = true
+		case "--playlist":
+			if i+1 < len(args) {
+				i++
+				playlist = args[i]
+			}
+		default:
+			files = append(files, args[i])
+		}
+	}
+	if len(files) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gomusic import [--yes] [--playlist queue.json] bookmarks.html|links.txt")
+		return 2
+	}
+
+	release, ok := claimCommand("import")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	ids := extractVideoIDs(string(data))
+	if len(ids) == 0 {
+		fmt.Fprintf(os.Stderr, "No YouTube links found in %s.\n", files[0])
+		return 1
+	}
+
+	if playlist != "" {
+		return importPlaylist(ids, playlist)
+	}
+
+	archive := loadArchive()
+	var todo []string
+	for _, id := range ids {
+		if !archive[id] {
+			todo = append(todo, id)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Found %d links, %d already downloaded.\n", len(ids), len(ids)-len(todo))
+	if len(todo) == 0 {
+		return 0
+	}
+	if !yes && !confirm(fmt.Sprintf("Download %d tracks?", len(todo))) {
+		fmt.Fprintln(os.Stderr, "Aborted.")
+		return 1
+	}
+
+	client := youtube.Client{}
+	pace := newPacer(cfg.Pacing)
+	failed := 0
+	for i, id := range todo {
+		var path string
+		var item songItem
+		for attempt := 0; attempt < 2; attempt++ {
+			pace.wait()
+			if item, err = videoItem(client, id); err == nil {
+				fmt.Fprintf(os.Stderr, "[%d/%d] %s - %s\n", i+1, len(todo), item.author, item.title)
+				path, err = downloadForCLI(item, false)
+			}
+			if isThrottled(err) {
+				until := pace.coolDown()
+				fmt.Fprintf(os.Stderr, "YouTube is rate limiting requests, waiting %s...\n", formatDuration(time.Until(until)))
+				continue
+			}
+			pace.succeeded()
+			break
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "\n%s: %v\n", id, err)
+			continue
+		}
+		fmt.Println(path)
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d downloads failed.\n", failed, len(todo))
+		return 1
+	}
+	return 0
+}
+
+// importPlaylist saves the linked videos as a queue file, looking up titles
+// so the queue reads well when played
+func importPlaylist(ids []string, path string) int {
+	client := youtube.Client{}
+	pace := newPacer(cfg.Pacing)
+	var tracks []songItem
+	for i, id := range ids {
+		pace.wait()
+		item, err := videoItem(client, id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[%d/%d] %s: %v\n", i+1, len(ids), id, err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s - %s\n", i+1, len(ids), item.author, item.title)
+		tracks = append(tracks, item)
+	}
+	if len(tracks) == 0 {
+		fmt.Fprintln(os.Stderr, "None of the links could be looked up.")
+		return 1
+	}
+	if err := writeQueueFile(path, tracks, 0); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Saved %d tracks. Play them with: gomusic --queue %s\n", len(tracks), path)
+	return 0
+}
+
+// confirm asks a yes/no question on stderr; an empty answer means yes
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [Y/n] ", question)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "" || answer == "y" || answer == "yes"
+}
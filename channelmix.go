@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+	"sync/atomic"
+
+	"github.com/faiface/beep"
+)
+
+// channelMix holds the mono and balance settings the playing streamer reads,
+// so changes from the settings screen are heard straight away
+var channelMix atomic.Pointer[playbackConfig]
+
+// applyChannelMix puts the mono and balance settings into effect
+func applyChannelMix(c playbackConfig) {
+	channelMix.Store(&c)
+}
+
+// channelMixer downmixes to mono and applies left/right balance to the
+// streamer it wraps, and records its level for the pulse effect
+type channelMixer struct {
+	beep.Streamer
+}
+
+func (c channelMixer) Stream(samples [][2]float64) (int, bool) {
+	n, ok := c.Streamer.Stream(samples)
+	recordLevel(samples[:n])
+	mix := channelMix.Load()
+	if mix == nil || (!mix.Mono && mix.Balance == 0) {
+		return n, ok
+	}
+	// Turning one side down keeps the other at full level
+	left := 1 - math.Max(0, mix.Balance)
+	right := 1 + math.Min(0, mix.Balance)
+	for i := range samples[:n] {
+		if mix.Mono {
+			v := (samples[i][0] + samples[i][1]) / 2
+			samples[i] = [2]float64{v, v}
+		}
+		samples[i][0] *= left
+		samples[i][1] *= right
+	}
+	return n, ok
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// chapter is a song marker inside a long video, taken from its description
+type chapter struct {
+	start time.Duration
+	title string
+}
+
+var (
+	// "0:00 Intro", "[01:02:03] - Song" or "1. 3:20 Song"
+	chapterLeadingTime = regexp.MustCompile(`^\s*(?:\d+[.)]\s*)?[\[(]?((?:\d{1,2}:)?\d{1,2}:\d{2})[\])]?\s*(?:[-–—:|•]\s*)?(.+?)\s*$`)
+	// "Intro - 0:00" or "Song (3:20)"
+	chapterTrailingTime = regexp.MustCompile(`^\s*(.+?)\s*(?:[-–—:|•]\s*)?[\[(]?((?:\d{1,2}:)?\d{1,2}:\d{2})[\])]?\s*$`)
+)
+
+// parseChapters extracts chapter markers from a video description. Like
+// YouTube itself, it requires at least two increasing timestamps with the
+// first at 0:00, which keeps stray times in prose from becoming chapters.
+func parseChapters(description string) []chapter {
+	var chapters []chapter
+	for _, line := range strings.Split(description, "\n") {
+		c, ok := parseChapterLine(line)
+		if !ok {
+			continue
+		}
+		if len(chapters) > 0 && c.start <= chapters[len(chapters)-1].start {
+			continue
+		}
+		chapters = append(chapters, c)
+	}
+	if len(chapters) < 2 || chapters[0].start != 0 {
+		return nil
+	}
+	return chapters
+}
+
+// parseChapterLine reads one line of a tracklist, with the time before or
+// after the title
+func parseChapterLine(line string) (chapter, bool) {
+	var stamp, title string
+	if match := chapterLeadingTime.FindStringSubmatch(line); match != nil {
+		stamp, title = match[1], match[2]
+	} else if match := chapterTrailingTime.FindStringSubmatch(line); match != nil {
+		title, stamp = match[1], match[2]
+	} else {
+		return chapter{}, false
+	}
+	start, err := parseOffset(stamp)
+	if err != nil {
+		return chapter{}, false
+	}
+	return chapter{start: start, title: strings.Trim(title, " -–—:|•/")}, true
+}
+
+// adjustChapters moves chapters onto the trimmed timeline. A chapter that
+// starts before the cut is kept from the new start.
+func (o downloadOptions) adjustChapters(chapters []chapter) []chapter {
+	if o.trimStart == 0 && o.trimEnd == 0 {
+		return chapters
+	}
+	var out []chapter
+	for i, c := range chapters {
+		if o.trimEnd > 0 && c.start >= o.trimEnd {
+			break
+		}
+		if i+1 < len(chapters) && chapters[i+1].start <= o.trimStart {
+			continue
+		}
+		start := c.start - o.trimStart
+		if start < 0 {
+			start = 0
+		}
+		out = append(out, chapter{start: start, title: c.title})
+	}
+	if len(out) < 2 {
+		return nil
+	}
+	return out
+}
+
+// escapeFFMetadata escapes the characters that are special in ffmetadata files
+func escapeFFMetadata(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n").Replace(s)
+}
+
+// writeChapterMetadata writes chapters as an ffmetadata file for "-map_chapters".
+// Each chapter ends where the next begins, the last one at total.
+func writeChapterMetadata(path string, chapters []chapter, total time.Duration) error {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	for i, c := range chapters {
+		end := total
+		if i+1 < len(chapters) {
+			end = chapters[i+1].start
+		}
+		if end < c.start {
+			end = c.start
+		}
+		fmt.Fprintf(&b, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			c.start.Milliseconds(), end.Milliseconds(), escapeFFMetadata(c.title))
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
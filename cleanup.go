@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// staleTempAge is how long a temp file must be left untouched before it
+// counts as left behind, so files a running instance is writing are spared
+const staleTempAge = time.Hour
+
+// tempFilePattern matches the temp files downloads, conversions and cover
+// lookups leave in the working directory
+var tempFilePattern = regexp.MustCompile(`^temp_(audio|thumb|cover|chapters)_`)
+
+// albumTempPattern matches the partial downloads of album tracks, which the
+// album download record resumes by track number
+var albumTempPattern = regexp.MustCompile(`^temp_audio_\d+$`)
+
+// cleanupTempFiles removes temp files left behind by crashes and killed
+// downloads, keeping the partial files paused and interrupted album
+// downloads resume from. Paused downloads whose partial file is gone are
+// forgotten. It returns how many files were removed.
+func cleanupTempFiles() int {
+	keep := map[string]bool{}
+	paused := loadPausedDownloads()
+	kept := paused[:0]
+	for _, p := range paused {
+		if _, err := os.Stat(p.TempPath); err == nil {
+			keep[absPath(p.TempPath)] = true
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) < len(paused) {
+		saveState(pausedDownloadsKey, kept)
+	}
+	albumPending := loadAlbumDownload() != nil
+
+	removed := 0
+	entries, _ := os.ReadDir(".")
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !tempFilePattern.MatchString(name) || keep[absPath(name)] {
+			continue
+		}
+		if albumPending && albumTempPattern.MatchString(name) {
+			continue
+		}
+		if removeStale(name) {
+			removed++
+		}
+	}
+
+	// Covers cut off while being downloaded or resized
+	if dir, err := cacheDir("art"); err == nil {
+		parts, _ := filepath.Glob(filepath.Join(dir, "*.part.jpg"))
+		for _, path := range parts {
+			if removeStale(path) {
+				removed++
+			}
+		}
+	}
+	return removed
+}
+
+// removeStale removes path if it hasn't been touched for staleTempAge
+func removeStale(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) < staleTempAge {
+		return false
+	}
+	return os.Remove(path) == nil
+}
+
+// absPath returns path made absolute, or as it is if that fails
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
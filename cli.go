@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kkdai/youtube/v2"
+	"github.com/raitonoberu/ytmusic"
+)
+
+// minMatchScore is the lowest confidence at which get picks a result on its own
+const minMatchScore = 0.5
+
+// plausibleMatchScore is the lowest confidence for a result to be offered in the shortlist
+const plausibleMatchScore = 0.25
+
+// maxShortlist is the number of candidates offered for interactive confirmation
+const maxShortlist = 5
+
+// plainProgressStep is how often plain progress prints a line, in percent
+const plainProgressStep = 10
+
+// cliReporter prints background download events to stderr for CLI commands,
+// or with plain set as whole lines on stdout for scripts and logs
+type cliReporter struct {
+	result  string
+	err     error
+	lastPct int
+	plain   bool
+}
+
+func (r *cliReporter) Send(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case downloadProgressMsg:
+		pct := int(float64(msg) * 100)
+		switch {
+		case r.plain && pct/plainProgressStep != r.lastPct/plainProgressStep:
+			fmt.Printf("downloading %d%%\n", pct/plainProgressStep*plainProgressStep)
+			r.lastPct = pct
+		case !r.plain && pct != r.lastPct:
+			fmt.Fprintf(os.Stderr, "\rDownloading... %3d%%", pct)
+			r.lastPct = pct
+		}
+	case convertMsg:
+		if r.plain {
+			fmt.Println("encoding")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "\rEncoding & tagging...   \n")
+	case doneMsg:
+		r.result = msg.path
+	case verifyFailedMsg:
+		r.result = msg.fileName
+		r.err = msg.err
+	case errMsg:
+		r.err = msg
+	}
+}
+
+// trackMatch is a search result scored against the user's query
+type trackMatch struct {
+	item  songItem
+	score float64
+}
+
+// splitArtistTitle splits an "artist - title" query; artist is empty without a separator
+func splitArtistTitle(query string) (artist, title string) {
+	if parts := strings.SplitN(query, " - ", 2); len(parts) == 2 {
+		return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	}
+	return "", strings.TrimSpace(query)
+}
+
+// rankTrackMatches scores results by artist/title similarity, penalising
+// durations typical of teasers and full-album uploads, best first
+func rankTrackMatches(query string, items []songItem) []trackMatch {
+	artist, title := splitArtistTitle(query)
+
+	var matches []trackMatch
+	for _, item := range items {
+		var score float64
+		if artist != "" {
+			score = 0.6*wordSimilarity(cleanString(item.title), title) +
+				0.4*wordSimilarity(item.author, artist)
+		} else {
+			score = wordSimilarity(item.author+" "+cleanString(item.title), title)
+		}
+		if item.duration > 0 && (item.duration < 60 || item.duration > 15*60) {
+			score *= 0.5
+		}
+		matches = append(matches, trackMatch{item: item, score: score})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	return matches
+}
+
+// searchTracksCLI runs a track search and returns the playable results
+func searchTracksCLI(query string) ([]songItem, error) {
+	result, err := ytmusic.TrackSearch(query).Next()
+	if err != nil {
+		return nil, fmt.Errorf("YouTube Music track search failed: %v", err)
+	}
+	var items []songItem
+	for _, track := range result.Tracks {
+		if len(track.VideoID) >= 10 {
+			items = append(items, convertYTMusicTrack(track))
+		}
+	}
+	return items, nil
+}
+
+// downloadForCLI runs the regular download pipeline without the TUI and
+// returns the finished file, re-downloading once if verification fails
+func downloadForCLI(item songItem, plain bool) (string, error) {
+	var r *cliReporter
+	for attempt := 0; attempt < 2; attempt++ {
+		r = &cliReporter{lastPct: -1, plain: plain}
+		m := &model{selected: item, program: r, download: &downloadControl{}}
+		m.runDownloadConvert(appCtx)
+		if _, ok := r.err.(*verifyError); ok && attempt == 0 {
+			fmt.Fprintf(os.Stderr, "%v\nRe-downloading...\n", r.err)
+			os.Remove(r.result)
+			continue
+		}
+		break
+	}
+	return r.result, r.err
+}
+
+// promptMatch prints a numbered shortlist and reads the user's choice from stdin.
+// An empty answer picks the first entry; ok is false if the user aborts.
+func promptMatch(shortlist []trackMatch) (songItem, bool) {
+	fmt.Fprintln(os.Stderr, "Multiple plausible matches:")
+	for i, match := range shortlist {
+		album := match.item.album
+		if album == "" {
+			album = "-"
+		}
+		fmt.Fprintf(os.Stderr, "  %d) %s - %s  [%s]  %s\n", i+1,
+			match.item.author, match.item.title, album,
+			formatDuration(time.Duration(match.item.duration)*time.Second))
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprintf(os.Stderr, "Select 1-%d [1], or q to abort: ", len(shortlist))
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" && err == nil {
+			return shortlist[0].item, true
+		}
+		if line == "q" || err != nil {
+			return songItem{}, false
+		}
+		if n, convErr := strconv.Atoi(line); convErr == nil && n >= 1 && n <= len(shortlist) {
+			return shortlist[n-1].item, true
+		}
+	}
+}
+
+// runGet implements `gomusic get [--yes] "artist - title"`: search, pick the
+// top confident match (asking when several are plausible), download and print
+// the saved path
+func runGet(args []string) int {
+	yes := false
+	var words []string
+	for _, arg := range args {
+		if arg == "--yes" || arg == "-y" {
+			yes = true
+			continue
+		}
+		words = append(words, arg)
+	}
+	query := strings.TrimSpace(strings.Join(words, " "))
+	if query == "" {
+		fmt.Fprintln(os.Stderr, `usage: gomusic get [--yes] "artist - title"`)
+		return 2
+	}
+
+	release, ok := claimCommand("get")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	fmt.Fprintf(os.Stderr, "Searching YouTube Music for %q...\n", query)
+	items, err := searchTracksCLI(query)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	matches := rankTrackMatches(query, items)
+	if len(matches) == 0 {
+		fmt.Fprintln(os.Stderr, "No results found.")
+		return 1
+	}
+
+	var shortlist []trackMatch
+	for _, match := range matches {
+		if match.score >= plausibleMatchScore && len(shortlist) < maxShortlist {
+			shortlist = append(shortlist, match)
+		}
+	}
+
+	var chosen songItem
+	if len(shortlist) > 1 && !yes {
+		item, ok := promptMatch(shortlist)
+		if !ok {
+			fmt.Fprintln(os.Stderr, "Aborted.")
+			return 1
+		}
+		chosen = item
+	} else {
+		best := matches[0]
+		if best.score < minMatchScore {
+			fmt.Fprintf(os.Stderr, "No confident match (best guess: %s - %s). Try a more specific query or use the TUI.\n",
+				best.item.author, best.item.title)
+			return 1
+		}
+		chosen = best.item
+	}
+
+	fmt.Fprintf(os.Stderr, "Matched: %s - %s\n", chosen.author, chosen.title)
+	path, err := downloadForCLI(chosen, false)
+	if err != nil {
+		fe := classifyError(err)
+		fmt.Fprintf(os.Stderr, "\n%s: %v\n", fe.title, err)
+		if fe.hint != "" {
+			fmt.Fprintln(os.Stderr, fe.hint)
+		}
+		return 1
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	fmt.Println(path)
+	return 0
+}
+
+// runDownload implements `gomusic download URL|"artist - title"`: a link or
+// video ID is downloaded as is, a query takes the top confident match without
+// asking. Progress goes to stdout as plain lines and the last line is the
+// saved path, so it runs unattended from cron and pipelines.
+func runDownload(args []string) int {
+	arg := strings.TrimSpace(strings.Join(args, " "))
+	if arg == "" {
+		fmt.Fprintln(os.Stderr, `usage: gomusic download YOUTUBE_URL|"artist - title"`)
+		return 2
+	}
+
+	var item songItem
+	id := arg
+	if match := youtubeLink.FindStringSubmatch(arg); match != nil {
+		id = match[1]
+	}
+	release, ok := claimCommand("download")
+	if !ok {
+		if !bareVideoID.MatchString(id) {
+			return 1
+		}
+		// A link can go to the running gomusic instead
+		if err := sendHandoff(handoffRequest{Action: "download", ID: id}); err != nil {
+			fmt.Fprintf(os.Stderr, "Handing the track to the running gomusic failed: %v\n", err)
+			return 1
+		}
+		fmt.Fprintln(os.Stderr, "Download started in the running gomusic")
+		return 0
+	}
+	defer release()
+
+	if bareVideoID.MatchString(id) {
+		var err error
+		if item, err = videoItem(youtube.Client{}, id); err != nil {
+			fmt.Fprintf(os.Stderr, "Looking up %s failed: %v\n", id, err)
+			return 1
+		}
+	} else {
+		items, err := searchTracksCLI(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		matches := rankTrackMatches(arg, items)
+		if len(matches) == 0 || matches[0].score < minMatchScore {
+			fmt.Fprintf(os.Stderr, "No confident match for %q\n", arg)
+			return 1
+		}
+		item = matches[0].item
+	}
+
+	fmt.Printf("track %s - %s\n", item.author, item.title)
+	path, err := downloadForCLI(item, true)
+	if err != nil {
+		fe := classifyError(err)
+		fmt.Fprintf(os.Stderr, "%s: %v\n", fe.title, err)
+		if fe.hint != "" {
+			fmt.Fprintln(os.Stderr, fe.hint)
+		}
+		return 1
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	fmt.Println(path)
+	return 0
+}
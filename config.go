@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+const configFileName = "config.toml"
+
+// cfg is the user configuration, loaded once at startup
+var cfg = defaultConfig()
+
+// config mirrors config.toml. Every setting is optional.
+type config struct {
+	Network       networkConfig       `toml:"network"`
+	Pacing        pacingConfig        `toml:"pacing"`
+	TitleCleaning titleCleaningConfig `toml:"title_cleaning"`
+	Output        outputConfig        `toml:"output"`
+	Playback      playbackConfig      `toml:"playback"`
+	Download      downloadConfig      `toml:"download"`
+	Search        searchConfig        `toml:"search"`
+	Transfer      transferConfig      `toml:"transfer"`
+	Watch         watchConfig         `toml:"watch"`
+	Info          infoConfig          `toml:"info"`
+	QuietHours    quietHoursConfig    `toml:"quiet_hours"`
+	Theme         themeConfig         `toml:"theme"`
+	Lyrics        lyricsConfig        `toml:"lyrics"`
+	Metadata      metadataConfig      `toml:"metadata"`
+	Art           artConfig           `toml:"art"`
+	Keys          keysConfig          `toml:"keys"`
+}
+
+// defaultConfig returns the settings used when config.toml leaves them out
+func defaultConfig() config {
+	return config{
+		Network: networkConfig{
+			Timeout:        15 * time.Second,
+			ConnectTimeout: 30 * time.Second,
+			Retries:        2,
+			MaxIdleConns:   4,
+		},
+		Pacing: pacingConfig{
+			Delay:    1500 * time.Millisecond,
+			Jitter:   time.Second,
+			Cooldown: time.Minute,
+		},
+		Search: searchConfig{
+			MinLength: time.Minute,
+			MaxLength: 15 * time.Minute,
+		},
+	}
+}
+
+// networkConfig controls how gomusic reaches YouTube and the lyrics APIs
+type networkConfig struct {
+	Interface     string `toml:"interface,omitempty"`      // Bind to the first address of this interface, e.g. "eth0"
+	SourceAddress string `toml:"source_address,omitempty"` // Bind to this local IP address
+	IPVersion     int    `toml:"ip_version,omitzero"`      // 4 or 6 to force a protocol, 0 for either
+	ForceIPv4     bool   `toml:"force_ipv4,omitempty"`     // Shorthand for ip_version = 4
+
+	Timeout        time.Duration `toml:"timeout,omitzero"`         // Limit for metadata, lyric and cover requests
+	ConnectTimeout time.Duration `toml:"connect_timeout,omitzero"` // Limit for connecting and for a reply to start, downloads included
+	Retries        int           `toml:"retries,omitzero"`         // Extra attempts after a connection error or 5xx reply
+	UserAgent      string        `toml:"user_agent,omitempty"`     // Sent where YouTube and YouTube Music need no specific one
+	MaxIdleConns   int           `toml:"max_idle_conns,omitzero"`  // Idle connections kept open per host for reuse
+
+	LowBandwidth bool `toml:"low_bandwidth,omitempty"` // Play the smallest streams and fetch no covers; downloads keep their quality
+}
+
+// outputConfig sends playback to a FIFO, file or stdout instead of the sound card
+type outputConfig struct {
+	Mode   string `toml:"mode,omitempty"`   // "speaker" (default), "pipe", "pipewire" or "jack"
+	Path   string `toml:"path,omitempty"`   // FIFO or file for pipe mode, "-" for stdout; target node or ports for pipewire/jack; mpv audio device for exclusive
+	Format string `toml:"format,omitempty"` // "pcm" (s16le stereo at playback.sample_rate, default) or "mp3"
+	Name   string `toml:"name,omitempty"`   // Application and stream name shown in mixers, "gomusic" by default
+
+	Exclusive bool `toml:"exclusive,omitempty"` // Play lossless local files bit-perfect with the sound card to itself (WASAPI exclusive, CoreAudio hog mode); mpv backend only
+}
+
+// clientName is the name gomusic's stream shows up under in mixers and patchbays
+func (o outputConfig) clientName() string {
+	if o.Name == "" {
+		return "gomusic"
+	}
+	return o.Name
+}
+
+// native reports whether playback goes to a PipeWire or JACK stream of its own
+func (o outputConfig) native() bool {
+	return o.Mode == "pipewire" || o.Mode == "jack"
+}
+
+// pipe reports whether playback is written out instead of played
+func (o outputConfig) pipe() bool {
+	return o.Mode == "pipe"
+}
+
+// toStdout reports whether playback takes over stdout, so the UI must draw elsewhere
+func (o outputConfig) toStdout() bool {
+	return o.pipe() && o.Path == "-"
+}
+
+// configPath returns the location of config.toml, honouring XDG_CONFIG_HOME
+func configPath() (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		var err error
+		if base, err = os.UserConfigDir(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(base, "gomusic", configFileName), nil
+}
+
+// loadConfig reads config.toml into cfg. A missing file keeps the defaults.
+func loadConfig() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	meta, err := toml.DecodeFile(path, &cfg)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("%s: unknown setting %q", path, undecoded[0].String())
+	}
+	return cfg.validate()
+}
+
+func (c *config) validate() error {
+	if c.Network.ForceIPv4 {
+		if c.Network.IPVersion == 6 {
+			return fmt.Errorf("network: force_ipv4 conflicts with ip_version = 6")
+		}
+		c.Network.IPVersion = 4
+	}
+	if c.Network.IPVersion != 0 && c.Network.IPVersion != 4 && c.Network.IPVersion != 6 {
+		return fmt.Errorf("network: ip_version must be 4 or 6, got %d", c.Network.IPVersion)
+	}
+	if c.Network.Interface != "" && c.Network.SourceAddress != "" {
+		return fmt.Errorf("network: set either interface or source_address, not both")
+	}
+	if c.Network.Timeout < 0 || c.Network.ConnectTimeout < 0 {
+		return fmt.Errorf("network: timeouts can't be negative")
+	}
+	if c.Network.Retries < 0 || c.Network.MaxIdleConns < 0 {
+		return fmt.Errorf("network: retries and max_idle_conns can't be negative")
+	}
+	if c.Pacing.Delay < 0 || c.Pacing.Jitter < 0 || c.Pacing.Cooldown < 0 {
+		return fmt.Errorf("pacing: durations can't be negative")
+	}
+	switch c.Output.Mode {
+	case "", "speaker", "pipe", "pipewire", "jack":
+	default:
+		return fmt.Errorf("output: mode must be speaker, pipe, pipewire or jack, got %q", c.Output.Mode)
+	}
+	switch c.Output.Format {
+	case "", "pcm", "mp3":
+	default:
+		return fmt.Errorf("output: format must be pcm or mp3, got %q", c.Output.Format)
+	}
+	if c.Output.pipe() && c.Output.Path == "" {
+		return fmt.Errorf("output: pipe mode needs a path (a FIFO, a file or \"-\" for stdout)")
+	}
+	if c.Output.native() && c.Output.Format == "mp3" {
+		return fmt.Errorf("output: %s mode plays pcm only", c.Output.Mode)
+	}
+	if c.Output.Exclusive && c.Playback.Backend != "mpv" {
+		return fmt.Errorf("output: exclusive needs playback.backend = \"mpv\"; the built-in player always goes through the system mixer")
+	}
+	if c.Output.Exclusive && c.Output.Mode != "" && c.Output.Mode != "speaker" {
+		return fmt.Errorf("output: exclusive only works in speaker mode")
+	}
+	if err := c.Playback.validate(); err != nil {
+		return err
+	}
+	if err := c.Search.validate(); err != nil {
+		return err
+	}
+	if err := c.Transfer.validate(); err != nil {
+		return err
+	}
+	if err := c.Download.validate(); err != nil {
+		return err
+	}
+	if err := c.Watch.validate(); err != nil {
+		return err
+	}
+	if err := c.Info.validate(); err != nil {
+		return err
+	}
+	if err := c.QuietHours.validate(); err != nil {
+		return err
+	}
+	if err := c.Theme.validate(); err != nil {
+		return err
+	}
+	if err := c.Lyrics.validate(); err != nil {
+		return err
+	}
+	if err := c.Metadata.validate(); err != nil {
+		return err
+	}
+	if err := c.Art.validate(); err != nil {
+		return err
+	}
+	if err := c.Keys.validate(); err != nil {
+		return err
+	}
+	return c.TitleCleaning.validate()
+}
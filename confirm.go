@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openAlbumConfirm asks before downloading tracks of the current album, since
+// a stray Enter on the album header would otherwise start it right away
+func (m *model) openAlbumConfirm(tracks []songItem) {
+	m.confirmTracks = tracks
+	m.albumZip = cfg.Download.Zip
+	m.albumFormat, m.albumPassthrough = "", cfg.Download.Passthrough
+	m.state = stateConfirmAlbum
+}
+
+func (m model) updateAlbumConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter", "y":
+		m.downloadTracks = m.confirmTracks // The album's own tracklist stays as it is
+		m.confirmTracks = nil
+		m.selected = m.currentAlbum
+		m.albumResume = nil
+		m.state = stateDownloadingAlbum
+		go m.runDownloadAlbum(downloadJob.start())
+	case "z":
+		m.albumZip = !m.albumZip
+	case "p":
+		m.albumPassthrough = !m.albumPassthrough
+	case "f":
+		m.albumFormat = nextFormat(m.albumOptions().withDefaults().format)
+	case "esc", "q":
+		m.confirmTracks = nil
+		m.state = stateViewingAlbumTracks
+	}
+	return m, nil
+}
+
+// nextFormat returns the format after format in formatOrder
+func nextFormat(format string) string {
+	for i, f := range formatOrder {
+		if f == format {
+			return formatOrder[(i+1)%len(formatOrder)]
+		}
+	}
+	return formatOrder[0]
+}
+
+// estimateAlbumSize guesses the size of tracks once converted to a format of
+// bitrate bits per second. Tracks of unknown length count as long as the
+// average of the others.
+func estimateAlbumSize(tracks []songItem, bitrate int) (int64, bool) {
+	var known, seconds int
+	for _, t := range tracks {
+		if t.duration > 0 {
+			known++
+			seconds += t.duration
+		}
+	}
+	if known == 0 {
+		return 0, false
+	}
+	seconds = seconds * len(tracks) / known
+	return int64(seconds) * int64(bitrate) / 8, true
+}
+
+func (m model) viewAlbumConfirm() string {
+	var length time.Duration
+	for _, t := range m.confirmTracks {
+		length += time.Duration(t.duration) * time.Second
+	}
+	size := "unknown"
+	format := m.albumOptions().container()
+	bitrate := format.bitrate
+	if m.albumPassthrough && m.albumFormat == "" {
+		bitrate = passthroughBitrate
+	}
+	if n, ok := estimateAlbumSize(m.confirmTracks, bitrate); ok {
+		size = "about " + formatBytes(n)
+	}
+	dir := m.albumDir(albumDirName(m.currentAlbum))
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	zip := "no"
+	if m.albumZip {
+		zip = "yes, " + filepath.Base(dir) + ".zip with cover and M3U playlist"
+	}
+	skipped := ""
+	if n := len(m.albumTracks) - len(m.confirmTracks); n > 0 {
+		skipped = fmt.Sprintf(" (%d switched off)", n)
+	}
+
+	return fmt.Sprintf("\n  %s\n\n  %s\n  %s\n  %s\n  %s\n  %s\n  %s\n\n  %s\n",
+		titleStyle.Render("Download album: "+m.currentAlbum.title),
+		fmt.Sprintf("Tracks:       %d%s", len(m.confirmTracks), skipped),
+		fmt.Sprintf("Length:       %s", formatDuration(length)),
+		fmt.Sprintf("Size:         %s", size),
+		fmt.Sprintf("Folder:       %s", dir),
+		fmt.Sprintf("Format:       %s", m.albumFormatDescription(format)),
+		fmt.Sprintf("Zip archive:  %s", zip),
+		helpStyle.Render("ENTER/Y: Download  •  F: Format  •  P: Toggle Passthrough  •  Z: Toggle Zip  •  ESC/Q: Back"),
+	)
+}
+
+// passthroughBitrate is roughly what YouTube serves Opus and AAC audio at
+const passthroughBitrate = 140_000
+
+// albumFormatDescription says what album tracks are saved as
+func (m *model) albumFormatDescription(f downloadFormat) string {
+	switch {
+	case m.albumPassthrough && m.albumFormat == "":
+		return "Opus or AAC as YouTube serves it, without re-encoding"
+	case m.albumPassthrough && (m.albumFormat == "opus" || m.albumFormat == "m4a"):
+		return f.label + ", kept without re-encoding where YouTube serves it"
+	case f.cover:
+		return f.label + " with cover art and tags"
+	}
+	return f.label + " with tags (the container can't hold cover art)"
+}
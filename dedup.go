@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	titleSimilarity   = 0.9 // Normalized titles at least this close are the same song
+	durationTolerance = 5   // Seconds two uploads of one recording may differ by
+)
+
+var (
+	// Text in brackets or after a dash, where versions are named: "Song (Live)", "Song - Acoustic"
+	titleDecoration = regexp.MustCompile(`[\(\[]([^\)\]]*)[\)\]]|\s[-–—]\s(.+)$`)
+	// Featured artists, which some uploads list in the title and others don't
+	titleFeaturing = regexp.MustCompile(`(?i)\s(?:feat|ft|featuring)\.?\s.*$`)
+
+	// versionPatterns name the non-studio versions, checked in order
+	versionPatterns = []struct {
+		kind string
+		re   *regexp.Regexp
+	}{
+		{"karaoke", regexp.MustCompile(`(?i)\bkaraoke\b`)},
+		{"instrumental", regexp.MustCompile(`(?i)\binstrumental\b`)},
+		{"sped up", regexp.MustCompile(`(?i)\b(?:sped up|speed up|slowed|nightcore)\b`)},
+		{"remix", regexp.MustCompile(`(?i)\b(?:remix|rmx|mix|dub)\b`)},
+		{"live", regexp.MustCompile(`(?i)\b(?:live|concert|in session)\b`)},
+		{"acoustic", regexp.MustCompile(`(?i)\b(?:acoustic|unplugged|stripped)\b`)},
+		{"demo", regexp.MustCompile(`(?i)\bdemo\b`)},
+		{"cover", regexp.MustCompile(`(?i)\bcover\b`)},
+	}
+)
+
+// filteredTrack is a track dropped from an album tracklist, kept for review
+type filteredTrack struct {
+	track  songItem
+	reason string
+	keptID string // Track that was kept in its place
+}
+
+func (f filteredTrack) Title() string       { return f.track.title }
+func (f filteredTrack) Description() string { return f.reason }
+func (f filteredTrack) FilterValue() string { return f.track.title }
+
+// versionKind names the version a track title announces, "" for the studio cut.
+// Only the bracketed or dashed part is checked so "Live Forever" stays a studio track.
+func versionKind(title string) string {
+	var decoration []string
+	for _, match := range titleDecoration.FindAllStringSubmatch(title, -1) {
+		decoration = append(decoration, match[1], match[2])
+	}
+	return matchVersion(strings.Join(decoration, " "))
+}
+
+// albumVersionKind names the version an album title announces, e.g. "live"
+// for "Live at Wembley", so a live album keeps its live tracks
+func albumVersionKind(title string) string {
+	return matchVersion(title)
+}
+
+func matchVersion(s string) string {
+	for _, p := range versionPatterns {
+		if p.re.MatchString(s) {
+			return p.kind
+		}
+	}
+	return ""
+}
+
+// normalizeTitle reduces a title to the bare song name for comparison
+func normalizeTitle(title string) string {
+	title = titleDecoration.ReplaceAllString(title, " ")
+	title = titleFeaturing.ReplaceAllString(title, "")
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// similarity returns how alike two strings are, from 0 to 1, by edit distance
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(min(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
+
+// sameLength reports whether two tracks could be the same recording. Unknown
+// durations don't rule it out.
+func sameLength(a, b songItem) bool {
+	if a.duration == 0 || b.duration == 0 {
+		return true
+	}
+	d := a.duration - b.duration
+	return d >= -durationTolerance && d <= durationTolerance
+}
+
+// dedupeTracks drops repeated uploads of a song and alternate versions of it,
+// keeping the version matching preferred ("" for studio). Songs that only
+// exist in another version are kept.
+func dedupeTracks(tracks []songItem, preferred string) ([]songItem, []filteredTrack) {
+	var kept []songItem
+	var filtered []filteredTrack
+	for _, t := range tracks {
+		name := normalizeTitle(t.title)
+		i := -1
+		for j, k := range kept {
+			if similarity(name, normalizeTitle(k.title)) >= titleSimilarity {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			kept = append(kept, t)
+			continue
+		}
+
+		k := kept[i]
+		kind, keptKind := versionKind(t.title), versionKind(k.title)
+		switch {
+		case kind == keptKind && sameLength(k, t):
+			filtered = append(filtered, filteredTrack{track: t, reason: "Duplicate of " + k.title, keptID: k.id})
+		case kind == keptKind:
+			// Same name but a different recording, e.g. a reprise
+			kept = append(kept, t)
+		case kind == preferred:
+			kept[i] = t
+			filtered = append(filtered, filteredTrack{track: k, reason: versionReason(keptKind, t.title), keptID: t.id})
+		case keptKind == preferred:
+			filtered = append(filtered, filteredTrack{track: t, reason: versionReason(kind, k.title), keptID: k.id})
+		default:
+			kept = append(kept, t)
+		}
+	}
+	return kept, filtered
+}
+
+func versionReason(kind, keptTitle string) string {
+	if kind == "" {
+		kind = "studio"
+	}
+	return fmt.Sprintf("%s%s version of %s", strings.ToUpper(kind[:1]), kind[1:], keptTitle)
+}
+
+// restoreFiltered puts a filtered track back into the tracklist, right after
+// the track that replaced it
+func (m *model) restoreFiltered(f filteredTrack) {
+	var remaining []filteredTrack
+	for _, other := range m.filteredTracks {
+		if other.track.id != f.track.id {
+			remaining = append(remaining, other)
+		}
+	}
+	m.filteredTracks = remaining
+
+	at := len(m.albumTracks)
+	for j, t := range m.albumTracks {
+		if t.id == f.keptID {
+			at = j + 1
+			break
+		}
+	}
+	tracks := append(append(append([]songItem{}, m.albumTracks[:at]...), f.track), m.albumTracks[at:]...)
+	m.setAlbumTracks(tracks)
+}
+
+func (m *model) openFilteredTracks() {
+	var items []list.Item
+	for _, f := range m.filteredTracks {
+		items = append(items, f)
+	}
+	index := m.filteredList.Index()
+	m.filteredList = list.New(items, list.NewDefaultDelegate(), m.width-4, m.height-8)
+	m.filteredList.Title = fmt.Sprintf("Filtered from %s", m.currentAlbum.title)
+	if len(items) > 0 {
+		m.filteredList.Select(min(index, len(items)-1))
+	}
+	m.state = stateFilteredTracks
+}
+
+func (m model) updateFilteredTracks(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.filteredList.FilterState() != list.Filtering {
+		switch msg.String() {
+		case "esc", "q", "v":
+			m.state = stateViewingAlbumTracks
+			return m, nil
+		case "enter":
+			if f, ok := m.filteredList.SelectedItem().(filteredTrack); ok {
+				m.restoreFiltered(f)
+				if len(m.filteredTracks) == 0 {
+					m.state = stateViewingAlbumTracks
+				} else {
+					m.openFilteredTracks()
+				}
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.filteredList, cmd = m.filteredList.Update(msg)
+	return m, cmd
+}
+
+func (m model) viewFilteredTracks() string {
+	return docStyle.Render(
+		lipgloss.JoinVertical(lipgloss.Left,
+			m.filteredList.View(),
+			helpStyle.Render("\n  ENTER: Restore to album  •  ESC: Back to tracks"),
+		),
+	)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// downloadChunkSize is the byte range requested per HTTP call while downloading
+const downloadChunkSize = 4 * 1024 * 1024
+
+const (
+	stallTimeout       = 15 * time.Second // No data for this long means the transfer stalled
+	throttleWindow     = 10 * time.Second // How long a chunk may run before its rate is judged
+	minStreamRate      = 24 * 1024        // Bytes per second below which the URL counts as throttled
+	maxStreamRefreshes = 3                // Consecutive URL refreshes without progress before giving up
+)
+
+// pausedDownloadsKey is the state entry listing paused downloads
+const pausedDownloadsKey = "paused_downloads"
+
+// downloadControl lets the UI pause and resume the active download
+type downloadControl struct {
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{}
+}
+
+// toggle flips the paused state and reports whether the download is now paused
+func (c *downloadControl) toggle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		c.paused = false
+		close(c.resume)
+	} else {
+		c.paused = true
+		c.resume = make(chan struct{})
+	}
+	return c.paused
+}
+
+func (c *downloadControl) isPaused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// wait blocks until the download is resumed or ctx is cancelled
+func (c *downloadControl) wait(ctx context.Context) error {
+	c.mu.Lock()
+	ch := c.resume
+	paused := c.paused
+	c.mu.Unlock()
+	if !paused {
+		return nil
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pausedDownload records a partially downloaded track so it can be resumed
+// later in the session or after a restart
+type pausedDownload struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Author   string `json:"author"`
+	Thumb    string `json:"thumb"`
+	TempPath string `json:"temp_path"`
+	Offset   int64  `json:"offset"`
+	Itag     int    `json:"itag,omitempty"` // Stream picked for the download, 0 for the default one
+}
+
+func (p pausedDownload) item() songItem {
+	return songItem{id: p.ID, title: p.Title, author: p.Author, thumb: p.Thumb}
+}
+
+// tempAudioPath returns the partial download file for a track
+func tempAudioPath(id string) string {
+	return "temp_audio_" + id
+}
+
+// downloadTempPath returns the partial file recorded for a paused track so a
+// resumed download continues where it stopped, or a fresh temp path otherwise
+func downloadTempPath(id string) string {
+	for _, p := range loadPausedDownloads() {
+		if p.ID == id {
+			return p.TempPath
+		}
+	}
+	return tempAudioPath(id)
+}
+
+func loadPausedDownloads() []pausedDownload {
+	var paused []pausedDownload
+	loadState(pausedDownloadsKey, &paused)
+	return paused
+}
+
+// savePausedDownload adds or updates the record for a paused track
+func savePausedDownload(p pausedDownload) error {
+	var paused []pausedDownload
+	return updateState(pausedDownloadsKey, &paused, func() {
+		for i, existing := range paused {
+			if existing.ID == p.ID {
+				paused[i] = p
+				return
+			}
+		}
+		paused = append(paused, p)
+	})
+}
+
+// removePausedDownload forgets a track once it is resumed or finished
+func removePausedDownload(id string) error {
+	var paused []pausedDownload
+	return updateState(pausedDownloadsKey, &paused, func() {
+		paused = slices.DeleteFunc(paused, func(p pausedDownload) bool { return p.ID == id })
+	})
+}
+
+// cancelDownload calls the running download off for good with Esc and goes
+// back to the list it was started from. The download removes its own
+// partial files, see downloadCancelled.
+func (m *model) cancelDownload() {
+	downloadJob.stop()
+	if m.download.isPaused() {
+		m.download.toggle()
+	}
+	m.cooldownUntil = time.Time{}
+	if m.state == stateDownloadingAlbum {
+		m.albumResume = nil // Esc forgets the album's progress record
+	}
+	switch {
+	case m.state == stateDownloadingAlbum && m.albumTrackList.Width() > 0:
+		m.state = stateViewingAlbumTracks
+	case m.state != stateDownloadingAlbum && len(m.list.Items()) > 0:
+		m.state = stateSelecting
+	default:
+		m.state = stateInput
+	}
+	m.notice = "Download cancelled"
+}
+
+// downloadCancelled reports whether the download running under ctx was called
+// off. Called off with Esc rather than by quitting, its partial files at paths
+// and the resume record of track id are removed; a quit keeps them so the
+// download can carry on later.
+func downloadCancelled(ctx context.Context, id string, paths ...string) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	if appCtx.Err() == nil {
+		for _, path := range paths {
+			os.Remove(path)
+		}
+		removePausedDownload(id)
+	}
+	return true
+}
+
+// newPausedDownload builds the resume record for the selected track
+func newPausedDownload(item songItem, offset int64, itag int) pausedDownload {
+	tempPath, err := filepath.Abs(downloadTempPath(item.id))
+	if err != nil {
+		tempPath = downloadTempPath(item.id)
+	}
+	return pausedDownload{
+		ID:       item.id,
+		Title:    item.title,
+		Author:   item.author,
+		Thumb:    item.thumb,
+		TempPath: tempPath,
+		Offset:   offset,
+		Itag:     itag,
+	}
+}
+
+// staleStreamError means the stream URL stopped delivering (expired, throttled
+// or stalled) and a fresh one should be resolved
+type staleStreamError struct {
+	reason string
+}
+
+func (e *staleStreamError) Error() string {
+	return "stream interrupted: " + e.reason
+}
+
+// refreshStreamURL re-resolves the video and returns a new URL for the same format
+func refreshStreamURL(ctx context.Context, client youtube.Client, video *youtube.Video, format *youtube.Format) (string, error) {
+	fresh, err := client.GetVideoContext(ctx, video.ID)
+	if err != nil {
+		return "", err
+	}
+	for i := range fresh.Formats {
+		if fresh.Formats[i].ItagNo == format.ItagNo {
+			return client.GetStreamURLContext(ctx, fresh, &fresh.Formats[i])
+		}
+	}
+	return "", fmt.Errorf("format %d is no longer offered", format.ItagNo)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// friendlyError is what the error screen shows: a short title and an
+// actionable hint, with the raw error kept for reference
+type friendlyError struct {
+	title string
+	hint  string
+	err   error
+}
+
+// classifyError maps common library, network and tool failures to specific messages
+func classifyError(err error) friendlyError {
+	fe := friendlyError{title: "Error", err: err}
+	if err == nil {
+		return fe
+	}
+	msg := strings.ToLower(err.Error())
+
+	var playability youtube.ErrPlayabiltyStatus
+	var status youtube.ErrUnexpectedStatusCode
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, exec.ErrNotFound) || strings.Contains(msg, "executable file not found"):
+		fe.title = "FFmpeg not found"
+		fe.hint = "gomusic needs ffmpeg and ffprobe on your PATH. Install FFmpeg (e.g. `sudo pacman -S ffmpeg`, `brew install ffmpeg`) and try again."
+	case errors.Is(err, youtube.ErrLoginRequired) || strings.Contains(msg, "confirm your age"):
+		fe.title = "Age-restricted video"
+		fe.hint = "YouTube requires sign-in to play this upload. Try another version of the song from the search results."
+	case errors.Is(err, youtube.ErrVideoPrivate) || strings.Contains(msg, "private"):
+		fe.title = "Private video"
+		fe.hint = "The uploader restricted access to this video. Pick a different result."
+	case errors.As(err, &playability):
+		fe.title, fe.hint = classifyPlayability(playability)
+	case errors.Is(err, youtube.ErrNotPlayableInEmbed):
+		fe.title = "Playback disabled by uploader"
+		fe.hint = "This video can't be played outside YouTube. Try another upload of the same track."
+	case errors.Is(err, youtube.ErrCipherNotFound) || errors.Is(err, youtube.ErrSignatureTimestampNotFound) ||
+		strings.Contains(msg, "decipher") || strings.Contains(msg, "cipher"):
+		fe.title = "Stream extraction failed"
+		fe.hint = "YouTube changed its player and the stream URL couldn't be decoded. Updating gomusic usually fixes this."
+	case errors.As(err, &status) && status == 429:
+		fe.title = "Rate limited by YouTube"
+		fe.hint = "Too many requests in a short time (HTTP 429). Wait a few minutes before trying again."
+	case errors.As(err, &status) && status == 403:
+		fe.title = "Stream access denied"
+		fe.hint = "YouTube rejected the stream URL (HTTP 403). It may have expired; try again."
+	case errors.As(err, &netErr) && netErr.Timeout():
+		fe.title = "Network timeout"
+		fe.hint = "The connection timed out. Check your internet connection and try again."
+	case errors.As(err, new(*net.OpError)) || errors.As(err, new(*net.DNSError)):
+		fe.title = "Network error"
+		fe.hint = "Couldn't reach YouTube. Check your internet connection."
+	}
+	return fe
+}
+
+// classifyPlayability interprets YouTube's playability status and reason
+func classifyPlayability(p youtube.ErrPlayabiltyStatus) (string, string) {
+	reason := strings.ToLower(p.Reason)
+	switch {
+	case strings.Contains(reason, "country") || strings.Contains(reason, "region"):
+		return "Not available in your region", "The uploader blocked this video in your country. Try another upload of the same song."
+	case strings.Contains(reason, "age") || p.Status == "LOGIN_REQUIRED":
+		return "Age-restricted video", "YouTube requires sign-in to play this upload. Try another version of the song."
+	case strings.Contains(reason, "private"):
+		return "Private video", "The uploader restricted access to this video. Pick a different result."
+	case strings.Contains(reason, "removed") || strings.Contains(reason, "deleted") ||
+		strings.Contains(reason, "terminated") || strings.Contains(reason, "unavailable"):
+		return "Video unavailable", "This video was removed or deleted. Pick a different result."
+	}
+	return "Video can't be played", p.Reason
+}
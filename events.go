@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Background work (streams, downloads, art and lyric lookups) never writes
+// model or playback state itself. It reports what it learned as a typed event
+// through m.program.Send and Update applies it on the UI goroutine. Events
+// about a track carry its key so results for a track that has since been
+// skipped are dropped instead of landing on the next one; cover and lyric
+// lookups carry the playback session instead, see session.go.
+
+// streamStartedMsg reports that audio for the track with key is flowing
+type streamStartedMsg struct {
+	key       string
+	title     string        // "Title - Artist" as shown while playing
+	health    *streamHealth // nil when the backend has no stream buffer to watch
+	audioInfo string
+	exclusive bool // Played bit-perfect, so the volume can't be changed
+}
+
+// coverReadyMsg carries the album art for the track with key, which the
+// now-playing screen draws as colorized ASCII art sized to the terminal, plus
+// a resized copy on terminals that can display images
+type coverReadyMsg struct {
+	session   uint64 // Playback session the art was looked up for
+	key       string
+	coverPath string
+}
+
+// songTitle formats a track the way the playing screen names it
+func songTitle(title, author string) string {
+	if author == "" {
+		return title
+	}
+	return fmt.Sprintf("%s - %s", title, author)
+}
+
+// resetTrack clears everything shown about the previous track before a new
+// one starts loading
+func (p *playbackState) resetTrack() {
+	clearKittyImages()
+	p.isPaused = false
+	p.lyrics = nil
+	p.lyricsUnsynced = false
+	p.currentLyricIndex = -1
+	p.albumCover = ""
+	p.artCols, p.artRows = 0, 0
+	p.coverPath = ""
+	p.kittyImage = ""
+	p.resizedCoverPath = ""
+	p.health = nil
+	p.audioInfo = ""
+	p.exclusive = false
+	p.position = 0
+	p.waveform = nil
+	p.info, p.infoErr, p.infoKey = nil, nil, ""
+	p.theme = nil
+}
+
+// applyStreamStarted shows the stream details of the current track
+func (m *model) applyStreamStarted(msg streamStartedMsg) {
+	if msg.key != m.playback.trackKey {
+		return
+	}
+	m.playback.health = msg.health
+	m.playback.audioInfo = msg.audioInfo
+	m.playback.exclusive = msg.exclusive
+	if msg.title != "" {
+		m.playback.playingSong = msg.title
+	}
+}
+
+// applyCover shows the album art of the current track, returning the command
+// that draws it for the current terminal size
+func (m *model) applyCover(msg coverReadyMsg) tea.Cmd {
+	if msg.session != m.playback.session {
+		return nil
+	}
+	m.playback.coverPath = msg.coverPath
+	m.playback.artCols, m.playback.artRows = 0, 0
+	return tea.Batch(m.refreshArt(), loadCoverTheme(m.playback.trackKey, msg.coverPath))
+}
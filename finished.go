@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// savedPath returns the absolute path of the finished download, since a
+// name relative to wherever gomusic was started is hard to find again
+func (m model) savedPath() string {
+	if abs, err := filepath.Abs(m.fileName); err == nil {
+		return abs
+	}
+	return m.fileName
+}
+
+// revealInFileManager opens the folder holding path in the system file
+// manager, with path selected where the file manager supports it
+func revealInFileManager(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", "-R", path)
+	case "windows":
+		cmd = exec.Command("explorer", "/select,"+path)
+	default:
+		cmd = exec.Command("xdg-open", filepath.Dir(path))
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait() // File managers keep running; just reap the launcher
+	return nil
+}
+
+func (m model) updateFinished(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "o":
+		if err := revealInFileManager(m.savedPath()); err != nil {
+			m.notice = "Couldn't open the file manager: " + err.Error()
+		}
+	case "f":
+		if len(m.albumGaps) > 0 {
+			m.state = stateFillGaps
+		}
+	case "enter", "esc":
+		m.fileName, m.savedDetail = "", ""
+		m.albumGaps = nil
+		m.textInput.SetValue("")
+		m.textInput.Focus()
+		m.state = stateInput
+	case "q":
+		m.quitting = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m model) viewFinished() string {
+	detail := ""
+	if m.savedDetail != "" {
+		detail = "\n  " + m.savedDetail
+	}
+	help := "O: Open Folder  •  ENTER: New Search  •  Q: Quit"
+	if len(m.albumGaps) > 0 {
+		help = "F: Fill Missing Tracks  •  " + help
+	}
+	return fmt.Sprintf("\n  %s\n\n  %s %s%s\n\n  %s%s\n",
+		titleStyle.Render("Success! Enjoy your music."),
+		statusStyle.Render("Saved:"), m.savedPath(),
+		detail,
+		helpStyle.Render(help),
+		m.renderNotice(),
+	)
+}
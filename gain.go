@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
+)
+
+// quietHoursConfig caps the volume playback starts at during part of the day
+type quietHoursConfig struct {
+	Start  string `toml:"start,omitempty"` // "22:00"; quiet hours may run past midnight
+	End    string `toml:"end,omitempty"`   // "07:00"
+	Volume int    `toml:"volume,omitzero"` // Cap in percent of full volume, e.g. 40
+}
+
+func (c quietHoursConfig) validate() error {
+	if c.Start == "" && c.End == "" {
+		return nil
+	}
+	start, err := parseClock(c.Start)
+	if err != nil {
+		return fmt.Errorf("quiet_hours: start: %v", err)
+	}
+	end, err := parseClock(c.End)
+	if err != nil {
+		return fmt.Errorf("quiet_hours: end: %v", err)
+	}
+	if start == end {
+		return fmt.Errorf("quiet_hours: start and end are both %s", c.Start)
+	}
+	if c.Volume < 1 || c.Volume > 100 {
+		return fmt.Errorf("quiet_hours: volume must be 1 to 100 percent, got %d", c.Volume)
+	}
+	return nil
+}
+
+// parseClock reads a "HH:MM" time of day as the time since midnight
+func parseClock(s string) (time.Duration, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hours, herr := strconv.Atoi(h)
+	minutes, merr := strconv.Atoi(m)
+	if !ok || herr != nil || merr != nil || hours < 0 || hours > 23 || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", s)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// cap returns the volume cap in force at now, 1 outside quiet hours
+func (c quietHoursConfig) cap(now time.Time) float64 {
+	start, err1 := parseClock(c.Start)
+	end, err2 := parseClock(c.End)
+	if err1 != nil || err2 != nil || c.Volume == 0 {
+		return 1
+	}
+	t := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+	quiet := start <= t && t < end
+	if start > end { // Past midnight, like 22:00 to 07:00
+		quiet = t >= start || t < end
+	}
+	if !quiet {
+		return 1
+	}
+	return float64(c.Volume) / 100
+}
+
+// volumeKey is the state entry keeping the volume set with the volume keys across runs
+const volumeKey = "volume"
+
+// volumeStep is how far one press of the volume keys moves the level, in percent
+const volumeStep = 5
+
+// volumeLevel is the volume chosen with the volume keys
+type volumeLevel struct {
+	Percent int  `json:"percent"`
+	Muted   bool `json:"muted"`
+}
+
+// loadVolume returns the saved volume, full volume if none was saved
+func loadVolume() volumeLevel {
+	v := volumeLevel{Percent: 100}
+	loadState(volumeKey, &v)
+	v.Percent = max(0, min(v.Percent, 100))
+	return v
+}
+
+// playbackGain is the gain new streams and mpv processes start at, as
+// float64 bits; 0 while muted
+var playbackGain atomic.Uint64
+
+func init() {
+	setGain(1)
+}
+
+// setGain records the gain of the playing audio, 1 for full volume
+func setGain(g float64) {
+	playbackGain.Store(math.Float64bits(g))
+}
+
+// currentGain returns the gain set with setGain
+func currentGain() float64 {
+	return math.Float64frombits(playbackGain.Load())
+}
+
+// startVolume sets the level a track starts at: the saved volume, lowered to
+// the quiet hours cap while they last. It returns the cap when it lowered the
+// level, 1 otherwise.
+func (m *model) startVolume() float64 {
+	m.playback.level = m.volume.Percent
+	quietCap := cfg.QuietHours.cap(time.Now())
+	if capped := int(math.Round(quietCap * 100)); capped < m.playback.level {
+		m.playback.level = capped
+	} else {
+		quietCap = 1
+	}
+	setGain(m.gain())
+	return quietCap
+}
+
+// gain is the gain the current level and mute state come to
+func (m *model) gain() float64 {
+	if m.volume.Muted {
+		return 0
+	}
+	return float64(m.playback.level) / 100
+}
+
+// changeVolume moves the level by delta percent and saves it for later runs
+func (m *model) changeVolume(delta int) {
+	if m.refuseExclusiveVolume() {
+		return
+	}
+	m.playback.level = max(0, min(m.playback.level+delta, 100))
+	m.playback.quietCap = 1 // Chosen by hand, even during quiet hours
+	m.volume = volumeLevel{Percent: m.playback.level}
+	m.applyVolume()
+}
+
+// toggleMute silences playback or brings it back at the same level
+func (m *model) toggleMute() {
+	if m.refuseExclusiveVolume() {
+		return
+	}
+	m.volume.Muted = !m.volume.Muted
+	m.applyVolume()
+}
+
+// refuseExclusiveVolume reports whether the track plays bit-perfect, where mpv
+// holds the volume at 100%, and says so instead of changing it
+func (m *model) refuseExclusiveVolume() bool {
+	if !m.playback.exclusive {
+		return false
+	}
+	m.notice = "Bit-perfect playback: the volume stays at 100% for this track"
+	return true
+}
+
+// applyVolume hands the level to the player and saves it
+func (m *model) applyVolume() {
+	setGain(m.gain())
+	player.SetVolume(m, m.gain())
+	if err := saveState(volumeKey, m.volume); err != nil {
+		m.notice = "Couldn't save the volume: " + err.Error()
+	}
+}
+
+// renderVolume shows the level on the playing screen
+func (m *model) renderVolume() string {
+	switch {
+	case m.playback.exclusive:
+		return "Volume: bit-perfect"
+	case m.volume.Muted:
+		return "Volume: muted"
+	case m.playback.quietCap < 1:
+		return fmt.Sprintf("Volume: %d%% (quiet hours cap)", m.playback.level)
+	}
+	return fmt.Sprintf("Volume: %d%%", m.playback.level)
+}
+
+// volumeStage wraps s in beep's volume effect at gain g
+func volumeStage(s beep.Streamer, g float64) *effects.Volume {
+	v := &effects.Volume{Streamer: s, Base: 2}
+	setVolumeStage(v, g)
+	return v
+}
+
+// setVolumeStage sets v to gain g. The caller holds the speaker lock if v plays.
+func setVolumeStage(v *effects.Volume, g float64) {
+	v.Silent = g <= 0
+	if !v.Silent {
+		v.Volume = math.Log2(g)
+	}
+}
+
+// mpvGainArgs starts mpv at the current gain
+func mpvGainArgs() []string {
+	if g := currentGain(); g < 1 {
+		return []string{"--volume=" + strconv.Itoa(int(math.Round(g*100)))}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+//go:build !noplayback
+
+package main
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"sync"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/mp3"
+)
+
+// localTrack is a downloaded file decoded through ffmpeg
+type localTrack struct {
+	item    songItem
+	cmd     *exec.Cmd
+	health  *streamHealth
+	decoder beep.StreamSeekCloser
+}
+
+// openLocalTrack starts decoding a file from disk into the speaker format.
+// Cancelling ctx kills the decoder.
+func openLocalTrack(ctx context.Context, item songItem) (*localTrack, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-i", item.path,
+		"-loglevel", "error",
+		"-vn", "-c:a", "libmp3lame", "-q:a", handoffMP3Quality,
+		"-ar", rateArg(),
+		"-ac", "2",
+		"-f", "mp3",
+		"pipe:1",
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	health := &streamHealth{buffer: newStreamBuffer(stdout)}
+	decoder, _, err := mp3.Decode(io.NopCloser(health.buffer))
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	go cmd.Wait()
+	return &localTrack{item: item, cmd: cmd, health: health, decoder: decoder}, nil
+}
+
+func (t *localTrack) close() {
+	if t.cmd.Process != nil {
+		t.cmd.Process.Kill()
+	}
+	t.health.buffer.Close()
+	t.decoder.Close()
+}
+
+// gaplessStreamer plays local tracks back to back. The next track is opened
+// while the current one plays and swapped in on the sample the current one
+// ends, so there is no silence between album tracks.
+type gaplessStreamer struct {
+	mu       sync.Mutex
+	current  *localTrack
+	next     *localTrack
+	advanced chan *localTrack
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func newGaplessStreamer(first *localTrack) *gaplessStreamer {
+	return &gaplessStreamer{
+		current:  first,
+		advanced: make(chan *localTrack, 1),
+		closed:   make(chan struct{}),
+	}
+}
+
+// queueNext sets the track to continue with once the current one ends
+func (g *gaplessStreamer) queueNext(t *localTrack) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.closed:
+		t.close()
+	default:
+		g.next = t
+	}
+}
+
+func (g *gaplessStreamer) Stream(samples [][2]float64) (int, bool) {
+	select {
+	case <-g.closed:
+		// Stopped: play silence rather than ending the sequence, which would look like the queue finished
+		for i := range samples {
+			samples[i] = [2]float64{}
+		}
+		return len(samples), true
+	default:
+	}
+	filled := 0
+	for filled < len(samples) {
+		n, ok := g.current.decoder.Stream(samples[filled:])
+		filled += n
+		if ok && filled == len(samples) {
+			break
+		}
+
+		// The current track is drained: continue with the next one in the same buffer
+		g.mu.Lock()
+		next := g.next
+		g.next = nil
+		g.mu.Unlock()
+		if next == nil {
+			return filled, filled > 0
+		}
+		g.current.close()
+		g.current = next
+		select {
+		case g.advanced <- next:
+		default:
+		}
+	}
+	return filled, true
+}
+
+func (g *gaplessStreamer) Err() error {
+	return g.current.decoder.Err()
+}
+
+func (g *gaplessStreamer) Len() int {
+	return g.current.decoder.Len()
+}
+
+func (g *gaplessStreamer) Position() int {
+	return g.current.decoder.Position()
+}
+
+func (g *gaplessStreamer) Seek(p int) error {
+	return g.current.decoder.Seek(p)
+}
+
+// Close stops decoding of the current and any preloaded track
+func (g *gaplessStreamer) Close() error {
+	g.once.Do(func() {
+		g.mu.Lock()
+		close(g.closed)
+		if g.next != nil {
+			g.next.close()
+			g.next = nil
+		}
+		g.mu.Unlock()
+		audioOut.Lock()
+		g.current.close()
+		audioOut.Unlock()
+	})
+	return nil
+}
+
+// runLocalPlayback plays a downloaded file and the local tracks queued after
+// it without touching the network
+func (m *model) runLocalPlayback(ctx context.Context, item songItem) {
+	var upcoming []songItem
+	for i := m.queue.index + 1; i < m.queue.len() && m.queue.tracks[i].path != ""; i++ {
+		upcoming = append(upcoming, m.queue.tracks[i])
+	}
+
+	first, err := openLocalTrack(ctx, item)
+	if err != nil {
+		m.playbackFailed(ctx, err)
+		return
+	}
+	chain := newGaplessStreamer(first)
+	ctrl := &beep.Ctrl{Streamer: chain, Paused: false}
+
+	volume := volumeStage(ctrl, currentGain())
+	builtin.set(ctrl, volume, func() { chain.Close() }, nil)
+	m.showLocalTrack(ctx, first)
+	m.program.Send(playMsg{title: item.title, author: item.author})
+
+	preload := func() {
+		if len(upcoming) == 0 {
+			return
+		}
+		next := upcoming[0]
+		upcoming = upcoming[1:]
+		go func() {
+			if t, err := openLocalTrack(ctx, next); err == nil {
+				chain.queueNext(t)
+			}
+		}()
+	}
+	preload()
+
+	done := make(chan bool, 1)
+	audioOut.Play(beep.Seq(channelMixer{volume}, beep.Callback(func() {
+		done <- true
+	})))
+
+	for {
+		select {
+		case t := <-chain.advanced:
+			trackCtx, session := newPlaybackSession(ctx)
+			m.program.Send(trackAdvancedMsg{item: t.item, session: session})
+			m.showLocalTrack(trackCtx, t)
+			preload()
+		case <-done:
+			m.program.Send(stopMsg{})
+			return
+		case <-chain.closed:
+			return
+		case <-ctx.Done():
+			chain.Close()
+			return
+		}
+	}
+}
+
+// showLocalTrack reports t as the now-playing track and loads its sidecar
+// lyrics and embedded art
+func (m *model) showLocalTrack(ctx context.Context, t *localTrack) {
+	key := localCoverKey(t.item.path)
+	started := streamStartedMsg{key: key, title: songTitle(t.item.title, t.item.author), health: t.health}
+	if info, err := probeAudioInfo(t.item.path); err == nil {
+		started.audioInfo = info.String()
+	}
+	m.program.Send(started)
+	m.fetchTrackExtras(ctx, t.item)
+}
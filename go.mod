@@ -3,12 +3,14 @@ module github.com/iiTzDante/gomusic
 go 1.25.5
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/charmbracelet/bubbles v0.21.0
 	github.com/charmbracelet/bubbletea v1.3.10
 	github.com/charmbracelet/lipgloss v1.1.0
 	github.com/faiface/beep v1.1.0
 	github.com/kkdai/youtube/v2 v2.10.5
 	github.com/raitonoberu/ytmusic v0.0.0-20240324143733-0e5780514b1d
+	go.etcd.io/bbolt v1.4.3
 )
 
 require (
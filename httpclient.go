@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// defaultStreamUserAgent is what ffmpeg sends for playback streams unless
+// network.user_agent is set, so YouTube doesn't throttle or close them
+const defaultStreamUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+
+// apiClient makes the short metadata, lyric and cover requests. Streams and
+// downloads go through http.DefaultClient, whose transport shares the same
+// connect timeouts, retries and pool but sets no overall deadline.
+var apiClient = &http.Client{Timeout: 15 * time.Second}
+
+// retryTransport sends the configured user agent and retries requests that
+// failed to connect or got a 5xx reply
+type retryTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	retries   int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	// A body that can't be replayed rules out a second attempt
+	retries := t.retries
+	if req.Body != nil && req.GetBody == nil {
+		retries = 0
+	}
+
+	backoff := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt == retries || !retryable(resp, err) || req.Context().Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(backoff):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		backoff *= 2
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryable reports whether a failed round trip is worth another attempt
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// streamUserAgent is the user agent ffmpeg sends when fetching streams itself
+func streamUserAgent() string {
+	if cfg.Network.UserAgent != "" {
+		return cfg.Network.UserAgent
+	}
+	return defaultStreamUserAgent
+}
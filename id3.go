@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// id3Keys maps the ID3v2 text frames the library uses to ffprobe's tag names
+var id3Keys = map[string]string{
+	"TIT2": "title",
+	"TPE1": "artist",
+	"TPE2": "album_artist",
+	"TALB": "album",
+	"TRCK": "track",
+}
+
+// readID3Tags reads the text tags of an MP3's ID3v2.3 or 2.4 tag straight
+// from the file, keyed like probeTags. It is much faster than running
+// ffprobe, which matters when indexing thousands of files. TXXX frames are
+// keyed by their lower-cased description.
+func readID3Tags(path string) (map[string]string, error) {
+	tags := map[string]string{}
+	err := walkID3Frames(path, func(id string, body []byte) {
+		switch {
+		case id == "TXXX":
+			if desc, value, ok := strings.Cut(decodeID3Text(body), "\x00"); ok {
+				tags[strings.ToLower(desc)] = value
+			}
+		case id3Keys[id] != "":
+			tags[id3Keys[id]] = decodeID3Text(body)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// walkID3Frames calls visit with the ID and body of each frame of an MP3's
+// ID3v2.3 or 2.4 tag
+func walkID3Frames(path string, visit func(id string, body []byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return err
+	}
+	if string(header[:3]) != "ID3" {
+		return fmt.Errorf("%s has no ID3v2 tag", path)
+	}
+	version, flags := header[3], header[5]
+	if version != 3 && version != 4 {
+		return fmt.Errorf("%s: ID3v2.%d tags are not supported", path, version)
+	}
+	if flags&0x80 != 0 {
+		return fmt.Errorf("%s: unsynchronised ID3v2 tags are not supported", path)
+	}
+	tag := make([]byte, syncsafe(header[6:10]))
+	if _, err := io.ReadFull(f, tag); err != nil {
+		return err
+	}
+	if flags&0x40 != 0 && len(tag) >= 4 {
+		// Skip the extended header; its size counts itself in 2.4 only
+		size := int(binary.BigEndian.Uint32(tag[:4])) + 4
+		if version == 4 {
+			size = syncsafe(tag[:4])
+		}
+		tag = tag[min(size, len(tag)):]
+	}
+
+	for len(tag) >= 10 && tag[0] != 0 {
+		id := string(tag[:4])
+		size := int(binary.BigEndian.Uint32(tag[4:8]))
+		if version == 4 {
+			size = syncsafe(tag[4:8])
+		}
+		if size > len(tag)-10 {
+			break
+		}
+		body := tag[10 : 10+size]
+		tag = tag[10+size:]
+		visit(id, body)
+	}
+	return nil
+}
+
+// decodeID3Text decodes a text frame body: an encoding byte, then Latin-1,
+// UTF-16 with BOM, UTF-16BE or UTF-8 text. Trailing terminators are dropped
+// and inner ones become "\x00".
+func decodeID3Text(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	enc, b := b[0], b[1:]
+	var s string
+	switch enc {
+	case 0:
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		s = string(runes)
+	case 1, 2:
+		order := binary.ByteOrder(binary.BigEndian)
+		units := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			switch {
+			case enc == 1 && b[i] == 0xff && b[i+1] == 0xfe:
+				order = binary.LittleEndian
+			case enc == 1 && b[i] == 0xfe && b[i+1] == 0xff:
+				order = binary.BigEndian
+			default:
+				units = append(units, order.Uint16(b[i:]))
+			}
+		}
+		s = string(utf16.Decode(units))
+	default:
+		s = string(b)
+	}
+	return strings.TrimRight(s, "\x00")
+}
+
+// readID3Lyrics reads the lyrics embedded in an MP3's ID3v2 tag: the lines of
+// a SYLT frame with millisecond timestamps, and the text of a USLT frame,
+// which may itself be LRC
+func readID3Lyrics(path string) (synced []LyricLine, unsynced string, err error) {
+	err = walkID3Frames(path, func(id string, body []byte) {
+		switch {
+		case id == "SYLT" && synced == nil && len(body) > 6 && body[4] == 2:
+			enc, b := body[0], body[6:]
+			_, b = cutID3String(enc, b) // Content descriptor
+			for len(b) > 0 {
+				var text string
+				text, b = cutID3String(enc, b)
+				if len(b) < 4 {
+					break
+				}
+				ms := binary.BigEndian.Uint32(b[:4])
+				b = b[4:]
+				synced = append(synced, LyricLine{
+					Timestamp: time.Duration(ms) * time.Millisecond,
+					Text:      strings.TrimSpace(text),
+				})
+			}
+		case id == "USLT" && unsynced == "" && len(body) > 4:
+			// The encoding, then past the language a descriptor and the text
+			text := decodeID3Text(append([]byte{body[0]}, body[4:]...))
+			if _, lyrics, ok := strings.Cut(text, "\x00"); ok {
+				unsynced = lyrics
+			}
+		}
+	})
+	sort.SliceStable(synced, func(i, j int) bool { return synced[i].Timestamp < synced[j].Timestamp })
+	return synced, unsynced, err
+}
+
+// cutID3String splits a terminated string in encoding enc off the front of b
+func cutID3String(enc byte, b []byte) (string, []byte) {
+	if enc == 1 || enc == 2 {
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return decodeID3Text(append([]byte{enc}, b[:i]...)), b[i+2:]
+			}
+		}
+	} else if i := bytes.IndexByte(b, 0); i >= 0 {
+		return decodeID3Text(append([]byte{enc}, b[:i]...)), b[i+1:]
+	}
+	return decodeID3Text(append([]byte{enc}, b...)), nil
+}
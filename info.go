@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxInfoLines caps the info panel so the player still fits the terminal
+const maxInfoLines = 8
+
+// infoRetryAfter is how long a track without any description is left alone
+// before the lookup is tried again
+const infoRetryAfter = 7 * 24 * time.Hour
+
+// infoConfig chooses where the playback info panel gets its text
+type infoConfig struct {
+	GeniusToken string `toml:"genius_token,omitempty"` // Genius API client access token; song descriptions are tried there first when set
+	Language    string `toml:"language,omitempty"`     // Wikipedia language edition, "en" if not set
+}
+
+func (c infoConfig) validate() error {
+	for _, r := range c.Language {
+		if (r < 'a' || r > 'z') && r != '-' {
+			return fmt.Errorf("info: language must be a Wikipedia code like \"en\" or \"pt\", got %q", c.Language)
+		}
+	}
+	return nil
+}
+
+// wikipedia returns the base URL of the configured Wikipedia edition
+func (c infoConfig) wikipedia() string {
+	lang := c.Language
+	if lang == "" {
+		lang = "en"
+	}
+	return "https://" + lang + ".wikipedia.org"
+}
+
+// trackInfo is a short description of a track or its artist, cached as JSON
+type trackInfo struct {
+	Heading string    `json:"heading"` // Page the text was taken from
+	Text    string    `json:"text"`    // Empty when nothing was found
+	Source  string    `json:"source"`  // "Genius" or "Wikipedia"
+	URL     string    `json:"url"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// trackInfoMsg delivers the info panel text for the track with key
+type trackInfoMsg struct {
+	key  string
+	info trackInfo
+	err  error
+}
+
+// infoCachePath returns where the info for key is cached
+func infoCachePath(key string) (string, error) {
+	dir, err := cacheDir("info")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, sanitizeFilename(key)+".json"), nil
+}
+
+// fetchTrackInfo looks up a description of item, from the cache when it has one
+func fetchTrackInfo(ctx context.Context, key string, item songItem) tea.Cmd {
+	return func() tea.Msg {
+		path, cacheErr := infoCachePath(key)
+		if cacheErr == nil {
+			var info trackInfo
+			if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &info) == nil {
+				if info.Text != "" || time.Since(info.Fetched) < infoRetryAfter {
+					return trackInfoMsg{key: key, info: info}
+				}
+			}
+		}
+		if offlineMode {
+			return trackInfoMsg{key: key, err: fmt.Errorf("not cached and offline")}
+		}
+
+		title := strings.TrimSpace(stripTitleNoise(item.title))
+		artist := primaryArtist(item.author)
+		info, err := lookupTrackInfo(ctx, title, artist)
+		if err != nil {
+			return trackInfoMsg{key: key, err: err}
+		}
+		info.Fetched = time.Now()
+		if cacheErr == nil {
+			if data, err := json.Marshal(info); err == nil {
+				os.WriteFile(path, data, 0644)
+			}
+		}
+		return trackInfoMsg{key: key, info: info}
+	}
+}
+
+// lookupTrackInfo tries Genius when a token is configured, then Wikipedia's
+// article on the song, then the one on the artist
+func lookupTrackInfo(ctx context.Context, title, artist string) (trackInfo, error) {
+	if cfg.Info.GeniusToken != "" {
+		if info, err := geniusDescription(ctx, title, artist); err == nil && info.Text != "" {
+			return info, nil
+		}
+	}
+	info, err := wikipediaSummary(ctx, fmt.Sprintf("%q %s song", title, artist), title)
+	if err != nil || info.Text != "" || artist == "" {
+		return info, err
+	}
+	return wikipediaSummary(ctx, artist+" musician", artist)
+}
+
+// wikipediaSummary returns the lead of the best search result for query,
+// provided its title mentions want
+func wikipediaSummary(ctx context.Context, query, want string) (trackInfo, error) {
+	params := url.Values{}
+	params.Set("action", "query")
+	params.Set("list", "search")
+	params.Set("srsearch", query)
+	params.Set("srlimit", "3")
+	params.Set("format", "json")
+	var search struct {
+		Query struct {
+			Search []struct {
+				Title string `json:"title"`
+			} `json:"search"`
+		} `json:"query"`
+	}
+	if err := getInfoJSON(ctx, cfg.Info.wikipedia()+"/w/api.php?"+params.Encode(), "", &search); err != nil {
+		return trackInfo{}, err
+	}
+	for _, result := range search.Query.Search {
+		if !strings.Contains(strings.ToLower(result.Title), strings.ToLower(want)) {
+			continue
+		}
+		var summary struct {
+			Type        string `json:"type"`
+			Title       string `json:"title"`
+			Extract     string `json:"extract"`
+			ContentURLs struct {
+				Desktop struct {
+					Page string `json:"page"`
+				} `json:"desktop"`
+			} `json:"content_urls"`
+		}
+		page := url.PathEscape(strings.ReplaceAll(result.Title, " ", "_"))
+		if err := getInfoJSON(ctx, cfg.Info.wikipedia()+"/api/rest_v1/page/summary/"+page, "", &summary); err != nil {
+			return trackInfo{}, err
+		}
+		if summary.Type == "disambiguation" {
+			continue
+		}
+		return trackInfo{Heading: summary.Title, Text: summary.Extract, Source: "Wikipedia", URL: summary.ContentURLs.Desktop.Page}, nil
+	}
+	return trackInfo{}, nil
+}
+
+// geniusDescription returns the Genius description of the song by artist
+func geniusDescription(ctx context.Context, title, artist string) (trackInfo, error) {
+	var search struct {
+		Response struct {
+			Hits []struct {
+				Result struct {
+					ID            int    `json:"id"`
+					FullTitle     string `json:"full_title"`
+					PrimaryArtist struct {
+						Name string `json:"name"`
+					} `json:"primary_artist"`
+				} `json:"result"`
+			} `json:"hits"`
+		} `json:"response"`
+	}
+	query := url.Values{"q": {title + " " + artist}}
+	if err := getInfoJSON(ctx, "https://api.genius.com/search?"+query.Encode(), cfg.Info.GeniusToken, &search); err != nil {
+		return trackInfo{}, err
+	}
+	for _, hit := range search.Response.Hits {
+		if !strings.Contains(strings.ToLower(hit.Result.PrimaryArtist.Name), strings.ToLower(artist)) {
+			continue
+		}
+		var song struct {
+			Response struct {
+				Song struct {
+					FullTitle   string `json:"full_title"`
+					URL         string `json:"url"`
+					Description struct {
+						Plain string `json:"plain"`
+					} `json:"description"`
+				} `json:"song"`
+			} `json:"response"`
+		}
+		if err := getInfoJSON(ctx, fmt.Sprintf("https://api.genius.com/songs/%d?text_format=plain", hit.Result.ID), cfg.Info.GeniusToken, &song); err != nil {
+			return trackInfo{}, err
+		}
+		s := song.Response.Song
+		text := strings.TrimSpace(s.Description.Plain)
+		if text == "?" { // Genius's placeholder for songs nobody has described
+			text = ""
+		}
+		return trackInfo{Heading: s.FullTitle, Text: text, Source: "Genius", URL: s.URL}, nil
+	}
+	return trackInfo{}, nil
+}
+
+// getInfoJSON decodes the JSON reply to a GET of u, with token as bearer authorization if set
+func getInfoJSON(ctx context.Context, u, token string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP %d", req.URL.Host, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// toggleInfo switches the player between lyrics and the info panel,
+// looking up the current track the first time it is shown
+func (m *model) toggleInfo() tea.Cmd {
+	m.showInfo = !m.showInfo
+	return m.infoCmd()
+}
+
+// infoCmd fetches the info of the current track if the panel is shown and
+// it isn't loaded or on its way yet
+func (m *model) infoCmd() tea.Cmd {
+	if !m.showInfo || m.playback.trackKey == "" || m.playback.infoKey == m.playback.trackKey {
+		return nil
+	}
+	m.playback.info, m.playback.infoErr = nil, nil
+	m.playback.infoKey = m.playback.trackKey
+	return fetchTrackInfo(playbackJob.context(), m.playback.trackKey, m.selected)
+}
+
+// applyTrackInfo shows the looked up info if it is still for the current track
+func (m *model) applyTrackInfo(msg trackInfoMsg) {
+	if msg.key != m.playback.infoKey {
+		return
+	}
+	m.playback.info, m.playback.infoErr = &msg.info, msg.err
+}
+
+// renderInfo draws the info panel shown instead of the lyrics
+func (m *model) renderInfo() string {
+	info := m.playback.info
+	switch {
+	case m.playback.infoErr != nil:
+		return "  " + errorStyle.Render("Couldn't look up track info: "+m.playback.infoErr.Error())
+	case info == nil:
+		return "  " + helpStyle.Render("Looking up track info...")
+	case info.Text == "":
+		return "  " + helpStyle.Render("No description found for this track.")
+	}
+
+	width := 60
+	if m.width > 0 {
+		width = max(30, min(width, m.width/2))
+	}
+	lines := strings.Split(lipgloss.NewStyle().Width(width).Render(info.Text), "\n")
+	if len(lines) > maxInfoLines {
+		lines = append(lines[:maxInfoLines-1], strings.TrimRight(lines[maxInfoLines-1], " ")+" …")
+	}
+	return fmt.Sprintf("  %s\n  %s\n  %s",
+		statusStyle.Render("About: "+info.Heading),
+		strings.Join(lines, "\n  "),
+		helpStyle.Render(info.Source+" • "+info.URL),
+	)
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// innertubeURL is the YouTube Music web client's API, which the ytmusic
+// package only uses for search
+const innertubeURL = "https://music.youtube.com/youtubei/v1/"
+
+// innertubeClientVersion is the web client version requests claim to come from
+const innertubeClientVersion = "1.20240918.01.00"
+
+// yearPattern finds the release year among the subtitle runs of a header
+var yearPattern = regexp.MustCompile(`^\d{4}$`)
+
+// innertubeRequest posts body to the endpoint of the YouTube Music API and
+// decodes the reply, which is walked with node rather than mapped to types
+// since its layout changes without notice
+func innertubeRequest(ctx context.Context, endpoint string, body map[string]any) (any, error) {
+	body["context"] = map[string]any{
+		"client": map[string]any{
+			"clientName":    "WEB_REMIX",
+			"clientVersion": innertubeClientVersion,
+			"hl":            "en",
+		},
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, innertubeURL+endpoint+"?prettyPrint=false", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "https://music.youtube.com")
+	req.Header.Set("Referer", "https://music.youtube.com/")
+	req.Header.Set("User-Agent", streamUserAgent())
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("YouTube Music %s: %s", endpoint, resp.Status)
+	}
+	var reply any
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, fmt.Errorf("YouTube Music %s: %v", endpoint, err)
+	}
+	return reply, nil
+}
+
+// node follows path, of object keys and array indices, into a decoded reply;
+// nil where it doesn't lead anywhere
+func node(v any, path ...any) any {
+	for _, step := range path {
+		switch step := step.(type) {
+		case string:
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil
+			}
+			v = obj[step]
+		case int:
+			arr, ok := v.([]any)
+			if !ok || step >= len(arr) || step < -len(arr) {
+				return nil
+			}
+			if step < 0 {
+				step += len(arr)
+			}
+			v = arr[step]
+		}
+	}
+	return v
+}
+
+// findNodes collects every value under key anywhere in v, outermost first
+func findNodes(v any, key string) []any {
+	var found []any
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if match, ok := v[key]; ok {
+				found = append(found, match)
+				return
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(v)
+	return found
+}
+
+// nodeString is the string at path, empty if there is none
+func nodeString(v any, path ...any) string {
+	s, _ := node(v, path...).(string)
+	return s
+}
+
+// runs returns the text runs of a text node
+func runs(text any) []any {
+	r, _ := node(text, "runs").([]any)
+	return r
+}
+
+// runsText joins the text runs of a text node
+func runsText(text any) string {
+	var b strings.Builder
+	for _, run := range runs(text) {
+		b.WriteString(nodeString(run, "text"))
+	}
+	return b.String()
+}
+
+// lastThumbnail is the largest thumbnail anywhere in v
+func lastThumbnail(v any) string {
+	for _, thumbs := range findNodes(v, "thumbnails") {
+		if url := nodeString(thumbs, -1, "url"); url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+// parseTrackLength reads a "3:45" or "1:02:03" track length in seconds
+func parseTrackLength(s string) int {
+	total := 0
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + n
+	}
+	return total
+}
+
+// browseAlbum lists the tracks of the album with browseID in album order,
+// with the album's details. Tracks YouTube Music greys out keep their place
+// without an ID, so the others keep their track numbers.
+func browseAlbum(ctx context.Context, browseID string) (songItem, []songItem, error) {
+	reply, err := innertubeRequest(ctx, "browse", map[string]any{"browseId": browseID})
+	if err != nil {
+		return songItem{}, nil, err
+	}
+	return parseAlbumPage(reply, browseID)
+}
+
+// parseAlbumPage reads the album details and tracks from an album page
+func parseAlbumPage(reply any, browseID string) (songItem, []songItem, error) {
+	album := parseBrowseHeader(reply, browseID)
+	var tracks []songItem
+	for _, shelf := range findNodes(reply, "musicShelfRenderer") {
+		items, _ := node(shelf, "contents").([]any)
+		for _, item := range items {
+			if r := node(item, "musicResponsiveListItemRenderer"); r != nil {
+				tracks = append(tracks, albumBrowseTrack(r, album))
+			}
+		}
+	}
+	if len(tracks) == 0 {
+		return album, nil, fmt.Errorf("YouTube Music listed no tracks for album %s", browseID)
+	}
+	album.trackCount = len(tracks)
+	return album, tracks, nil
+}
+
+// parseBrowseHeader reads the title, artist, year and cover from the header
+// of an album or playlist page
+func parseBrowseHeader(reply any, browseID string) songItem {
+	album := songItem{id: browseID, isAlbum: true}
+	var header any
+	if found := findNodes(reply, "musicResponsiveHeaderRenderer"); len(found) > 0 {
+		header = found[0]
+	} else if found := findNodes(reply, "musicDetailHeaderRenderer"); len(found) > 0 {
+		header = found[0]
+	}
+	album.title = runsText(node(header, "title"))
+	for i, run := range runs(node(header, "subtitle")) {
+		text := strings.TrimSpace(nodeString(run, "text"))
+		switch {
+		case i == 0:
+			album.category = text
+		case yearPattern.MatchString(text):
+			album.year = text
+		case nodeString(run, "navigationEndpoint", "browseEndpoint", "browseId") != "" && album.author == "":
+			album.author = text // Older layout: the artist is in the subtitle
+		}
+	}
+	for _, run := range runs(node(header, "straplineTextOne")) {
+		if id := nodeString(run, "navigationEndpoint", "browseEndpoint", "browseId"); id != "" {
+			album.artistIDs = append(album.artistIDs, id)
+		}
+	}
+	if strapline := runsText(node(header, "straplineTextOne")); strapline != "" {
+		album.author = strapline
+	}
+	if album.author == "" {
+		// Playlists show their owner beside the avatars
+		if stack := findNodes(header, "avatarStackViewModel"); len(stack) > 0 {
+			album.author = nodeString(stack[0], "text", "content")
+		}
+	}
+	album.thumb = lastThumbnail(node(header, "thumbnail"))
+	return album
+}
+
+// albumBrowseTrack converts a track row of an album page
+func albumBrowseTrack(r any, album songItem) songItem {
+	title := node(r, "flexColumns", 0, "musicResponsiveListItemFlexColumnRenderer", "text")
+	track := songItem{
+		id:       nodeString(r, "playlistItemData", "videoId"),
+		title:    runsText(title),
+		author:   album.author,
+		album:    album.title,
+		year:     album.year,
+		thumb:    album.thumb,
+		albumID:  album.id,
+		duration: parseTrackLength(runsText(node(r, "fixedColumns", 0, "musicResponsiveListItemFixedColumnRenderer", "text"))),
+	}
+	if track.id == "" {
+		track.id = nodeString(runs(title), 0, "navigationEndpoint", "watchEndpoint", "videoId")
+	}
+	// Featured artists are listed per track; the album artist is left out
+	var artists []string
+	for _, run := range runs(node(r, "flexColumns", 1, "musicResponsiveListItemFlexColumnRenderer", "text")) {
+		if id := nodeString(run, "navigationEndpoint", "browseEndpoint", "browseId"); strings.HasPrefix(id, "UC") {
+			artists = append(artists, nodeString(run, "text"))
+			track.artistIDs = append(track.artistIDs, id)
+		}
+	}
+	if len(artists) > 0 {
+		track.author = strings.Join(artists, ", ")
+	} else {
+		track.artistIDs = album.artistIDs
+	}
+	if track.id == "" {
+		track.title = "⚠️ " + track.title + " (Not available for playback)"
+	}
+	return track
+}
+
+// maxPlaylistPages caps the pages of a playlist that are fetched, about a
+// hundred tracks each
+const maxPlaylistPages = 50
+
+// browsePlaylist lists the tracks of the playlist with browseID ("VL" and the
+// playlist ID) in playlist order, following the continuations of long ones
+func browsePlaylist(ctx context.Context, browseID string) (songItem, []songItem, error) {
+	reply, err := innertubeRequest(ctx, "browse", map[string]any{"browseId": browseID})
+	if err != nil {
+		return songItem{}, nil, err
+	}
+	playlist := parseBrowseHeader(reply, browseID)
+	playlist.category = "Playlist"
+	// The first page has recommendations below the playlist's own shelf
+	page := reply
+	if shelves := findNodes(reply, "musicPlaylistShelfRenderer"); len(shelves) > 0 {
+		page = shelves[0]
+	} else if shelves := findNodes(reply, "musicShelfRenderer"); len(shelves) > 0 {
+		page = shelves[0]
+	}
+	tracks := playlistTracks(page, playlist)
+	token := continuationToken(page)
+	for pages := 1; token != "" && pages < maxPlaylistPages; pages++ {
+		reply, err := innertubeRequest(ctx, "browse", map[string]any{"continuation": token})
+		if err != nil {
+			return playlist, nil, err
+		}
+		tracks = append(tracks, playlistTracks(reply, playlist)...)
+		next := continuationToken(reply)
+		if next == token {
+			break
+		}
+		token = next
+	}
+	if len(tracks) == 0 {
+		return playlist, nil, fmt.Errorf("YouTube Music listed no tracks for playlist %s", browseID)
+	}
+	playlist.trackCount = len(tracks)
+	return playlist, tracks, nil
+}
+
+// continuationToken is the token of the next page of a list, empty on the last
+func continuationToken(v any) string {
+	for _, command := range findNodes(v, "continuationCommand") {
+		if token := nodeString(command, "token"); token != "" {
+			return token
+		}
+	}
+	for _, data := range findNodes(v, "nextContinuationData") {
+		if token := nodeString(data, "continuation"); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// playlistTracks converts the track rows of a page of a playlist
+func playlistTracks(page any, playlist songItem) []songItem {
+	var tracks []songItem
+	for _, r := range findNodes(page, "musicResponsiveListItemRenderer") {
+		tracks = append(tracks, playlistBrowseTrack(r, playlist))
+	}
+	return tracks
+}
+
+// playlistBrowseTrack converts a track row of a playlist page, which unlike
+// an album's names the artist, album and cover of each track
+func playlistBrowseTrack(r any, playlist songItem) songItem {
+	title := node(r, "flexColumns", 0, "musicResponsiveListItemFlexColumnRenderer", "text")
+	track := songItem{
+		id:       nodeString(r, "playlistItemData", "videoId"),
+		title:    runsText(title),
+		thumb:    cmp.Or(lastThumbnail(node(r, "thumbnail")), playlist.thumb),
+		duration: parseTrackLength(runsText(node(r, "fixedColumns", 0, "musicResponsiveListItemFixedColumnRenderer", "text"))),
+	}
+	if track.id == "" {
+		track.id = nodeString(runs(title), 0, "navigationEndpoint", "watchEndpoint", "videoId")
+	}
+	artistRuns := runs(node(r, "flexColumns", 1, "musicResponsiveListItemFlexColumnRenderer", "text"))
+	var artists []string
+	for _, run := range artistRuns {
+		if id := nodeString(run, "navigationEndpoint", "browseEndpoint", "browseId"); strings.HasPrefix(id, "UC") {
+			artists = append(artists, cleanArtistName(nodeString(run, "text")))
+			track.artistIDs = append(track.artistIDs, id)
+		}
+	}
+	if len(artists) == 0 && len(artistRuns) > 0 {
+		artists = []string{cleanArtistName(nodeString(artistRuns, 0, "text"))} // Uploads name an unlinked channel
+	}
+	track.author = strings.Join(artists, ", ")
+	for _, run := range runs(node(r, "flexColumns", 2, "musicResponsiveListItemFlexColumnRenderer", "text")) {
+		if id := nodeString(run, "navigationEndpoint", "browseEndpoint", "browseId"); strings.HasPrefix(id, "MPREb_") {
+			track.album = nodeString(run, "text")
+			track.albumID = id
+		}
+	}
+	if track.id == "" {
+		track.title = "⚠️ " + track.title + " (Not available for playback)"
+	}
+	return track
+}
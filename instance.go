@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kkdai/youtube/v2"
+)
+
+// handoffTimeout bounds a handoff from a second invocation, both ways
+const handoffTimeout = 5 * time.Second
+
+// errInstanceRunning reports that another gomusic already owns the instance socket
+var errInstanceRunning = errors.New("gomusic is already running")
+
+// bareVideoID matches a YouTube video ID given without a link
+var bareVideoID = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// handoffRequest asks the running gomusic to play or download a track
+type handoffRequest struct {
+	Action string `json:"action"` // "play" or "download"
+	ID     string `json:"id"`
+}
+
+type handoffReply struct {
+	Error string `json:"error,omitempty"`
+}
+
+// handoffMsg is a request from a second invocation. Update answers on reply
+// whether it was taken on.
+type handoffMsg struct {
+	req   handoffRequest
+	reply chan<- error
+}
+
+// handoffItemMsg carries the track of an accepted handoff once it is looked up
+type handoffItemMsg struct {
+	action string
+	item   songItem
+	err    error
+}
+
+// instanceSocketPath returns the socket the running gomusic listens on,
+// next to the status file
+func instanceSocketPath() string {
+	return filepath.Join(filepath.Dir(statusPath()), "instance.sock")
+}
+
+// claimInstance makes this the running gomusic. Only one may run at a time,
+// since two would fight over the status file, the download archive and the
+// saved download state. A socket left behind by a crash is taken over.
+func claimInstance() (net.Listener, error) {
+	path := instanceSocketPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, errInstanceRunning
+	}
+	os.Remove(path)
+	return net.Listen("unix", path)
+}
+
+// claimCommand makes the CLI command name the running gomusic while it runs,
+// as it writes the same files as the app. Handoffs meanwhile are turned down.
+// When gomusic is already running it says so and reports false.
+func claimCommand(name string) (release func(), ok bool) {
+	l, err := claimInstance()
+	if errors.Is(err, errInstanceRunning) {
+		fmt.Fprintf(os.Stderr, "gomusic is already running; quit it before running gomusic %s\n", name)
+		return nil, false
+	}
+	if err != nil {
+		// No socket, no other instance to find us either: run unguarded
+		return func() {}, true
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.SetDeadline(time.Now().Add(handoffTimeout))
+			json.NewEncoder(conn).Encode(handoffReply{Error: "gomusic " + name + " is running"})
+			conn.Close()
+		}
+	}()
+	return func() { l.Close() }, true
+}
+
+// serveHandoffs passes requests from later invocations to the UI until l is closed
+func serveHandoffs(l net.Listener, sender msgSender) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(handoffTimeout))
+			var req handoffRequest
+			var reply handoffReply
+			if err := json.NewDecoder(conn).Decode(&req); err != nil {
+				reply.Error = "unreadable request: " + err.Error()
+			} else {
+				result := make(chan error, 1)
+				sender.Send(handoffMsg{req: req, reply: result})
+				select {
+				case err := <-result:
+					if err != nil {
+						reply.Error = err.Error()
+					}
+				case <-time.After(handoffTimeout):
+					reply.Error = "gomusic did not answer"
+				}
+			}
+			json.NewEncoder(conn).Encode(reply)
+		}()
+	}
+}
+
+// sendHandoff hands req to the running gomusic
+func sendHandoff(req handoffRequest) error {
+	conn, err := net.DialTimeout("unix", instanceSocketPath(), time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * handoffTimeout))
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	var reply handoffReply
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		return err
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error)
+	}
+	return nil
+}
+
+// acceptHandoff checks whether the UI can take on req right now
+func (m model) acceptHandoff(req handoffRequest) error {
+	switch {
+	case offlineMode:
+		return fmt.Errorf("the running gomusic is offline")
+	case m.downloadRunning():
+		// Playing would take the screen the download's progress is shown on
+		return fmt.Errorf("the running gomusic is busy with a download")
+	case req.Action != "play" && req.Action != "download":
+		return fmt.Errorf("unknown action %q", req.Action)
+	}
+	return nil
+}
+
+// downloadRunning reports whether a download or conversion owns the screen
+func (m model) downloadRunning() bool {
+	return m.state == stateDownloading || m.state == stateDownloadingAlbum || m.state == stateConverting
+}
+
+// lookupHandoff fetches the title and cover of a handed off track
+func lookupHandoff(req handoffRequest) tea.Cmd {
+	return func() tea.Msg {
+		item, err := videoItem(youtube.Client{}, req.ID)
+		return handoffItemMsg{action: req.Action, item: item, err: err}
+	}
+}
+
+// applyHandoff plays or downloads a handed off track
+func (m *model) applyHandoff(msg handoffItemMsg) tea.Cmd {
+	if msg.err != nil {
+		m.notice = "Couldn't open the handed over track: " + msg.err.Error()
+		return nil
+	}
+	if m.downloadRunning() { // Started while the track was looked up
+		m.notice = "Couldn't open the handed over track: a download is in progress"
+		return nil
+	}
+	if msg.action == "play" {
+		m.queue.set([]songItem{msg.item}, 0)
+		return m.startPlayback(msg.item)
+	}
+	return m.startDownload(msg.item)
+}
+
+// runOpen implements `gomusic open [--download] URL`. With gomusic already
+// running the track is handed over to it and the returned request is nil;
+// otherwise the request is for the gomusic about to start.
+func runOpen(args []string) (*handoffRequest, int) {
+	usage := func() (*handoffRequest, int) {
+		fmt.Fprintln(os.Stderr, "usage: gomusic open [--download] YOUTUBE_URL")
+		return nil, 2
+	}
+	req := handoffRequest{Action: "play"}
+	for _, arg := range args {
+		if arg == "--download" || arg == "-d" {
+			req.Action = "download"
+			continue
+		}
+		id := arg
+		if match := youtubeLink.FindStringSubmatch(arg); match != nil {
+			id = match[1]
+		}
+		if req.ID != "" || !bareVideoID.MatchString(id) {
+			return usage()
+		}
+		req.ID = id
+	}
+	if req.ID == "" {
+		return usage()
+	}
+
+	err := sendHandoff(req)
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return &req, 0 // Nothing running: start up with the track
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Handing the track to the running gomusic failed: %v\n", err)
+		return nil, 1
+	case req.Action == "download":
+		fmt.Fprintln(os.Stderr, "Download started in the running gomusic")
+	default:
+		fmt.Fprintln(os.Stderr, "Playing in the running gomusic")
+	}
+	return nil, 0
+}
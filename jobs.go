@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// appCtx is cancelled when gomusic quits. Every background job runs under it,
+// so quitting aborts in-flight requests and kills ffmpeg children instead of
+// leaving them behind.
+var appCtx, quitJobs = context.WithCancel(context.Background())
+
+// jobScope runs one kind of background work at a time: starting a job cancels
+// the one before it
+type jobScope struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var (
+	searchJob   jobScope // YouTube Music searches, album track lookups and manual lyric searches
+	playbackJob jobScope // The playing track or preview with its lyric, art and waveform lookups
+	downloadJob jobScope // Single track and album downloads
+	previewJob  jobScope // Cover and tracklist of the highlighted search result
+)
+
+// start cancels the running job and returns the context for the next one
+func (s *jobScope) start() context.Context {
+	ctx, cancel := context.WithCancel(appCtx)
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.ctx, s.cancel = ctx, cancel
+	s.mu.Unlock()
+	return ctx
+}
+
+// context returns the running job's context, for work it spawns later on.
+// Without a running job the context is already cancelled.
+func (s *jobScope) context() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		ctx, cancel := context.WithCancel(appCtx)
+		cancel()
+		return ctx
+	}
+	return s.ctx
+}
+
+// stop cancels the running job, if any
+func (s *jobScope) stop() {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+		s.ctx, s.cancel = nil, nil
+	}
+	s.mu.Unlock()
+}
+
+// withContext runs fn and gives up with ctx's error once ctx is cancelled. The
+// YouTube Music client takes no context, so an abandoned request finishes in
+// the background and its result is dropped.
+func withContext[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		done <- result{value, err}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keysConfig rebinds the playback keys. Keys are named as Bubble Tea reports
+// them, like "p", "ctrl+n", "space" or "right".
+type keysConfig struct {
+	Pause       string `toml:"pause,omitempty"`
+	Next        string `toml:"next,omitempty"`
+	Stop        string `toml:"stop,omitempty"`
+	VolumeUp    string `toml:"volume_up,omitempty"`
+	VolumeDown  string `toml:"volume_down,omitempty"`
+	Mute        string `toml:"mute,omitempty"`
+	Lyrics      string `toml:"lyrics,omitempty"`
+	Info        string `toml:"info,omitempty"`
+	Export      string `toml:"export,omitempty"`
+	Browse      string `toml:"browse,omitempty"`
+	SeekForward string `toml:"seek_forward,omitempty"`
+	SeekBack    string `toml:"seek_back,omitempty"`
+}
+
+// keyAction is a rebindable key and the key it stands for by default. Global
+// actions work on every screen while a track plays, the rest on the playing
+// screen only.
+type keyAction struct {
+	name   string
+	def    string
+	global bool
+	bound  func(c keysConfig) string
+}
+
+var keyActions = []keyAction{
+	{"pause", " ", true, func(c keysConfig) string { return c.Pause }},
+	{"next", "n", true, func(c keysConfig) string { return c.Next }},
+	{"stop", "s", true, func(c keysConfig) string { return c.Stop }},
+	{"volume_up", "+", false, func(c keysConfig) string { return c.VolumeUp }},
+	{"volume_down", "-", false, func(c keysConfig) string { return c.VolumeDown }},
+	{"mute", "m", false, func(c keysConfig) string { return c.Mute }},
+	{"lyrics", "l", false, func(c keysConfig) string { return c.Lyrics }},
+	{"info", "i", false, func(c keysConfig) string { return c.Info }},
+	{"export", "e", false, func(c keysConfig) string { return c.Export }},
+	{"browse", "b", false, func(c keysConfig) string { return c.Browse }},
+	{"seek_forward", "right", false, func(c keysConfig) string { return c.SeekForward }},
+	{"seek_back", "left", false, func(c keysConfig) string { return c.SeekBack }},
+}
+
+// reservedKeys keep their meaning on the playing screen whatever the bindings
+var reservedKeys = map[string]bool{"ctrl+c": true, "ctrl+p": true, "q": true, "esc": true, "enter": true}
+
+// key returns the key the action is bound to in c
+func (a keyAction) key(c keysConfig) string {
+	k := a.bound(c)
+	switch k {
+	case "":
+		return a.def
+	case "space":
+		return " "
+	}
+	return k
+}
+
+func (c keysConfig) validate() error {
+	used := map[string]string{}
+	for _, a := range keyActions {
+		k := a.key(c)
+		if reservedKeys[k] {
+			return fmt.Errorf("keys: %s can't use %q, it is reserved", a.name, k)
+		}
+		if other, ok := used[k]; ok {
+			return fmt.Errorf("keys: %s and %s are both bound to %q", other, a.name, a.bound(c))
+		}
+		used[k] = a.name
+	}
+	return nil
+}
+
+// keyTarget is what a pressed key stands for: the default key of its action,
+// or "" for a default key that was rebound away
+type keyTarget struct {
+	key    string
+	global bool
+}
+
+// keyMap holds the keys that differ from the defaults, see applyKeys
+var keyMap = map[string]keyTarget{}
+
+// applyKeys puts the key bindings into effect
+func applyKeys(c keysConfig) {
+	keyMap = map[string]keyTarget{}
+	for _, a := range keyActions {
+		if a.key(c) != a.def {
+			keyMap[a.def] = keyTarget{global: a.global}
+		}
+	}
+	for _, a := range keyActions {
+		if k := a.key(c); k != a.def {
+			keyMap[k] = keyTarget{key: a.def, global: a.global}
+		}
+	}
+}
+
+// rebindKey turns a key press into the default key of the action it is bound
+// to, so the handlers only know the defaults. It returns false for a default
+// key that no longer does anything.
+func (m *model) rebindKey(msg tea.KeyMsg) (tea.KeyMsg, bool) {
+	t, ok := keyMap[msg.String()]
+	if !ok || !m.playing() || m.typing() || (!t.global && m.state != statePlaying) {
+		return msg, true
+	}
+	switch t.key {
+	case "":
+		return msg, false
+	case " ":
+		return tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}, true
+	case "left":
+		return tea.KeyMsg{Type: tea.KeyLeft}, true
+	case "right":
+		return tea.KeyMsg{Type: tea.KeyRight}, true
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(t.key)}, true
+}
+
+// keyLabel is how the help lines show the key of the named action
+func keyLabel(name string) string {
+	for _, a := range keyActions {
+		if a.name != name {
+			continue
+		}
+		switch k := a.key(cfg.Keys); k {
+		case " ":
+			return "SPACE"
+		case "left":
+			return "←"
+		case "right":
+			return "→"
+		default:
+			return strings.ToUpper(k)
+		}
+	}
+	return ""
+}
+
+// playingHelp is the help line of the playing screen
+func playingHelp() string {
+	return fmt.Sprintf("%s: Play/Pause  •  %s: Next  •  %s/%s: Volume  •  %s: Mute  •  %s: Search Lyrics  •  %s: Info  •  %s: Export Queue  •  %s: Browse  •  %s: Stop  •  Q: Exit",
+		keyLabel("pause"), keyLabel("next"), keyLabel("volume_up"), keyLabel("volume_down"), keyLabel("mute"),
+		keyLabel("lyrics"), keyLabel("info"), keyLabel("export"), keyLabel("browse"), keyLabel("stop"))
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// libraryDir is where downloads end up and the library is read from:
+// download.dir, or the working directory
+func libraryDir() string {
+	if cfg.Download.Dir == "" {
+		return "."
+	}
+	return expandHome(cfg.Download.Dir)
+}
+
+// audioExtensions are the file types recognised as tracks in the library
+var audioExtensions = map[string]bool{
+	".mp3": true, ".m4a": true, ".flac": true, ".opus": true, ".ogg": true, ".wav": true,
+}
+
+// trackNumberPrefix matches the "01 - " prefix album downloads are named with
+var trackNumberPrefix = regexp.MustCompile(`^\d+\s*-\s*`)
+
+// libraryAlbum is a downloaded album folder shown in the library view
+type libraryAlbum struct {
+	name     string
+	path     string
+	tracks   int
+	artist   string
+	artistID string // YT Music artist ID tagged on the tracks, empty for other files
+}
+
+func (a libraryAlbum) Title() string { return "📀 " + a.name }
+func (a libraryAlbum) Description() string {
+	if a.artist == "" {
+		return fmt.Sprintf("%d tracks", a.tracks)
+	}
+	return fmt.Sprintf("%s  •  %d tracks", a.artist, a.tracks)
+}
+func (a libraryAlbum) FilterValue() string { return a.artist + " " + a.name }
+
+// artistKey groups albums by artist ID, so same-named artists stay apart
+func (a libraryAlbum) artistKey() string {
+	if a.artistID != "" {
+		return a.artistID
+	}
+	return strings.ToLower(a.artist)
+}
+
+// isAudioFile reports whether name has one of the supported audio extensions
+func isAudioFile(name string) bool {
+	return audioExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// maxLibraryDepth is how deep album folders are looked for, enough for
+// download templates like {artist}/{album}/...
+const maxLibraryDepth = 3
+
+// scanLibrary lists the folders under dir that contain audio files
+func scanLibrary(dir string) tea.Cmd {
+	return func() tea.Msg {
+		if _, err := os.ReadDir(dir); err != nil {
+			return libraryScannedMsg{err: err}
+		}
+		var albums []libraryAlbum
+		var index []libraryEntry
+		var scan func(path string, depth int)
+		scan = func(path string, depth int) {
+			files, err := os.ReadDir(path)
+			if err != nil {
+				return
+			}
+			var names []string
+			for _, f := range files {
+				switch {
+				case f.IsDir() && !strings.HasPrefix(f.Name(), ".") && depth < maxLibraryDepth:
+					scan(filepath.Join(path, f.Name()), depth+1)
+				case !f.IsDir() && isAudioFile(f.Name()):
+					names = append(names, f.Name())
+				}
+			}
+			// Loose files in the library itself aren't an album
+			if len(names) > 0 && depth > 0 {
+				artist, artistID := folderArtistID(path)
+				album := libraryAlbum{name: filepath.Base(path), path: path, tracks: len(names), artist: artist, artistID: artistID}
+				albums = append(albums, album)
+				sort.Strings(names)
+				index = append(index, indexAlbum(album, names)...)
+			}
+		}
+		scan(dir, 0)
+		// Group each artist's albums together, in name order
+		sort.SliceStable(albums, func(i, j int) bool {
+			if albums[i].artistKey() != albums[j].artistKey() {
+				return strings.ToLower(albums[i].artist) < strings.ToLower(albums[j].artist) ||
+					(strings.EqualFold(albums[i].artist, albums[j].artist) && albums[i].artistKey() < albums[j].artistKey())
+			}
+			return albums[i].name < albums[j].name
+		})
+		return libraryScannedMsg{albums: albums, index: index}
+	}
+}
+
+// loadLocalAlbum reads the tracks of an album folder in file name order,
+// which is track order for albums downloaded by gomusic. Playback starts at
+// the track at startPath, or the first one when it is empty.
+func loadLocalAlbum(album libraryAlbum, startPath string) tea.Cmd {
+	return func() tea.Msg {
+		files, err := os.ReadDir(album.path)
+		if err != nil {
+			return localAlbumMsg{err: err}
+		}
+		var names []string
+		for _, f := range files {
+			if !f.IsDir() && isAudioFile(f.Name()) {
+				names = append(names, f.Name())
+			}
+		}
+		sort.Strings(names)
+
+		var tracks []songItem
+		for _, name := range names {
+			tracks = append(tracks, localTrackItem(filepath.Join(album.path, name), album.name))
+		}
+		if len(tracks) == 0 {
+			return localAlbumMsg{err: fmt.Errorf("no audio files in %s", album.path)}
+		}
+		start := 0
+		for i, t := range tracks {
+			if t.path == startPath {
+				start = i
+			}
+		}
+		return localAlbumMsg{tracks: tracks, start: start}
+	}
+}
+
+// localTrackItem describes a file on disk, preferring its tags over the file name
+func localTrackItem(path, album string) songItem {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	item := songItem{
+		title: trackNumberPrefix.ReplaceAllString(name, ""),
+		album: album,
+		path:  path,
+	}
+	tags, dur, err := probeTags(path)
+	if err != nil {
+		return item
+	}
+	if tags["title"] != "" {
+		item.title = tags["title"]
+	}
+	if tags["artist"] != "" {
+		item.author = tags["artist"]
+	}
+	if tags["album"] != "" {
+		item.album = tags["album"]
+	}
+	item.duration = dur
+	return item
+}
+
+// probeTags reads the container tags (lower-cased keys) and duration in seconds of a local file
+func probeTags(path string) (map[string]string, int, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration:format_tags",
+		"-of", "json",
+		path,
+	).Output()
+	if err != nil {
+		return nil, 0, err
+	}
+	var probe struct {
+		Format struct {
+			Duration string            `json:"duration"`
+			Tags     map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, 0, err
+	}
+	tags := make(map[string]string, len(probe.Format.Tags))
+	for k, v := range probe.Format.Tags {
+		tags[strings.ToLower(k)] = v
+	}
+	seconds, _ := strconv.ParseFloat(probe.Format.Duration, 64)
+	return tags, int(seconds), nil
+}
+
+// localCoverKey names the cached cover of a local track
+func localCoverKey(path string) string {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	// A retagged file gets a new key, so its cached art is not reused
+	if info, err := os.Stat(path); err == nil {
+		fmt.Fprintf(h, "%d", info.ModTime().UnixNano())
+	}
+	return fmt.Sprintf("local_%08x", h.Sum32())
+}
+
+// extractEmbeddedArt writes the picture embedded in a local file (APIC frame
+// for MP3, cover atom for M4A) to out as JPEG
+func extractEmbeddedArt(path, out string) error {
+	return exec.Command("ffmpeg",
+		"-y",
+		"-loglevel", "error",
+		"-i", path,
+		"-an",
+		"-frames:v", "1",
+		out,
+	).Run()
+}
+
+// localLyrics loads the .lrc sidecar written next to a downloaded track
+func localLyrics(path string) []LyricLine {
+	data, err := os.ReadFile(strings.TrimSuffix(path, filepath.Ext(path)) + ".lrc")
+	if err != nil {
+		return nil
+	}
+	return parseLRC(string(data))
+}
+
+// embeddedLyrics reads the lyrics embedded in a local track: SYLT or USLT
+// frames of MP3s, the LYRICS tag of other files. unsynced reports lyrics
+// without timestamps, whose lines are shown as plain text.
+func embeddedLyrics(path string) (lines []LyricLine, unsynced bool) {
+	var text string
+	if strings.EqualFold(filepath.Ext(path), ".mp3") {
+		synced, plain, err := readID3Lyrics(path)
+		if len(synced) > 0 {
+			return synced, false
+		}
+		if err == nil {
+			text = plain
+		}
+	}
+	if text == "" {
+		tags, _, err := probeTags(path)
+		if err != nil {
+			return nil, false
+		}
+		for key, value := range tags {
+			if key == "lyrics" || key == "unsyncedlyrics" || strings.HasPrefix(key, "lyrics-") {
+				text = value
+				break
+			}
+		}
+	}
+	if lines := parseLRC(text); len(lines) > 0 {
+		return lines, false
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r", ""), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, LyricLine{Text: line})
+		}
+	}
+	return lines, len(lines) > 0
+}
+
+// openLibrary switches to the library view and starts scanning for album folders
+func (m *model) openLibrary() tea.Cmd {
+	m.libraryErr = nil
+	m.libraryLoading = true
+	m.state = stateLibrary
+	return tea.Batch(m.spinner.Tick, scanLibrary(libraryDir()))
+}
+
+func (m model) updateLibrary(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.libraryList.FilterState() != list.Filtering {
+		switch msg.String() {
+		case "esc", "q":
+			if offlineMode {
+				// The library is the whole app when offline
+				m.quitting = true
+				return m, tea.Quit
+			}
+			m.state = stateInput
+			return m, nil
+		case "enter":
+			if album, ok := m.libraryList.SelectedItem().(libraryAlbum); ok && !m.libraryLoading {
+				m.libraryLoading = true
+				return m, tea.Batch(m.spinner.Tick, loadLocalAlbum(album, ""))
+			}
+			return m, nil
+		case "ctrl+f":
+			if len(m.libraryIndex) > 0 {
+				return m, m.openLibrarySearch()
+			}
+			return m, nil
+		case "t":
+			if album, ok := m.libraryList.SelectedItem().(libraryAlbum); ok {
+				m.startTransfer(album.path)
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.libraryList, cmd = m.libraryList.Update(msg)
+	return m, cmd
+}
+
+func (m model) viewLibrary() string {
+	back := "ESC: Back"
+	if offlineMode {
+		back = "ESC: Quit  •  Offline mode"
+	}
+	if m.libraryErr != nil {
+		return fmt.Sprintf("\n  %s\n\n  %v\n\n  %s\n",
+			titleStyle.Render("Library"),
+			errorStyle.Render(m.libraryErr.Error()),
+			helpStyle.Render(back),
+		)
+	}
+	if m.libraryLoading && len(m.libraryList.Items()) == 0 {
+		return fmt.Sprintf("\n  %s Scanning library...\n", m.spinner.View())
+	}
+	if len(m.libraryList.Items()) == 0 {
+		return fmt.Sprintf("\n  %s\n\n  %s\n\n  %s\n",
+			titleStyle.Render("Library"),
+			"No downloaded albums found in this folder.",
+			helpStyle.Render(back),
+		)
+	}
+	help := helpStyle.Render("\n  ENTER: Play Album  •  T: Send to Device  •  /: Filter Albums  •  Ctrl+F: Search Tracks  •  " + back)
+	if m.transferStatus != "" {
+		help += "\n  " + statusStyle.Render(m.transferStatus)
+	}
+	if m.watchStatus != "" {
+		help += "\n  " + helpStyle.Render(m.watchStatus)
+	}
+	return docStyle.Render(
+		lipgloss.JoinVertical(lipgloss.Left,
+			m.libraryList.View(),
+			help,
+		),
+	)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxLibraryMatches caps the tracks listed by the library search
+const maxLibraryMatches = 200
+
+// libraryEntry is one track in the library search index
+type libraryEntry struct {
+	title, artist, album string
+	path                 string
+	albumName, albumPath string // Folder the track is played from
+	key                  string // Lower-cased title, artist and album matched against
+}
+
+// indexAlbum lists the tracks of an album folder for the search index.
+// MP3 tags are read directly; other files are named after the file and folder.
+func indexAlbum(album libraryAlbum, names []string) []libraryEntry {
+	entries := make([]libraryEntry, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(album.path, name)
+		e := libraryEntry{
+			title:     trackNumberPrefix.ReplaceAllString(strings.TrimSuffix(name, filepath.Ext(name)), ""),
+			artist:    album.artist,
+			album:     album.name,
+			path:      path,
+			albumName: album.name,
+			albumPath: album.path,
+		}
+		if strings.EqualFold(filepath.Ext(name), ".mp3") {
+			if tags, err := readID3Tags(path); err == nil {
+				if tags["title"] != "" {
+					e.title = tags["title"]
+				}
+				if tags["artist"] != "" {
+					e.artist = tags["artist"]
+				}
+				if tags["album"] != "" {
+					e.album = tags["album"]
+				}
+			}
+		}
+		e.key = strings.ToLower(e.title + " " + e.artist + " " + e.album)
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// fuzzyScore rates how well token matches text, both lower-cased. A plain
+// substring wins, more so at a word start; otherwise the token's letters must
+// appear in order, and runs of adjacent letters count more.
+func fuzzyScore(token, text string) (int, bool) {
+	if i := strings.Index(text, token); i >= 0 {
+		score := 100 + 2*len(token)
+		if i == 0 || text[i-1] == ' ' {
+			score += 50
+		}
+		return score, true
+	}
+	score, t, last := 0, 0, -2
+	for i := 0; i < len(text) && t < len(token); i++ {
+		if text[i] != token[t] {
+			continue
+		}
+		if last == i-1 {
+			score += 3
+		} else {
+			score++
+		}
+		last = i
+		t++
+	}
+	return score, t == len(token)
+}
+
+// searchLibrary returns the indexed tracks matching every word of query, best first
+func searchLibrary(index []libraryEntry, query string) []libraryEntry {
+	tokens := strings.Fields(strings.ToLower(query))
+	if len(tokens) == 0 {
+		return nil
+	}
+	type match struct {
+		entry libraryEntry
+		score int
+	}
+	var matches []match
+	for _, e := range index {
+		total := 0
+		ok := true
+		for _, token := range tokens {
+			score, found := fuzzyScore(token, e.key)
+			if !found {
+				ok = false
+				break
+			}
+			total += score
+		}
+		if ok {
+			matches = append(matches, match{e, total})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	var entries []libraryEntry
+	for _, m := range matches[:min(len(matches), maxLibraryMatches)] {
+		entries = append(entries, m.entry)
+	}
+	return entries
+}
+
+// openLibrarySearch switches to the track search over the library index
+func (m *model) openLibrarySearch() tea.Cmd {
+	ti := textinput.New()
+	ti.Placeholder = "Title, artist or album..."
+	ti.CharLimit = 156
+	ti.Width = 40
+	ti.Focus()
+	m.librarySearch = ti
+	m.libraryMatches = nil
+	m.libraryCursor = 0
+	m.state = stateLibrarySearch
+	return textinput.Blink
+}
+
+func (m model) updateLibrarySearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.state = stateLibrary
+		return m, nil
+	case "up":
+		m.libraryCursor = max(0, m.libraryCursor-1)
+		return m, nil
+	case "down":
+		if m.libraryCursor < len(m.libraryMatches)-1 {
+			m.libraryCursor++
+		}
+		return m, nil
+	case "enter":
+		if m.libraryCursor < len(m.libraryMatches) && !m.libraryLoading {
+			e := m.libraryMatches[m.libraryCursor]
+			m.libraryLoading = true
+			m.state = stateLibrary
+			return m, tea.Batch(m.spinner.Tick, loadLocalAlbum(libraryAlbum{name: e.albumName, path: e.albumPath}, e.path))
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.librarySearch, cmd = m.librarySearch.Update(msg)
+	m.libraryMatches = searchLibrary(m.libraryIndex, m.librarySearch.Value())
+	m.libraryCursor = 0
+	return m, cmd
+}
+
+func (m model) viewLibrarySearch() string {
+	var rows []string
+	if m.librarySearch.Value() != "" && len(m.libraryMatches) == 0 {
+		rows = append(rows, helpStyle.Render("No matching tracks"))
+	}
+	// Keep the cursor in a window that fits the terminal
+	height := 15
+	if m.height > 0 {
+		height = max(3, m.height-10)
+	}
+	first := max(0, min(m.libraryCursor-height/2, len(m.libraryMatches)-height))
+	for i := first; i < min(first+height, len(m.libraryMatches)); i++ {
+		e := m.libraryMatches[i]
+		source := helpStyle.Render(e.artist + " • " + e.album)
+		if i == m.libraryCursor {
+			rows = append(rows, "> "+statusStyle.Render(e.title)+"  "+source)
+		} else {
+			rows = append(rows, "  "+e.title+"  "+source)
+		}
+	}
+	return fmt.Sprintf("\n  %s\n\n  %s\n\n  %s\n\n  %s\n",
+		titleStyle.Render(fmt.Sprintf("Search Library (%d tracks)", len(m.libraryIndex))),
+		m.librarySearch.View(),
+		strings.Join(rows, "\n  "),
+		helpStyle.Render("↑/↓: Select  •  ENTER: Play from this track  •  ESC: Back"),
+	)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kkdai/youtube/v2"
+)
+
+// playlistLink matches the playlist ID of YouTube and YouTube Music playlist
+// links; albums are playlists starting with OLAK5uy_
+var playlistLink = regexp.MustCompile(`youtube\.com/(?:playlist|watch)\?(?:\S*?&)?list=([A-Za-z0-9_-]+)`)
+
+// albumBrowseLink matches the browse ID of a YouTube Music album page
+var albumBrowseLink = regexp.MustCompile(`music\.youtube\.com/browse/(MPREb_[A-Za-z0-9_-]+)`)
+
+// linkedPlaylistMsg carries a playlist or album opened from a pasted link
+type linkedPlaylistMsg struct {
+	album  songItem
+	tracks []songItem
+}
+
+// openLink resolves a YouTube or YouTube Music link typed into the search
+// field: a track becomes the only result, a playlist or album opens in the
+// album view. ok is false when query isn't a link.
+func (m *model) openLink(query string) (cmd tea.Cmd, ok bool) {
+	query = strings.TrimSpace(query)
+	video := youtubeLink.FindStringSubmatch(query)
+	playlist := playlistLink.FindStringSubmatch(query)
+	album := albumBrowseLink.FindStringSubmatch(query)
+	if video == nil && playlist == nil && album == nil {
+		return nil, false
+	}
+	if !m.requireNetwork("opening links") {
+		return nil, true
+	}
+	ctx := searchJob.start()
+	m.state = stateSearching
+	switch {
+	case video != nil: // A track played from a playlist is still the track
+		return tea.Batch(m.spinner.Tick, linkedTrack(ctx, query, video[1])), true
+	case playlist != nil:
+		return tea.Batch(m.spinner.Tick, linkedPlaylist(ctx, playlist[1])), true
+	}
+	return tea.Batch(m.spinner.Tick, linkedAlbum(ctx, album[1])), true
+}
+
+// linkedAlbum fetches the album page with browseID
+func linkedAlbum(ctx context.Context, browseID string) tea.Cmd {
+	return func() tea.Msg {
+		album, tracks, err := browseAlbum(ctx, browseID)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return errMsg(fmt.Errorf("opening the linked album failed: %v", err))
+		}
+		return linkedPlaylistMsg{album: album, tracks: tracks}
+	}
+}
+
+// linkedTrack looks up the linked video id as the only result of a search for link
+func linkedTrack(ctx context.Context, link, id string) tea.Cmd {
+	return func() tea.Msg {
+		item, err := videoItem(youtube.Client{}, id)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return errMsg(fmt.Errorf("opening the linked track failed: %v", err))
+		}
+		return searchResultsMsg{key: newSearchKey(link, filterAll, false), items: []songItem{item}}
+	}
+}
+
+// linkedPlaylist fetches the tracks of the linked playlist id: playlists from
+// their YouTube Music page, albums from YouTube
+func linkedPlaylist(ctx context.Context, id string) tea.Cmd {
+	if !strings.HasPrefix(id, "OLAK5uy_") {
+		return func() tea.Msg {
+			playlist, tracks, err := browsePlaylist(ctx, "VL"+id)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return errMsg(fmt.Errorf("opening the linked playlist failed: %v", err))
+			}
+			return linkedPlaylistMsg{album: playlist, tracks: tracks}
+		}
+	}
+	return func() tea.Msg {
+		client := youtube.Client{}
+		playlist, err := client.GetPlaylistContext(ctx, id)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return errMsg(fmt.Errorf("opening the linked playlist failed: %v", err))
+		}
+		title := strings.TrimPrefix(playlist.Title, "Album - ")
+		album := songItem{id: playlist.ID, title: title, author: playlist.Author, isAlbum: true}
+		var tracks []songItem
+		for _, v := range playlist.Videos {
+			track := songItem{
+				id:       v.ID,
+				title:    v.Title,
+				author:   strings.TrimSuffix(v.Author, " - Topic"),
+				album:    title,
+				duration: int(v.Duration.Seconds()),
+			}
+			if n := len(v.Thumbnails); n > 0 {
+				track.thumb = v.Thumbnails[n-1].URL
+			}
+			if album.thumb == "" {
+				album.thumb = track.thumb
+			}
+			if album.author == "" {
+				album.author = track.author
+			}
+			tracks = append(tracks, track)
+		}
+		if len(tracks) == 0 {
+			return errMsg(fmt.Errorf("the linked playlist is empty or private"))
+		}
+		album.trackCount = len(tracks)
+		return linkedPlaylistMsg{album: album, tracks: tracks}
+	}
+}
+
+// applyLinkedPlaylist shows a linked playlist in the album view, which goes
+// back to the search screen
+func (m *model) applyLinkedPlaylist(msg linkedPlaylistMsg) {
+	m.selected = msg.album
+	m.currentAlbum = msg.album
+	m.albumFromInput = true
+	m.filteredTracks = nil
+	m.albumExcluded = nil
+	m.setAlbumTracks(msg.tracks)
+	m.state = stateViewingAlbumTracks
+}
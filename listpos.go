@@ -0,0 +1,26 @@
+package main
+
+import "github.com/charmbracelet/bubbles/list"
+
+// Lists are rebuilt whenever their contents change: the library is
+// rescanned, an album is opened again, filtered tracks are restored. The
+// cursor is carried over to the item it was on, so long lists don't jump
+// back to the top.
+
+// selectItem moves the cursor of l to the first item match accepts,
+// reporting whether there was one
+func selectItem(l *list.Model, match func(list.Item) bool) bool {
+	for i, item := range l.Items() {
+		if match(item) {
+			l.Select(i)
+			return true
+		}
+	}
+	return false
+}
+
+// selectedSong returns the song under the cursor of l, if it is one
+func selectedSong(l list.Model) (songItem, bool) {
+	item, ok := l.SelectedItem().(songItem)
+	return item, ok
+}
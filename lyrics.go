@@ -1,17 +1,52 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
+// maxLyricDurationDiff is the largest difference in seconds between the track
+// and a lyric candidate before the candidate is rejected as a different song
+const maxLyricDurationDiff = 8
+
+// minLyricScore is the lowest confidence accepted for an automatic lyric match
+const minLyricScore = 0.45
+
+// lyricsConfig picks where lyrics come from
+type lyricsConfig struct {
+	Providers []string `toml:"providers,omitempty"` // "sidecar" (.lrc next to local files), "embedded" (lyrics tags of local files) and "lrclib"; all when left out
+}
+
+// lyricProviders are the lyric sources lyrics.providers can list
+var lyricProviders = []string{"sidecar", "embedded", "lrclib"}
+
+func (c lyricsConfig) validate() error {
+	for _, p := range c.Providers {
+		if !slices.Contains(lyricProviders, p) {
+			return fmt.Errorf("lyrics: unknown provider %q, use sidecar, embedded or lrclib", p)
+		}
+	}
+	return nil
+}
+
+// uses reports whether lyrics are looked up from provider
+func (c lyricsConfig) uses(provider string) bool {
+	return len(c.Providers) == 0 || slices.Contains(c.Providers, provider)
+}
+
 // LRCLIB API response structure
 type lrclibResponse struct {
 	TrackName    string  `json:"trackName"`
@@ -21,7 +56,114 @@ type lrclibResponse struct {
 	SyncedLyrics string  `json:"syncedLyrics"`
 }
 
-func fetchLyrics(title, artist string, duration int) ([]LyricLine, error) {
+// lyricsService shares lyrics between playback and downloads so a track is
+// only looked up on LRCLIB once. Results are kept in memory for the session
+// and on disk as .lrc files keyed by video ID.
+type lyricsService struct {
+	mu     sync.Mutex
+	byID   map[string][]LyricLine
+	missed map[string]bool // Tracks LRCLIB had nothing for this session
+}
+
+var lyricsCache = &lyricsService{
+	byID:   make(map[string][]LyricLine),
+	missed: make(map[string]bool),
+}
+
+// get returns the lyrics for a video, fetching them from LRCLIB if they are not cached
+func (s *lyricsService) get(ctx context.Context, videoID, title, artist string, duration int) ([]LyricLine, error) {
+	s.mu.Lock()
+	lines, ok := s.byID[videoID]
+	missed := s.missed[videoID]
+	s.mu.Unlock()
+	if ok {
+		return lines, nil
+	}
+	if missed {
+		return nil, fmt.Errorf("lyrics not found")
+	}
+
+	if lines := readCachedLRC(videoID); len(lines) > 0 {
+		s.remember(videoID, lines)
+		return lines, nil
+	}
+	if !cfg.Lyrics.uses("lrclib") {
+		return nil, fmt.Errorf("lyrics not found")
+	}
+
+	lines, err := fetchLyrics(ctx, title, artist, duration)
+	if ctx.Err() != nil {
+		// Called off, which says nothing about whether LRCLIB has the track
+		return nil, ctx.Err()
+	}
+	if err != nil || len(lines) == 0 {
+		s.mu.Lock()
+		s.missed[videoID] = true
+		s.mu.Unlock()
+		return nil, fmt.Errorf("lyrics not found")
+	}
+	s.put(videoID, lines)
+	return lines, nil
+}
+
+// put stores lyrics for a video, replacing any previous match
+func (s *lyricsService) put(videoID string, lines []LyricLine) {
+	if videoID == "" {
+		return
+	}
+	s.remember(videoID, lines)
+	if dir, err := cacheDir("lyrics"); err == nil {
+		os.WriteFile(filepath.Join(dir, videoID+".lrc"), []byte(formatLRC(lines)), 0644)
+	}
+}
+
+func (s *lyricsService) remember(videoID string, lines []LyricLine) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byID[videoID] = lines
+	delete(s.missed, videoID)
+}
+
+// readCachedLRC loads lyrics previously stored on disk for a video
+func readCachedLRC(videoID string) []LyricLine {
+	dir, err := cacheDir("lyrics")
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, videoID+".lrc"))
+	if err != nil {
+		return nil
+	}
+	return parseLRC(string(data))
+}
+
+// formatLRC renders lyric lines back into LRC text
+func formatLRC(lines []LyricLine) string {
+	var b strings.Builder
+	for _, l := range lines {
+		total := l.Timestamp.Seconds()
+		minutes := int(total) / 60
+		fmt.Fprintf(&b, "[%02d:%05.2f]%s\n", minutes, total-float64(minutes*60), l.Text)
+	}
+	return b.String()
+}
+
+// writeLRCSidecar saves synced lyrics next to an audio file for players that read .lrc files
+func writeLRCSidecar(audioPath string, lines []LyricLine) error {
+	lrcPath := strings.TrimSuffix(audioPath, filepath.Ext(audioPath)) + ".lrc"
+	return os.WriteFile(lrcPath, []byte(formatLRC(lines)), 0644)
+}
+
+// plainLyrics joins lyric lines without timestamps for embedding as unsynced lyrics
+func plainLyrics(lines []LyricLine) string {
+	var texts []string
+	for _, l := range lines {
+		texts = append(texts, l.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+func fetchLyrics(ctx context.Context, title, artist string, duration int) ([]LyricLine, error) {
 	// Search for lyrics using LRCLIB API - optimized order
 
 	cleanedTitle := cleanString(title)
@@ -29,7 +171,7 @@ func fetchLyrics(title, artist string, duration int) ([]LyricLine, error) {
 
 	// Strategy 1: Search endpoint first (broader, usually faster)
 	searchQuery := cleanedArtist + " " + cleanedTitle
-	lyrics, err := trySearch(searchQuery)
+	lyrics, err := trySearch(ctx, searchQuery, cleanedTitle, cleanedArtist, duration)
 	if err == nil {
 		return lyrics, nil
 	}
@@ -40,14 +182,14 @@ func fetchLyrics(title, artist string, duration int) ([]LyricLine, error) {
 		newArtist := cleanArtist(parts[0])
 		newTitle := cleanString(parts[1])
 
-		lyrics, err = trySearch(newArtist + " " + newTitle)
+		lyrics, err = trySearch(ctx, newArtist+" "+newTitle, newTitle, newArtist, duration)
 		if err == nil {
 			return lyrics, nil
 		}
 	}
 
 	// Strategy 3: Exact get without duration (last resort)
-	lyrics, err = tryFetch(cleanedTitle, cleanedArtist, 0)
+	lyrics, err = tryFetch(ctx, cleanedTitle, cleanedArtist, 0, duration)
 	if err == nil {
 		return lyrics, nil
 	}
@@ -55,17 +197,22 @@ func fetchLyrics(title, artist string, duration int) ([]LyricLine, error) {
 	return nil, fmt.Errorf("lyrics not found")
 }
 
-func tryFetch(title, artist string, duration int) ([]LyricLine, error) {
+// tryFetch asks LRCLIB for an exact match. queryDuration is sent to the API
+// while trackDuration is only used to reject a result of the wrong length.
+func tryFetch(ctx context.Context, title, artist string, queryDuration, trackDuration int) ([]LyricLine, error) {
 	baseURL := "https://lrclib.net/api/get"
 	params := url.Values{}
 	params.Add("artist_name", artist)
 	params.Add("track_name", title)
-	if duration > 0 {
-		params.Add("duration", strconv.Itoa(duration))
+	if queryDuration > 0 {
+		params.Add("duration", strconv.Itoa(queryDuration))
 	}
 
-	client := &http.Client{Timeout: 7 * time.Second}
-	resp, err := client.Get(baseURL + "?" + params.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := apiClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -83,17 +230,101 @@ func tryFetch(title, artist string, duration int) ([]LyricLine, error) {
 	if lrclib.SyncedLyrics == "" {
 		return nil, fmt.Errorf("no synced lyrics")
 	}
+	if _, ok := scoreLyricCandidate(lrclib, title, artist, trackDuration); !ok {
+		return nil, fmt.Errorf("lyrics duration does not match track")
+	}
 
 	return parseLRC(lrclib.SyncedLyrics), nil
 }
 
-func trySearch(query string) ([]LyricLine, error) {
+// trySearch searches LRCLIB and returns the synced lyrics of the candidate that
+// best matches the track's title, artist and duration
+func trySearch(ctx context.Context, query, title, artist string, duration int) ([]LyricLine, error) {
+	results, err := searchLyricsCandidates(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	best, ok := bestLyricCandidate(results, title, artist, duration)
+	if !ok {
+		return nil, fmt.Errorf("no confident synced lyrics match in search")
+	}
+	return parseLRC(best.SyncedLyrics), nil
+}
+
+// bestLyricCandidate picks the highest scoring synced candidate, if any is confident enough
+func bestLyricCandidate(results []lrclibResponse, title, artist string, duration int) (lrclibResponse, bool) {
+	var best lrclibResponse
+	bestScore := -1.0
+	for _, res := range results {
+		if res.SyncedLyrics == "" {
+			continue
+		}
+		score, ok := scoreLyricCandidate(res, title, artist, duration)
+		if ok && score > bestScore {
+			best, bestScore = res, score
+		}
+	}
+	return best, bestScore >= minLyricScore
+}
+
+// scoreLyricCandidate rates a candidate between 0 and 1 by title and artist
+// similarity and duration proximity. It reports false when the duration differs
+// by more than maxLyricDurationDiff, since that is almost always another song.
+func scoreLyricCandidate(res lrclibResponse, title, artist string, duration int) (float64, bool) {
+	durationScore := 0.5 // Unknown duration is neutral
+	if duration > 0 && res.Duration > 0 {
+		diff := res.Duration - float64(duration)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > maxLyricDurationDiff {
+			return 0, false
+		}
+		durationScore = 1 - diff/maxLyricDurationDiff
+	}
+
+	titleScore := wordSimilarity(cleanString(res.TrackName), title)
+	artistScore := wordSimilarity(cleanArtist(res.ArtistName), artist)
+	return 0.5*titleScore + 0.3*artistScore + 0.2*durationScore, true
+}
+
+// wordSimilarity returns the Jaccard similarity of the words in a and b
+func wordSimilarity(a, b string) float64 {
+	split := func(s string) map[string]bool {
+		words := make(map[string]bool)
+		for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			words[w] = true
+		}
+		return words
+	}
+
+	wa, wb := split(a), split(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range wa {
+		if wb[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wa)+len(wb)-shared)
+}
+
+// searchLyricsCandidates returns every LRCLIB search result for a query
+func searchLyricsCandidates(ctx context.Context, query string) ([]lrclibResponse, error) {
 	baseURL := "https://lrclib.net/api/search"
 	params := url.Values{}
 	params.Add("q", query)
 
-	client := &http.Client{Timeout: 7 * time.Second}
-	resp, err := client.Get(baseURL + "?" + params.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := apiClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -107,14 +338,7 @@ func trySearch(query string) ([]LyricLine, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
 		return nil, err
 	}
-
-	for _, res := range results {
-		if res.SyncedLyrics != "" {
-			return parseLRC(res.SyncedLyrics), nil
-		}
-	}
-
-	return nil, fmt.Errorf("no synced lyrics in search")
+	return results, nil
 }
 
 func cleanString(s string) string {
@@ -122,16 +346,8 @@ func cleanString(s string) string {
 	reBrackets := regexp.MustCompile(`\[[^\]]*\]|\([^)]*\)`)
 	s = reBrackets.ReplaceAllString(s, "")
 
-	// 2. Remove common suffixes (case insensitive)
-	suffixes := []string{
-		"official music video", "official video", "official audio",
-		"music video", "lyric video", "lyrics", "official",
-		"video", "audio", "full song", "hd", "4k", "720p", "1080p",
-	}
-	for _, suffix := range suffixes {
-		re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(suffix))
-		s = re.ReplaceAllString(s, "")
-	}
+	// 2. Remove common suffixes in any configured language (case insensitive)
+	s = stripTitleNoise(s)
 
 	// 3. Remove "by Artist" patterns if they exist
 	reBy := regexp.MustCompile(`(?i)\s+by\s+.*$`)
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// searchLyricsManual runs a user-edited query against LRCLIB and returns the
+// candidates that carry synced lyrics. Nothing is reported once ctx is cancelled.
+func searchLyricsManual(ctx context.Context, query string) tea.Cmd {
+	return func() tea.Msg {
+		results, err := searchLyricsCandidates(ctx, query)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return lyricsCandidatesMsg{err: err}
+		}
+		var synced []lrclibResponse
+		for _, res := range results {
+			if res.SyncedLyrics != "" {
+				synced = append(synced, res)
+			}
+		}
+		return lyricsCandidatesMsg{results: synced}
+	}
+}
+
+// openLyricsSearch switches to the manual lyrics search prompt, prefilled with
+// the cleaned artist and title of the current track
+func (m *model) openLyricsSearch() {
+	ti := textinput.New()
+	ti.Placeholder = "Artist title..."
+	ti.CharLimit = 156
+	ti.Width = 40
+	ti.SetValue(cleanArtist(m.selected.author) + " " + cleanString(m.selected.title))
+	ti.Focus()
+
+	m.lyricsInput = ti
+	m.lyricsErr = nil
+	m.lyricsSearching = false
+	m.state = stateLyricsSearch
+}
+
+// resumePlayingView returns to the playing screen and makes sure the lyric ticker runs
+func (m *model) resumePlayingView() tea.Cmd {
+	m.state = statePlaying
+	m.syncPosition()
+	return m.startTicker()
+}
+
+func (m model) updateLyricsSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch m.state {
+	case stateLyricsSearch:
+		switch msg.String() {
+		case "esc":
+			searchJob.stop()
+			m.lyricsSearching = false
+			return m, m.resumePlayingView()
+		case "enter":
+			if m.lyricsSearching {
+				return m, nil
+			}
+			m.lyricsSearching = true
+			m.lyricsErr = nil
+			return m, tea.Batch(m.spinner.Tick, searchLyricsManual(searchJob.start(), m.lyricsInput.Value()))
+		}
+		var cmd tea.Cmd
+		m.lyricsInput, cmd = m.lyricsInput.Update(msg)
+		return m, cmd
+
+	case stateLyricsResults:
+		if m.lyricsList.FilterState() == list.Filtering {
+			break
+		}
+		switch msg.String() {
+		case "esc", "q":
+			m.state = stateLyricsSearch
+			return m, nil
+		case "enter":
+			if c, ok := m.lyricsList.SelectedItem().(lyricCandidate); ok {
+				m.playback.lyrics = parseLRC(c.result.SyncedLyrics)
+				m.playback.lyricsUnsynced = false
+				lyricsCache.put(m.selected.id, m.playback.lyrics)
+				m.playback.currentLyricIndex = -1
+				return m, m.resumePlayingView()
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.lyricsList, cmd = m.lyricsList.Update(msg)
+	return m, cmd
+}
+
+func (m model) viewLyricsSearch() string {
+	if m.state == stateLyricsResults {
+		return docStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				m.lyricsList.View(),
+				helpStyle.Render("\n  ENTER: Use these lyrics  •  ESC: Edit query"),
+			),
+		)
+	}
+
+	status := helpStyle.Render("ENTER: Search LRCLIB  •  ESC: Back to player")
+	if m.lyricsSearching {
+		status = fmt.Sprintf("%s Searching LRCLIB...", m.spinner.View())
+	} else if m.lyricsErr != nil {
+		status = errorStyle.Render(m.lyricsErr.Error())
+	}
+	return fmt.Sprintf("\n  %s\n\n  %s\n\n  %s",
+		titleStyle.Render("Search Lyrics"),
+		m.lyricsInput.View(),
+		status,
+	)
+}
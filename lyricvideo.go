@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lyricVideo describes a sing-along video rendered from a local track and its .lrc
+type lyricVideo struct {
+	audio         string
+	out           string
+	from, to      time.Duration // Clip of the track to render; to is the end of the track if zero
+	width, height int
+	font          string // Font file for drawtext, fontconfig's default if empty
+}
+
+// lyricVideoBackground is the backdrop colour of tracks without cover art
+const lyricVideoBackground = "0x1e1e2e"
+
+// renderLyricVideo renders the lyric video with ffmpeg: the blurred cover as
+// background, the title on top and the current lyric line in the middle with
+// the next one below it. report gets the fraction done.
+func renderLyricVideo(ctx context.Context, v lyricVideo, report func(float64)) error {
+	lyrics := localLyrics(v.audio)
+	if len(lyrics) == 0 {
+		return fmt.Errorf("no synced lyrics: %s needs a .lrc file next to it", filepath.Base(v.audio))
+	}
+	tags, seconds, err := probeTags(v.audio)
+	if err != nil {
+		return fmt.Errorf("reading %s: %v", v.audio, err)
+	}
+	end := time.Duration(seconds) * time.Second
+	if v.to > 0 && v.to < end {
+		end = v.to
+	}
+	if v.from >= end {
+		return fmt.Errorf("the clip starts after the track ends (%s)", formatDuration(end))
+	}
+	length := end - v.from
+
+	work, err := os.MkdirTemp("", "gomusic-video-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+
+	// Text goes through files so lyrics need no drawtext escaping
+	textFile := func(name, text string) (string, error) {
+		path := filepath.Join(work, name)
+		return path, os.WriteFile(path, []byte(text), 0644)
+	}
+	font := ""
+	if v.font != "" {
+		font = ":fontfile=" + filterQuote(v.font)
+	}
+	var filters []string
+	args := []string{"-y", "-loglevel", "error", "-progress", "pipe:1", "-nostats",
+		"-ss", ffmpegTime(v.from), "-t", ffmpegTime(length), "-i", v.audio}
+	if cover, err := cachedEmbeddedArt(localCoverKey(v.audio), v.audio); err == nil {
+		args = append(args, "-loop", "1", "-framerate", "25", "-i", cover)
+		filters = append(filters, fmt.Sprintf(
+			"[1:v]scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d,boxblur=20:2,eq=brightness=-0.3,setsar=1[bg0]",
+			v.width, v.height, v.width, v.height))
+	} else {
+		args = append(args, "-f", "lavfi", "-i", fmt.Sprintf("color=c=%s:s=%dx%d:r=25", lyricVideoBackground, v.width, v.height))
+		filters = append(filters, "[1:v]null[bg0]")
+	}
+
+	title := stripTitleNoise(tags["title"])
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(v.audio), filepath.Ext(v.audio))
+	}
+	if tags["artist"] != "" {
+		title = tags["artist"] + " – " + title
+	}
+	titlePath, err := textFile("title.txt", title)
+	if err != nil {
+		return err
+	}
+	big, small := v.height/14, v.height/24
+	steps := []string{fmt.Sprintf("drawtext=textfile=%s:expansion=none%s:fontsize=%d:fontcolor=white@0.8:x=(w-text_w)/2:y=h/12",
+		filterQuote(titlePath), font, small)}
+
+	for i, line := range lyrics {
+		start := line.Timestamp - v.from
+		stop := length
+		if i+1 < len(lyrics) {
+			stop = lyrics[i+1].Timestamp - v.from
+		}
+		if stop <= 0 || start >= length || strings.TrimSpace(line.Text) == "" {
+			continue
+		}
+		enable := fmt.Sprintf("between(t\\,%.3f\\,%.3f)", max(start, 0).Seconds(), stop.Seconds())
+		current, err := textFile(fmt.Sprintf("line%d.txt", i), line.Text)
+		if err != nil {
+			return err
+		}
+		steps = append(steps, fmt.Sprintf(
+			"drawtext=textfile=%s:expansion=none%s:fontsize=%d:fontcolor=white:borderw=3:bordercolor=black@0.6:x=(w-text_w)/2:y=(h-text_h)/2:enable=%s",
+			filterQuote(current), font, big, enable))
+		if i+1 < len(lyrics) && strings.TrimSpace(lyrics[i+1].Text) != "" {
+			next, err := textFile(fmt.Sprintf("next%d.txt", i), lyrics[i+1].Text)
+			if err != nil {
+				return err
+			}
+			steps = append(steps, fmt.Sprintf(
+				"drawtext=textfile=%s:expansion=none%s:fontsize=%d:fontcolor=white@0.55:x=(w-text_w)/2:y=h/2+%d:enable=%s",
+				filterQuote(next), font, small, big, enable))
+		}
+	}
+	filters = append(filters, "[bg0]"+strings.Join(steps, ",")+",format=yuv420p[v]")
+
+	script := filepath.Join(work, "filters.txt")
+	if err := os.WriteFile(script, []byte(strings.Join(filters, ";\n")), 0644); err != nil {
+		return err
+	}
+	tmp := v.out + ".part"
+	defer os.Remove(tmp)
+	args = append(args,
+		"-filter_complex_script", script,
+		"-map", "[v]", "-map", "0:a",
+		"-c:v", "libx264", "-preset", "veryfast", "-tune", "stillimage", "-r", "25",
+		"-c:a", "aac", "-b:a", "192k",
+		"-t", ffmpegTime(length), "-movflags", "+faststart",
+		"-f", "mp4", tmp)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("ffmpeg: %v", err)
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if us, ok := strings.CutPrefix(scanner.Text(), "out_time_us="); ok {
+			if n, err := strconv.ParseInt(us, 10, 64); err == nil {
+				report(math.Min(1, float64(time.Duration(n)*time.Microsecond)/float64(length)))
+			}
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("ffmpeg: %s", msg)
+		}
+		return fmt.Errorf("ffmpeg: %v", err)
+	}
+	return os.Rename(tmp, v.out)
+}
+
+// filterQuote quotes a value for an ffmpeg filtergraph
+func filterQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runVideo implements `gomusic video FILE`: render a lyric video of a
+// downloaded track with its synced lyrics, for karaoke and practice clips
+func runVideo(args []string) int {
+	usage := func() int {
+		fmt.Fprintln(os.Stderr, "usage: gomusic video [--from m:ss] [--to m:ss] [--size 1280x720] [--font FILE] [-o OUT.mp4] TRACK")
+		return 2
+	}
+	v := lyricVideo{width: 1280, height: 720}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			if v.audio != "" {
+				return usage()
+			}
+			v.audio = arg
+			continue
+		}
+		if i+1 >= len(args) {
+			return usage()
+		}
+		i++
+		var err error
+		switch arg {
+		case "--from":
+			v.from, err = parseOffset(args[i])
+		case "--to":
+			v.to, err = parseOffset(args[i])
+		case "--size":
+			w, h, ok := strings.Cut(args[i], "x")
+			v.width, err = strconv.Atoi(w)
+			if err == nil {
+				v.height, err = strconv.Atoi(h)
+			}
+			if !ok || err != nil || v.width < 160 || v.height < 120 || v.width%2 != 0 || v.height%2 != 0 {
+				err = fmt.Errorf("invalid size %q, use an even WIDTHxHEIGHT like 1280x720", args[i])
+			}
+		case "--font":
+			v.font = expandHome(args[i])
+		case "-o", "--output":
+			v.out = args[i]
+		default:
+			return usage()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if v.audio == "" {
+		return usage()
+	}
+	if v.to > 0 && v.to <= v.from {
+		fmt.Fprintln(os.Stderr, "--to must come after --from")
+		return 2
+	}
+	if v.out == "" {
+		v.out = strings.TrimSuffix(v.audio, filepath.Ext(v.audio)) + " (lyrics).mp4"
+	}
+
+	lastPct := -1
+	err := renderLyricVideo(appCtx, v, func(fraction float64) {
+		if pct := int(fraction * 100); pct != lastPct {
+			fmt.Fprintf(os.Stderr, "\rRendering lyric video... %3d%%", pct)
+			lastPct = pct
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\r%v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "\rSaved: %s                 \n", v.out)
+	return 0
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"image"
 	"image/jpeg"
@@ -10,6 +13,10 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -59,6 +66,20 @@ func min(a, b int) int {
 	return b
 }
 
+// min64 returns the minimum of two int64 values
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// formatDuration formats a duration as m:ss
+func formatDuration(d time.Duration) string {
+	total := int(d.Seconds())
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
 // isKittyTerminal checks if we're running in Kitty terminal
 func isKittyTerminal() bool {
 	return os.Getenv("TERM") == "xterm-kitty" || os.Getenv("KITTY_WINDOW_ID") != ""
@@ -247,6 +268,7 @@ func convertImageToASCII(imagePath string, width, height int) string {
 	chars := []rune{' ', '░', '▒', '▓', '█'}
 	
 	var result strings.Builder
+	result.Grow(width * height * 24) // Room for a true color escape per cell
 	
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
@@ -281,9 +303,13 @@ func convertImageToASCII(imagePath string, width, height int) string {
 			// Create colored character using ANSI escape codes
 			char := chars[charIndex]
 			if char != ' ' {
-				// Use RGB color for the character
-				coloredChar := fmt.Sprintf("\033[38;2;%d;%d;%dm%c\033[0m", r8, g8, b8, char)
-				result.WriteString(coloredChar)
+				// Use RGB color for the character, without fmt on this hot path
+				buf := append([]byte("\033[38;2;"), strconv.Itoa(int(r8))...)
+				buf = append(append(buf, ';'), strconv.Itoa(int(g8))...)
+				buf = append(append(buf, ';'), strconv.Itoa(int(b8))...)
+				result.Write(append(buf, 'm'))
+				result.WriteRune(char)
+				result.WriteString("\033[0m")
 			} else {
 				result.WriteRune(char)
 			}
@@ -296,35 +322,25 @@ func convertImageToASCII(imagePath string, width, height int) string {
 	return result.String()
 }
 
-// downloadAndCacheThumb downloads and caches a thumbnail for display
-func (m *model) downloadAndCacheThumb(url, path string) error {
-	// Check if file already exists
-	if _, err := os.Stat(path); err == nil {
-		return nil // File already exists
-	}
-	
-	return m.downloadThumb(url, path)
+func searchSongs(ctx context.Context, query string, filter searchFilter, lengthFilter bool) tea.Cmd {
+	return searchYTMusic(ctx, query, filter, lengthFilter)
 }
 
-func searchSongs(query string, filter searchFilter) tea.Cmd {
-	return searchYTMusic(query, filter)
+func fetchAlbumTracks(ctx context.Context, browseID string) tea.Cmd {
+	return fetchYTMusicAlbumTracks(ctx, browseID)
 }
 
-func fetchAlbumTracks(browseID string) tea.Cmd {
-	return fetchYTMusicAlbumTracks(browseID)
-}
-
-func (m *model) runDownloadConvert() {
+func (m *model) runDownloadConvert(ctx context.Context) {
 	// Validate track ID before attempting download
 	if m.selected.id == "" || len(m.selected.id) < 10 {
-		m.program.Send(errMsg(fmt.Errorf("cannot download this track - invalid track ID")))
+		m.downloadFailed(fmt.Errorf("cannot download this track - invalid track ID"))
 		return
 	}
 
 	client := youtube.Client{}
-	track, err := client.GetVideo(m.selected.id) // GetVideo works for music tracks too
+	track, err := client.GetVideoContext(ctx, m.selected.id) // GetVideo works for music tracks too
 	if err != nil {
-		m.program.Send(errMsg(err))
+		m.downloadFailed(err)
 		return
 	}
 
@@ -334,95 +350,286 @@ func (m *model) runDownloadConvert() {
 		author: track.Author,
 	})
 
-	formats := track.Formats.Type("audio")
-	if len(formats) == 0 {
-		m.program.Send(errMsg(fmt.Errorf("no audio format found")))
+	format := bestAudioFormat(track.Formats.Type("audio"), cfg.Download.StreamQuality)
+	if format == nil {
+		m.downloadFailed(fmt.Errorf("no audio format found"))
 		return
 	}
-	format := &formats[0]
+	if m.dlOptions.itag != 0 {
+		picked := track.Formats.Itag(m.dlOptions.itag)
+		if len(picked) == 0 {
+			m.downloadFailed(fmt.Errorf("stream %d is no longer offered for this track", m.dlOptions.itag))
+			return
+		}
+		format = &picked[0]
+	}
+	opts := m.dlOptions.forSource(format, cfg.Download.Passthrough)
 
-	tempAudio := "temp_audio"
-	tempThumb := "temp_thumb.jpg"
-	finalName := strings.ReplaceAll(track.Title, "/", "_") + ".mp3"
+	tempAudio := downloadTempPath(m.selected.id)
+	finalName, err := opts.outputPath(m.selected, track.Title, track.Author, releaseYear(m.selected.year, track))
+	if err != nil {
+		m.downloadFailed(err)
+		return
+	}
 
-	err = m.downloadFile(client, format, track, tempAudio, func(p float64) {
+	err = m.downloadFile(ctx, client, format, track, tempAudio, func(p float64) {
 		m.program.Send(downloadProgressMsg(p))
 	})
+	if downloadCancelled(ctx, m.selected.id, tempAudio) {
+		return
+	}
 	if err != nil {
-		m.program.Send(errMsg(err))
+		m.downloadFailed(err)
 		return
 	}
 
 	m.program.Send(convertMsg{})
-	err = m.downloadThumb(m.selected.thumb, tempThumb)
-	if err != nil {
-		// Silently continue if thumb download fails
+	// Shares the cover cached by playback of this track
+	tempThumb, thumbErr := m.cachedArt(ctx, m.selected.id, m.selected.thumb)
+	if len(opts.tracklist) > 0 {
+		m.splitTracklist(ctx, opts, track, tempAudio, tempThumb, thumbErr == nil)
+		return
 	}
 
-	args := []string{
-		"-y",
-		"-i", tempAudio,
-		"-i", tempThumb,
-		"-map", "0:0",
-		"-map", "1:0",
-		"-c:a", "libmp3lame",
-		"-q:a", "2",
-		"-id3v2_version", "3",
-		"-metadata:s:v", "title=\"Album cover\"",
-		"-metadata:s:v", "comment=\"Cover (Front)\"",
-		"-metadata", "title=" + track.Title,
-		"-metadata", "artist=" + track.Author,
-		finalName,
-	}
-
-	cmd := exec.Command("ffmpeg", args...)
+	// Tags and cover come from the sources [metadata] ranks first
+	base := trackMetadata{Title: track.Title, Artist: track.Author, Album: m.selected.album, Year: releaseYear(m.selected.year, track), Art: m.selected.thumb}
+	meta := resolveMetadata(ctx, m.selected, base, metadataFields)
+	if cover, ok := m.metadataCover(ctx, meta, base); ok {
+		tempThumb = cover
+	}
+
+	args := []string{"-y"}
+	args = append(args, opts.inputArgs()...)
+	args = append(args, "-i", tempAudio, "-i", tempThumb)
+
+	// Long videos keep their chapter markers so players can jump between songs
+	tempChapters := fmt.Sprintf("temp_chapters_%s.txt", m.selected.id)
+	chapters := opts.adjustChapters(parseChapters(track.Description))
+	if len(chapters) > 0 && writeChapterMetadata(tempChapters, chapters, opts.outputDuration(track.Duration)) == nil {
+		args = append(args, "-i", tempChapters, "-map_chapters", "2")
+		defer os.Remove(tempChapters)
+	}
+
+	args = append(args, opts.mapArgs(true)...)
+	args = append(args, opts.codecArgs()...)
+	args = append(args,
+		"-metadata", "title="+meta.Title,
+		"-metadata", "artist="+meta.Artist,
+	)
+	if meta.Album != "" {
+		args = append(args, "-metadata", "album="+meta.Album)
+	}
+	args = append(args, idTagArgs(m.selected.primaryArtistID(), m.selected.albumID)...)
+	args = append(args, sourceTagArgs(m.selected.id)...)
+	args = append(args, opts.filterArgs(track.Duration)...)
+	if meta.Year != "" {
+		args = append(args, "-metadata", "date="+meta.Year)
+	}
+	// Reuses lyrics already fetched for playback of this track
+	lyrics, _ := lyricsCache.get(ctx, m.selected.id, track.Title, track.Author, int(track.Duration.Seconds()))
+	lyrics = opts.adjustLyrics(lyrics)
+	if len(lyrics) > 0 {
+		args = append(args, "-metadata", "lyrics="+plainLyrics(lyrics))
+	}
+	args = append(args, finalName)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 	if err := cmd.Run(); err != nil {
-		m.program.Send(errMsg(fmt.Errorf("FFmpeg failed: %v", err)))
+		if downloadCancelled(ctx, m.selected.id, tempAudio, finalName) {
+			return
+		}
+		m.downloadFailed(fmt.Errorf("FFmpeg failed: %w", err))
 		return
 	}
+	if err := tagSourceURL(finalName, m.selected.id); err != nil {
+		fmt.Fprintf(os.Stderr, "Error tagging source URL: %v\n", err)
+	}
+	if len(lyrics) > 0 {
+		writeLRCSidecar(finalName, lyrics)
+	}
 
 	os.Remove(tempAudio)
-	os.Remove(tempThumb)
+	removePausedDownload(m.selected.id)
 
-	m.program.Send(doneMsg(finalName))
+	if err := verifyAudio(finalName, opts.outputDuration(track.Duration)); err != nil {
+		session.failed()
+		m.program.Send(verifyFailedMsg{fileName: finalName, err: err})
+		return
+	}
+
+	session.trackDone()
+	recordDownload(m.selected.id)
+	m.program.Send(doneMsg{path: finalName})
 }
 
-func (m *model) downloadFile(client youtube.Client, format *youtube.Format, video *youtube.Video, path string, onProgress func(float64)) error {
-	stream, size, err := client.GetStream(video, format)
+// downloadFailed counts a failed single-track download and reports it
+func (m *model) downloadFailed(err error) {
+	if errors.Is(err, context.Canceled) {
+		return // Called off, see cancelDownload
+	}
+	session.failed()
+	m.program.Send(errMsg(err))
+}
+
+// downloadFile fetches the audio stream into path using ranged requests.
+// Data already present in path is kept, so a paused or interrupted download
+// continues from its byte offset instead of starting over. When the stream
+// stalls or the URL expires mid-transfer, a fresh URL is resolved and the
+// transfer picks up where it stopped.
+func (m *model) downloadFile(ctx context.Context, client youtube.Client, format *youtube.Format, video *youtube.Video, path string, onProgress func(float64)) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
 	if err != nil {
 		return err
 	}
-	defer stream.Close()
+	downloaded := info.Size()
+	size := format.ContentLength
 
-	file, err := os.Create(path)
+	start, startOffset := time.Now(), downloaded
+	defer func() {
+		session.addTransfer(downloaded-startOffset, time.Since(start))
+	}()
+
+	streamURL, err := client.GetStreamURLContext(ctx, video, format)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	var downloaded int64
+	refreshes := 0
 	buf := make([]byte, 32*1024)
+	for size == 0 || downloaded < size {
+		if m.download.isPaused() {
+			m.program.Send(downloadPausedMsg{offset: downloaded})
+			if err := m.download.wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		before := downloaded
+		finished, err := m.downloadRange(ctx, streamURL, file, buf, &downloaded, size, onProgress)
+		if downloaded > before {
+			refreshes = 0
+		}
+		if err != nil {
+			var stale *staleStreamError
+			if !errors.As(err, &stale) || refreshes >= maxStreamRefreshes {
+				return err
+			}
+			refreshes++
+			if streamURL, err = refreshStreamURL(ctx, client, video, format); err != nil {
+				return err
+			}
+			continue
+		}
+		if finished {
+			break
+		}
+	}
+	return nil
+}
+
+// downloadRange requests the next chunk from downloaded onwards and appends it
+// to file. Expired URLs, throttled or stalled transfers are reported as
+// *staleStreamError so the caller can refresh the URL and retry.
+func (m *model) downloadRange(parent context.Context, streamURL string, file *os.File, buf []byte, downloaded *int64, size int64, onProgress func(float64)) (bool, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	stall := time.AfterFunc(stallTimeout, cancel)
+	defer stall.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, streamURL, nil)
+	if err != nil {
+		return false, err
+	}
+	if size > 0 {
+		end := min64(*downloaded+downloadChunkSize, size) - 1
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", *downloaded, end))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", *downloaded))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if parent.Err() != nil {
+			return false, parent.Err()
+		}
+		if ctx.Err() != nil {
+			return false, &staleStreamError{reason: "no response"}
+		}
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+	case http.StatusForbidden, http.StatusGone:
+		return false, &staleStreamError{reason: fmt.Sprintf("HTTP %d", resp.StatusCode)}
+	default:
+		return false, youtube.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	started := time.Now()
+	var received int64
 	for {
-		n, err := stream.Read(buf)
+		n, err := resp.Body.Read(buf)
 		if n > 0 {
-			file.Write(buf[:n])
-			downloaded += int64(n)
+			stall.Reset(stallTimeout)
+			if _, werr := file.Write(buf[:n]); werr != nil {
+				return false, werr
+			}
+			*downloaded += int64(n)
+			received += int64(n)
 			if size > 0 {
-				onProgress(float64(downloaded) / float64(size))
+				onProgress(float64(*downloaded) / float64(size))
 			}
 		}
 		if err == io.EOF {
-			break
+			// Without a known size, the end of an open-ended range is the end of the stream
+			return size == 0, nil
 		}
 		if err != nil {
-			return err
+			if parent.Err() != nil {
+				return false, parent.Err()
+			}
+			if ctx.Err() != nil {
+				return false, &staleStreamError{reason: "stalled"}
+			}
+			return false, &staleStreamError{reason: err.Error()}
+		}
+		if m.download.isPaused() {
+			return false, nil
+		}
+		if elapsed := time.Since(started); elapsed > throttleWindow && float64(received)/elapsed.Seconds() < minStreamRate {
+			return false, &staleStreamError{reason: "throttled"}
 		}
 	}
-	return nil
 }
 
-func (m *model) downloadThumb(url, path string) error {
-	resp, err := http.Get(url)
+// releasedOnPattern matches the release date line of auto-generated YouTube Music uploads
+var releasedOnPattern = regexp.MustCompile(`Released on: (\d{4})-\d{2}-\d{2}`)
+
+// releaseYear prefers the year known from album metadata and falls back to the
+// "Released on" line of the video description
+func releaseYear(known string, video *youtube.Video) string {
+	if known != "" {
+		return known
+	}
+	if match := releasedOnPattern.FindStringSubmatch(video.Description); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+func (m *model) downloadThumb(ctx context.Context, url, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := apiClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -436,14 +643,70 @@ func (m *model) downloadThumb(url, path string) error {
 	return err
 }
 
-func (m *model) runDownloadAlbum() {
-	if len(m.albumTracks) == 0 {
-		m.program.Send(errMsg(fmt.Errorf("no tracks found in album")))
-		return
+// setAlbumTracks replaces the album tracklist and rebuilds its tree view
+func (m *model) setAlbumTracks(tracks []songItem) {
+	// Back on the same album, the cursor stays on its track
+	var previous songItem
+	if items := m.albumTrackList.Items(); len(items) > 0 {
+		if header, ok := items[0].(songItem); ok && header.id == m.currentAlbum.id {
+			previous, _ = selectedSong(m.albumTrackList)
+		}
+	}
+	m.albumTracks = tracks
+	// Create list of tracks for viewing with tree structure
+	var trackItems []list.Item
+
+	// Add album header with download instruction
+	albumHeader := songItem{
+		id:      m.currentAlbum.id,
+		title:   fmt.Sprintf("📀 %s (Press ENTER to download full album)", m.currentAlbum.title),
+		author:  m.currentAlbum.author,
+		isAlbum: true,
+	}
+	trackItems = append(trackItems, albumHeader)
+
+	// Add tracks with tree view formatting, numbered as they will be downloaded
+	number := 0
+	for i, track := range tracks {
+		// Create a copy for display with tree structure
+		displayTrack := track
+		label := "--."
+		if m.albumExcluded[track.id] {
+			displayTrack.title = "✗ " + track.title
+		} else {
+			number++
+			label = fmt.Sprintf("%02d.", number)
+		}
+		// Use tree characters for visual hierarchy
+		if i == len(tracks)-1 {
+			// Last track
+			displayTrack.title = fmt.Sprintf("└── %s %s", label, displayTrack.title)
+		} else {
+			// Middle tracks
+			displayTrack.title = fmt.Sprintf("├── %s %s", label, displayTrack.title)
+		}
+		trackItems = append(trackItems, displayTrack)
 	}
 
-	// Clean up album name for folder creation
-	albumName := m.currentAlbum.title
+	m.albumTrackList = list.New(trackItems, list.NewDefaultDelegate(), m.width-4, m.height-8)
+	m.albumTrackList.Title = fmt.Sprintf("Album: %s (%d tracks)", m.currentAlbum.title, len(tracks))
+	if number < len(tracks) {
+		m.albumTrackList.Title = fmt.Sprintf("Album: %s (%d of %d tracks)", m.currentAlbum.title, number, len(tracks))
+	}
+	if len(m.filteredTracks) > 0 {
+		m.albumTrackList.Title += fmt.Sprintf(" • %d filtered", len(m.filteredTracks))
+	}
+	if previous.id != "" {
+		selectItem(&m.albumTrackList, func(item list.Item) bool {
+			track, ok := item.(songItem)
+			return ok && track.id == previous.id
+		})
+	}
+}
+
+// albumDirName cleans up an album title for its folder and tags
+func albumDirName(album songItem) string {
+	albumName := album.title
 	// Remove year from title if present
 	if strings.Contains(albumName, "(") && strings.Contains(albumName, ")") {
 		parts := strings.Split(albumName, "(")
@@ -452,43 +715,67 @@ func (m *model) runDownloadAlbum() {
 	// Remove "Topic" and other suffixes
 	albumName = strings.TrimSuffix(albumName, " - Topic")
 	albumName = strings.TrimSuffix(albumName, "Topic")
-	albumName = strings.TrimSpace(albumName)
-	
-	// Create safe folder name
-	albumDir := strings.ReplaceAll(albumName, "/", "_")
-	albumDir = strings.ReplaceAll(albumDir, "\\", "_")
-	albumDir = strings.ReplaceAll(albumDir, ":", "_")
-	albumDir = strings.ReplaceAll(albumDir, "*", "_")
-	albumDir = strings.ReplaceAll(albumDir, "?", "_")
-	albumDir = strings.ReplaceAll(albumDir, "\"", "_")
-	albumDir = strings.ReplaceAll(albumDir, "<", "_")
-	albumDir = strings.ReplaceAll(albumDir, ">", "_")
-	albumDir = strings.ReplaceAll(albumDir, "|", "_")
-	
+	return strings.TrimSpace(albumName)
+}
+
+func (m *model) runDownloadAlbum(ctx context.Context) {
+	if len(m.downloadTracks) == 0 {
+		m.program.Send(errMsg(fmt.Errorf("no tracks found in album")))
+		return
+	}
+
+	albumName := albumDirName(m.currentAlbum)
+
+	// Create safe folder name, kept apart from a same-named album by another artist
+	albumDir := m.albumDir(albumName)
+
 	err := os.MkdirAll(albumDir, 0755)
 	if err != nil {
 		m.program.Send(errMsg(fmt.Errorf("failed to create album directory: %v", err)))
 		return
 	}
 
-	totalTracks := len(m.albumTracks)
+	totalTracks := len(m.downloadTracks)
 	client := youtube.Client{}
 
-	// Download album cover if available
-	albumThumb := "temp_album_thumb.jpg"
+	// Download album cover if available, or reuse it from the art cache
+	var albumThumb string
 	if m.currentAlbum.thumb != "" {
-		err = m.downloadThumb(m.currentAlbum.thumb, albumThumb)
+		albumThumb, err = m.cachedArt(ctx, m.currentAlbum.id, m.currentAlbum.thumb)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error downloading album thumb: %v\n", err)
 		}
 	}
 
-	// Download each track
-	for i, track := range m.albumTracks {
+	albumMeta, albumThumb := m.albumMetadata(ctx, albumThumb)
+
+	// Record progress so an album cut short by a crash can be resumed on the next launch
+	// A copy, as the UI reads m.albumResume until the download reports back
+	record := newAlbumDownload(m.currentAlbum, m.downloadTracks)
+	if r := m.albumResume; r != nil && r.Album.ID == m.currentAlbum.id {
+		record = r.clone()
+	}
+	if err := saveState(albumDownloadKey, record); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving album progress: %v\n", err)
+	}
+
+	// Download each track, re-downloading once if the result fails verification.
+	// Requests are spaced out and back off when YouTube starts throttling; a
+	// throttled request is retried after the cool-down without using up an attempt.
+	pace := newPacer(cfg.Pacing)
+	var flagged, failed []string
+	for i, track := range m.downloadTracks {
 		// Skip tracks with invalid IDs
 		if track.id == "" || len(track.id) < 10 {
 			continue
 		}
+		// Saved before the last run was interrupted
+		if record.done(track.id) {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
 
 		m.program.Send(albumTrackProgressMsg{
 			current: i + 1,
@@ -496,111 +783,318 @@ func (m *model) runDownloadAlbum() {
 			title:   track.title,
 		})
 
-		// Get track details
-		trackDetails, err := client.GetVideo(track.id)
-		if err != nil {
-			continue
+		var err error
+		for attempt := 0; attempt < 2 && ctx.Err() == nil; attempt++ {
+			pace.wait()
+			err = m.downloadAlbumTrack(ctx, client, i, track, albumDir, albumName, albumThumb, albumMeta)
+			if isThrottled(err) {
+				m.program.Send(cooldownMsg{until: pace.coolDown()})
+				attempt--
+				continue
+			}
+			pace.succeeded()
+			if verr, ok := err.(*verifyError); ok {
+				if attempt == 1 {
+					flagged = append(flagged, fmt.Sprintf("%02d - %s (%s)", i+1, track.title, verr.reason))
+				}
+				continue
+			}
+			break
 		}
-
-		formats := trackDetails.Formats.Type("audio")
-		if len(formats) == 0 {
-			continue
+		if ctx.Err() != nil {
+			break
 		}
-		format := &formats[0]
-
-		tempAudio := fmt.Sprintf("temp_audio_%d", i)
-		safeTitle := strings.ReplaceAll(trackDetails.Title, "/", "_")
-		safeTitle = strings.ReplaceAll(safeTitle, "\\", "_")
-		safeTitle = strings.ReplaceAll(safeTitle, ":", "_")
-		finalName := fmt.Sprintf("%s/%02d - %s.mp3", albumDir, i+1, safeTitle)
-
-		err = m.downloadFile(client, format, trackDetails, tempAudio, func(p float64) {
-			// Calculate overall album progress: (completed tracks + current track progress) / total tracks
-			overallProgress := (float64(i) + p) / float64(totalTracks)
-			m.program.Send(downloadProgressMsg(overallProgress))
-		})
 		if err != nil {
-			os.Remove(tempAudio)
-			continue
+			session.failed()
+			if _, ok := err.(*verifyError); !ok {
+				failed = append(failed, fmt.Sprintf("%02d - %s (%v)", i+1, track.title, err))
+			}
+		} else {
+			session.trackDone()
+			recordDownload(track.id)
+			if err := record.complete(track.id); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving album progress: %v\n", err)
+			}
+		}
+	}
+	// Called off; after a quit the progress record lets the album be resumed
+	// later, after Esc it is forgotten
+	if ctx.Err() != nil {
+		if appCtx.Err() == nil {
+			removeState(albumDownloadKey)
 		}
+		return
+	}
+	removeState(albumDownloadKey)
 
-		// Convert to MP3 with metadata
-		args := []string{
-			"-y",
-			"-i", tempAudio,
+	summary := fmt.Sprintf("%d tracks", totalTracks)
+	if len(failed) > 0 {
+		summary += fmt.Sprintf("\n  %s %d track(s) failed to download:\n    %s",
+			errorStyle.Render("Warning:"), len(failed), strings.Join(failed, "\n    "))
+	}
+	if len(flagged) > 0 {
+		summary += fmt.Sprintf("\n  %s %d track(s) failed verification after re-download:\n    %s",
+			errorStyle.Render("Warning:"), len(flagged), strings.Join(flagged, "\n    "))
+	}
+	gaps := m.findAlbumGaps(albumDir, albumName)
+	if len(gaps) > 0 {
+		summary += describeGaps(gaps, totalTracks)
+	}
+	if cfg.Download.NFO {
+		if err := writeAlbumNFO(albumDir, m.currentAlbum, m.downloadTracks, albumThumb); err != nil {
+			summary += fmt.Sprintf("\n  %s writing album.nfo failed: %v", errorStyle.Render("Warning:"), err)
 		}
-		
-		// Add album cover if available
-		if m.currentAlbum.thumb != "" {
-			args = append(args, "-i", albumThumb, "-map", "0:0", "-map", "1:0")
+	}
+	if m.albumZip {
+		if zipPath, err := zipAlbum(ctx, albumDir, albumName, albumThumb); err != nil {
+			summary += fmt.Sprintf("\n  %s zip archive failed: %v", errorStyle.Render("Warning:"), err)
 		} else {
-			args = append(args, "-map", "0:0")
+			summary += "\n  Zip: " + zipPath
 		}
-		
-		args = append(args,
-			"-c:a", "libmp3lame",
-			"-q:a", "2",
-			"-id3v2_version", "3",
-		)
-		
-		// Add album cover metadata if available
-		if m.currentAlbum.thumb != "" {
-			args = append(args,
-				"-metadata:s:v", "title=\"Album cover\"",
-				"-metadata:s:v", "comment=\"Cover (Front)\"",
-			)
+	}
+	session.albumDone()
+	m.program.Send(doneMsg{path: albumDir, detail: summary, gaps: gaps})
+}
+
+// albumMetadata resolves the tags the album's tracks share, and the cover
+// file that goes with them. Playlists get nil, their tracks keep their own.
+func (m *model) albumMetadata(ctx context.Context, albumThumb string) (*trackMetadata, string) {
+	if m.currentAlbum.isPlaylist() || len(m.downloadTracks) == 0 {
+		return nil, albumThumb
+	}
+	base := trackMetadata{Album: m.currentAlbum.title, Year: m.currentAlbum.year, Art: m.currentAlbum.thumb}
+	meta, cover := m.resolveAlbumMetadata(ctx, m.downloadTracks[0], base, albumThumb)
+	return &meta, cover
+}
+
+// albumDir returns the folder an album download goes to: a folder of its own
+// in the library, or with download.template the folder of its first track
+func (m *model) albumDir(albumName string) string {
+	if cfg.Download.Template == "" || len(m.downloadTracks) == 0 {
+		return albumFolder(libraryDir(), albumName, m.currentAlbum)
+	}
+	first := m.downloadTracks[0]
+	return filepath.Dir(m.albumTrackPath("", albumName, 0, first.title, first.author, m.albumOptions().container().ext))
+}
+
+// albumTrackPath returns where track number i of the album is saved as a file with extension ext
+func (m *model) albumTrackPath(albumDir, albumName string, i int, title, artist, ext string) string {
+	if cfg.Download.Template == "" {
+		return safeJoin(albumDir, fmt.Sprintf("%02d - %s%s", i+1, title, ext))
+	}
+	return templatePath(libraryDir(), templateValues(title, artist, m.currentAlbum.author, albumName, i+1, m.currentAlbum.year, strings.TrimPrefix(ext, ".")))
+}
+
+// albumOptions are the download options of album tracks: the format chosen on
+// the confirmation, download.format and download.quality otherwise
+func (m *model) albumOptions() downloadOptions {
+	return downloadOptions{format: m.albumFormat}
+}
+
+// downloadAlbumTrack downloads, converts and verifies track number i of the
+// album, tagged with the album's shared tags albumMeta (nil for playlists)
+func (m *model) downloadAlbumTrack(ctx context.Context, client youtube.Client, i int, track songItem, albumDir, albumName, albumThumb string, albumMeta *trackMetadata) error {
+	totalTracks := len(m.downloadTracks)
+
+	// Get track details
+	trackDetails, err := client.GetVideoContext(ctx, track.id)
+	if err != nil {
+		return err
+	}
+
+	format := bestAudioFormat(trackDetails.Formats.Type("audio"), cfg.Download.StreamQuality)
+	if format == nil {
+		return fmt.Errorf("no audio format found")
+	}
+
+	opts := m.albumOptions().forSource(format, m.albumPassthrough)
+
+	tempAudio := fmt.Sprintf("temp_audio_%d", i)
+	finalName := m.albumTrackPath(albumDir, albumName, i, trackDetails.Title, track.author, opts.container().ext)
+	if err := os.MkdirAll(filepath.Dir(finalName), 0755); err != nil {
+		return err
+	}
+
+	err = m.downloadFile(ctx, client, format, trackDetails, tempAudio, func(p float64) {
+		// Calculate overall album progress: (completed tracks + current track progress) / total tracks
+		overallProgress := (float64(i) + p) / float64(totalTracks)
+		m.program.Send(downloadProgressMsg(overallProgress))
+	})
+	if err != nil {
+		os.Remove(tempAudio)
+		return err
+	}
+
+	// Convert to the album format with metadata
+	args := []string{
+		"-y",
+		"-i", tempAudio,
+	}
+
+	// Playlist tracks keep their own album, artist and cover; the playlist
+	// only names the folder and numbers the tracks
+	release := m.currentAlbum
+	cover := albumThumb
+	if m.currentAlbum.isPlaylist() {
+		release = songItem{id: track.albumID, title: cmp.Or(track.album, albumName), author: primaryArtist(track.author), thumb: track.thumb}
+		if track.thumb != "" {
+			if cover, err = m.cachedArt(ctx, track.id, track.thumb); err != nil {
+				release.thumb = ""
+			}
 		}
-		
-		args = append(args,
-			"-metadata", "title=" + trackDetails.Title,
-			"-metadata", "artist=" + trackDetails.Author,
-			"-metadata", "album=" + albumName,
-			"-metadata", "track=" + fmt.Sprintf("%d/%d", i+1, totalTracks),
-			finalName,
-		)
+	}
 
-		cmd := exec.Command("ffmpeg", args...)
-		if err := cmd.Run(); err != nil {
-			os.Remove(tempAudio)
-			continue
+	// Tags and cover come from the sources [metadata] ranks first; those of
+	// an album were resolved for all its tracks up front
+	base := trackMetadata{Title: trackDetails.Title, Artist: trackDetails.Author, Album: release.title, Year: releaseYear(release.year, trackDetails), Art: release.thumb}
+	var meta trackMetadata
+	if albumMeta != nil {
+		meta = resolveMetadata(ctx, track, base, trackFields)
+		meta.Album, meta.Year = albumMeta.Album, cmp.Or(albumMeta.Year, meta.Year)
+		release.thumb = albumMeta.Art
+	} else {
+		meta = resolveMetadata(ctx, track, base, metadataFields)
+		if path, ok := m.metadataCover(ctx, meta, base); ok {
+			cover, release.thumb = path, meta.Art
 		}
+	}
 
-		os.Remove(tempAudio)
+	// Add album cover if available
+	if release.thumb != "" {
+		args = append(args, "-i", cover)
+	}
+	args = append(args, opts.mapArgs(release.thumb != "")...)
+	args = append(args, opts.codecArgs()...)
+
+	args = append(args,
+		"-metadata", "title="+meta.Title,
+		"-metadata", "artist="+meta.Artist,
+		"-metadata", "album="+meta.Album,
+		"-metadata", "track="+fmt.Sprintf("%d/%d", i+1, totalTracks),
+		"-metadata", "album_artist="+release.author,
+	)
+	artistID := track.primaryArtistID()
+	if artistID == "" {
+		artistID = release.primaryArtistID()
+	}
+	args = append(args, idTagArgs(artistID, release.id)...)
+	args = append(args, sourceTagArgs(track.id)...)
+	if meta.Year != "" {
+		args = append(args, "-metadata", "date="+meta.Year)
+	}
+	if !m.currentAlbum.isPlaylist() && m.currentAlbum.category != "" {
+		args = append(args, "-metadata", "releasetype="+strings.ToLower(m.currentAlbum.category))
+	}
+	lyrics, _ := lyricsCache.get(ctx, track.id, trackDetails.Title, trackDetails.Author, int(trackDetails.Duration.Seconds()))
+	if len(lyrics) > 0 {
+		args = append(args, "-metadata", "lyrics="+plainLyrics(lyrics))
 	}
+	args = append(args, finalName)
 
-	// Clean up album thumb
-	if m.currentAlbum.thumb != "" {
-		os.Remove(albumThumb)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	err = cmd.Run()
+	os.Remove(tempAudio)
+	if err != nil {
+		if ctx.Err() != nil {
+			os.Remove(finalName) // Cut off mid-conversion
+		}
+		return err
 	}
-	
-	m.program.Send(doneMsg(fmt.Sprintf("Album: %s (%d tracks)", albumDir, totalTracks)))
+	if err := tagSourceURL(finalName, track.id); err != nil {
+		fmt.Fprintf(os.Stderr, "Error tagging source URL: %v\n", err)
+	}
+	if len(lyrics) > 0 {
+		writeLRCSidecar(finalName, lyrics)
+	}
+
+	return verifyAudio(finalName, trackDetails.Duration)
 }
 
 // --- Bubble Tea Methods ---
 
 func (m model) Init() tea.Cmd {
-	return textinput.Blink
+	// The library scan also marks the tracks on disk as owned in results
+	cmds := []tea.Cmd{textinput.Blink, loadOwned(), scanLibrary(libraryDir())}
+	if m.queueImport != "" {
+		cmds = append(cmds, importQueue(m.queueImport))
+	}
+	if m.handoff != nil {
+		cmds = append(cmds, lookupHandoff(*m.handoff))
+	}
+	return tea.Batch(cmds...)
 }
 
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.notice = ""
+		msg, ok := m.rebindKey(msg)
+		if !ok {
+			return m, nil
+		}
+		if cmd, ok := m.playbackKey(msg.String()); ok {
+			return m, cmd
+		}
+		if msg.String() != "ctrl+c" && (m.state == stateLyricsSearch || m.state == stateLyricsResults) {
+			return m.updateLyricsSearch(msg)
+		}
+		if msg.String() != "ctrl+c" && m.state == stateLibrary {
+			return m.updateLibrary(msg)
+		}
+		if msg.String() != "ctrl+c" && m.state == stateLibrarySearch {
+			return m.updateLibrarySearch(msg)
+		}
+		if msg.String() != "ctrl+c" && m.state == stateDownloadOptions {
+			return m.updateDownloadOptions(msg)
+		}
+		if msg.String() != "ctrl+c" && m.state == stateRelatedAlbums {
+			return m.updateRelatedAlbums(msg)
+		}
+		if msg.String() != "ctrl+c" && m.state == stateFilteredTracks {
+			return m.updateFilteredTracks(msg)
+		}
+		if msg.String() != "ctrl+c" && m.state == stateSettings {
+			return m.updateSettings(msg)
+		}
+		if msg.String() != "ctrl+c" && m.state == stateConfirmAlbum {
+			return m.updateAlbumConfirm(msg)
+		}
+		if msg.String() != "ctrl+c" && m.state == statePickStream {
+			return m.updateStreamPicker(msg)
+		}
+		if msg.String() != "ctrl+c" && m.state == stateFillGaps {
+			return m.updateFillGaps(msg)
+		}
+		if msg.String() != "ctrl+c" && m.state == stateInput && m.pinsFocused {
+			return m.updatePins(msg)
+		}
+		if msg.String() != "ctrl+c" && m.state == stateFinished {
+			return m.updateFinished(msg)
+		}
+		if msg.String() != "ctrl+c" && m.state == stateTrackEnded {
+			return m.updateTrackEnded(msg)
+		}
 		switch msg.String() {
 		case "ctrl+c":
 			m.quitting = true
 			return m, tea.Quit
 		case "q":
+			if m.state == statePlaying && m.selected.path != "" {
+				m.stopPlayback()
+				m.state = stateLibrary
+				return m, nil
+			}
 			if m.state == statePlaying {
 				m.stopPlayback()
 				m.state = stateViewingAlbumTracks
 				return m, nil
 			}
 			if m.state == stateViewingAlbumTracks {
-				m.state = stateSelecting
-				m.list.ResetSelected()
+				m.albumBack()
 				return m, nil
 			}
 			if m.state == stateSelecting {
+				m.rememberSearch()
 				m.state = stateInput
 				return m, nil
 			}
@@ -608,8 +1102,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		case "enter":
 			if m.state == stateInput {
+				// Recent searches come back as they were left, without the network
+				if m.restoreSearch(newSearchKey(m.textInput.Value(), m.searchFilter, m.lengthFilter)) {
+					return m, m.previewHighlighted()
+				}
+				if cmd, ok := m.openLink(m.textInput.Value()); ok {
+					return m, cmd
+				}
+				if !m.requireNetwork("searching YouTube Music") {
+					return m, nil
+				}
 				m.state = stateSearching
-				return m, tea.Batch(m.spinner.Tick, searchSongs(m.textInput.Value(), m.searchFilter))
+				return m, tea.Batch(m.spinner.Tick, searchSongs(searchJob.start(), m.textInput.Value(), m.searchFilter, m.lengthFilter))
 			}
 			if m.state == stateSelecting {
 				item, ok := m.list.SelectedItem().(songItem)
@@ -618,19 +1122,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					if item.isAlbum {
 						// For albums, try to fetch tracks using the album title and artist
 						m.currentAlbum = item
+						m.albumFromInput = false
 						m.state = stateSearching
 						
 						// Use enhanced album track search
-						return m, tea.Batch(m.spinner.Tick, searchAlbumWithTracks(item.title, item.author))
+						return m, tea.Batch(m.spinner.Tick, searchAlbumWithTracks(searchJob.start(), item))
 					} else {
 						// Check if track has valid ID before downloading
 						if item.id == "" || len(item.id) < 10 {
 							return m, nil // Do nothing for invalid tracks
 						}
-						m.state = stateDownloading
-						go m.runDownloadConvert()
+						return m, m.startDownload(item)
 					}
-					return m, nil
 				}
 			}
 			if m.state == stateViewingAlbumTracks {
@@ -638,10 +1141,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if ok {
 					// Skip if album header is selected
 					if item.isAlbum {
-						// Download the entire album
-						m.selected = m.currentAlbum
-						m.state = stateDownloadingAlbum
-						go m.runDownloadAlbum()
+						// Download the entire album, minus any tracks switched off, once confirmed
+						tracks := m.includedTracks()
+						if len(tracks) == 0 {
+							m.notice = "All tracks are switched off; press X on a track to include it"
+							return m, nil
+						}
+						m.openAlbumConfirm(tracks)
 						return m, nil
 					}
 					// Download individual track from album
@@ -653,15 +1159,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							if origTrack.id == "" || len(origTrack.id) < 10 {
 								return m, nil // Do nothing for invalid tracks
 							}
-							m.selected = origTrack
-							m.state = stateDownloading
-							go m.runDownloadConvert()
-							return m, nil
+							return m, m.startDownload(origTrack)
 						}
 					}
 				}
 			}
 		case "p":
+			if m.state == stateDownloading || m.state == stateDownloadingAlbum {
+				if !m.download.toggle() && m.state == stateDownloading {
+					removePausedDownload(m.selected.id)
+				}
+				return m, nil
+			}
 			if m.state == stateSelecting {
 				item, ok := m.list.SelectedItem().(songItem)
 				if ok {
@@ -675,11 +1184,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						return m, nil // Do nothing for invalid tracks
 					}
 					
-					m.stopPlayback() // Cleanup any existing playback first
-					m.selected = item
-					m.state = stateLoading
-					go m.runInternalPlayback(item)
-					return m, m.spinner.Tick
+					m.queue.set([]songItem{item}, 0)
+					return m, m.startPlayback(item)
 				}
 			}
 			if m.state == stateViewingAlbumTracks {
@@ -689,38 +1195,173 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					if item.isAlbum {
 						return m, nil
 					}
-					m.stopPlayback() // Cleanup any existing playback first
 					// Find the original track (without tree prefix) from albumTracks
-					for _, origTrack := range m.albumTracks {
+					for i, origTrack := range m.albumTracks {
 						if origTrack.id == item.id {
 							// Check if track has valid ID
 							if origTrack.id == "" || len(origTrack.id) < 10 {
 								return m, nil // Do nothing for invalid tracks
 							}
-							m.selected = origTrack
-							m.state = stateLoading
-							go m.runInternalPlayback(origTrack)
-							return m, m.spinner.Tick
+							// Queue the rest of the album so playback advances automatically,
+							// passing over tracks switched off
+							queue := []songItem{origTrack}
+							for _, t := range m.albumTracks[i+1:] {
+								if !m.albumExcluded[t.id] {
+									queue = append(queue, t)
+								}
+							}
+							m.queue.set(queue, 0)
+							return m, m.startPlayback(origTrack)
 						}
 					}
 				}
 			}
-		case " ":
+		case "b":
 			if m.state == statePlaying {
-				m.togglePause()
+				m.browseBack()
 				return m, nil
 			}
-		case "s":
+		case "ctrl+p":
+			if m.playing() && !m.showsPlayback() {
+				return m, m.resumePlayingView()
+			}
+		case "l":
+			if m.state == statePlaying && m.requireNetwork("lyrics search") {
+				m.openLyricsSearch()
+				return m, textinput.Blink
+			}
+		case "i":
 			if m.state == statePlaying {
-				m.stopPlayback()
+				return m, m.toggleInfo()
+			}
+		case "+", "=":
+			if m.state == statePlaying {
+				m.changeVolume(volumeStep)
+				return m, nil
+			}
+		case "-":
+			if m.state == statePlaying {
+				m.changeVolume(-volumeStep)
+				return m, nil
+			}
+		case "m":
+			if m.state == statePlaying {
+				m.toggleMute()
+				return m, nil
+			}
+		case "e":
+			if m.state == statePlaying {
+				if path, err := m.exportQueue(); err != nil {
+					m.notice = "Queue export failed: " + err.Error()
+				} else {
+					m.notice = "Queue exported to " + path
+				}
+				return m, nil
+			}
+		case "r":
+			if m.state == stateVerifyFailed {
+				// Re-download the flagged track from scratch
+				os.Remove(m.fileName)
+				m.state = stateDownloading
+				go m.runDownloadConvert(downloadJob.start())
+				return m, nil
+			}
+		case "o":
+			if m.state == stateSelecting {
+				if item, ok := m.list.SelectedItem().(songItem); ok && !item.isAlbum && len(item.id) >= 10 {
+					return m, m.openDownloadOptions(item)
+				}
+			}
+			if m.state == stateViewingAlbumTracks {
+				item, ok := m.albumTrackList.SelectedItem().(songItem)
+				if ok && !item.isAlbum {
+					// Find the original track (without tree prefix) from albumTracks
+					for _, origTrack := range m.albumTracks {
+						if origTrack.id == item.id && len(origTrack.id) >= 10 {
+							return m, m.openDownloadOptions(origTrack)
+						}
+					}
+				}
+			}
+		case "tab":
+			if m.state == stateViewingAlbumTracks && m.albumTrackList.FilterState() != list.Filtering {
+				return m, m.openRelatedAlbums()
+			}
+		case "x":
+			if m.state == stateViewingAlbumTracks && m.albumTrackList.FilterState() != list.Filtering {
+				m.toggleAlbumTrack()
+				return m, nil
+			}
+		case "K", "shift+up":
+			if m.state == stateViewingAlbumTracks && m.albumTrackList.FilterState() != list.Filtering {
+				m.moveAlbumTrack(-1)
+				return m, nil
+			}
+		case "J", "shift+down":
+			if m.state == stateViewingAlbumTracks && m.albumTrackList.FilterState() != list.Filtering {
+				m.moveAlbumTrack(1)
+				return m, nil
+			}
+		case "v":
+			if m.state == stateViewingAlbumTracks && m.albumTrackList.FilterState() != list.Filtering && len(m.filteredTracks) > 0 {
+				m.openFilteredTracks()
+				return m, nil
+			}
+		case "ctrl+t":
+			if m.state == stateInput {
+				m.openSettings()
+				return m, nil
+			}
+		case "ctrl+l":
+			if m.state == stateInput {
+				return m, m.openLibrary()
+			}
+		case "ctrl+s":
+			if m.state == stateInput {
+				m.state = stateStats
+				return m, nil
+			}
+			if m.state == stateStats {
+				m.state = stateInput
+				return m, nil
+			}
+		case "ctrl+r":
+			if m.state == stateInput && len(m.pausedDownloads) > 0 && m.requireNetwork("resuming downloads") {
+				m.selected = m.pausedDownloads[0].item()
+				m.dlOptions = downloadOptions{itag: m.pausedDownloads[0].Itag} // The partial file is of that stream
+				m.pausedDownloads = m.pausedDownloads[1:]
+				m.state = stateDownloading
+				go m.runDownloadConvert(downloadJob.start())
+				return m, nil
+			}
+		case "ctrl+o":
+			if m.state == stateInput && m.albumResume != nil && m.requireNetwork("resuming album downloads") {
+				m.currentAlbum = m.albumResume.Album.item()
+				m.currentAlbum.isAlbum = true
+				m.albumTracks = m.albumResume.tracks()
+				m.downloadTracks = m.albumTracks
+				m.albumZip = cfg.Download.Zip
+				m.albumFormat, m.albumPassthrough = "", cfg.Download.Passthrough
+				m.selected = m.currentAlbum
+				m.state = stateDownloadingAlbum
+				go m.runDownloadAlbum(downloadJob.start())
 				return m, nil
 			}
 		case "esc":
+			if m.state == stateDownloading || m.state == stateConverting || m.state == stateDownloadingAlbum {
+				m.cancelDownload()
+				return m, nil
+			}
+			if m.state == stateStats {
+				m.state = stateInput
+				return m, nil
+			}
 			if m.state == stateViewingAlbumTracks {
-				m.state = stateSelecting
+				m.albumBack()
 				return m, nil
 			}
 			if m.state == stateSelecting {
+				m.rememberSearch()
 				m.state = stateInput
 				return m, nil
 			}
@@ -739,13 +1380,45 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.searchFilter = filterAlbums
 				return m, nil
 			}
+		case "4":
+			if m.state == stateInput {
+				m.lengthFilter = !m.lengthFilter
+				return m, nil
+			}
+		case "a":
+			if m.state == stateSelecting && !m.typing() {
+				return m, m.queueResults()
+			}
+		case "*":
+			if m.state == stateSelecting && !m.typing() {
+				if item, ok := m.list.SelectedItem().(songItem); ok {
+					m.togglePin(item)
+				}
+				return m, nil
+			}
+			if m.state == stateViewingAlbumTracks && m.albumTrackList.FilterState() != list.Filtering {
+				item, ok := m.albumTrackList.SelectedItem().(songItem)
+				if !ok || item.isAlbum {
+					item = m.currentAlbum
+				}
+				m.togglePin(item)
+				return m, nil
+			}
+		case "down":
+			if m.state == stateInput && len(m.pins) > 0 {
+				m.pinsFocused = true
+				m.textInput.Blur()
+				return m, nil
+			}
 		case "right":
 			if m.state == statePlaying {
 				m.seekForward()
+				m.syncPosition()
 			}
 		case "left":
 			if m.state == statePlaying {
 				m.seekBackward()
+				m.syncPosition()
 			}
 		}
 
@@ -755,22 +1428,44 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 
 	case lyricTickMsg:
-		if m.state == statePlaying {
-			m.updateLyrics()
-			return m, tea.Tick(time.Millisecond*200, func(t time.Time) tea.Msg {
-				return lyricTickMsg(t)
-			})
+		m.playback.ticking = false
+		// Nothing moves while paused; unpausing starts the ticker again
+		if !m.playing() || m.playback.isPaused {
+			return m, nil
 		}
-		return m, nil
+		m.syncPosition()
+		m.updatePulse()
+		return m, m.startTicker()
 
 	case searchResultsMsg:
 		m.state = stateSelecting
 		var items []list.Item
-		for _, v := range msg {
+		for _, v := range msg.items {
 			items = append(items, v)
 		}
-		m.list = list.New(items, list.NewDefaultDelegate(), m.width-4, m.height-8)
+		m.list = list.New(items, list.NewDefaultDelegate(), m.resultsWidth(), m.height-8)
 		m.list.Title = "Select Song or Album"
+		m.searchKey = msg.key
+		m.rememberSearch()
+		return m, m.previewHighlighted()
+
+	case lyricsCandidatesMsg:
+		m.lyricsSearching = false
+		if msg.err != nil {
+			m.lyricsErr = msg.err
+			return m, nil
+		}
+		if len(msg.results) == 0 {
+			m.lyricsErr = fmt.Errorf("no synced lyrics found for this query")
+			return m, nil
+		}
+		var items []list.Item
+		for _, res := range msg.results {
+			items = append(items, lyricCandidate{result: res})
+		}
+		m.lyricsList = list.New(items, list.NewDefaultDelegate(), m.width-4, m.height-8)
+		m.lyricsList.Title = "Select Lyrics"
+		m.state = stateLyricsResults
 		return m, nil
 
 	case errMsg:
@@ -778,6 +1473,21 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.state = stateError
 		return m, nil
 
+	case browseSettledMsg:
+		return m, m.loadBrowseDetails(msg)
+
+	case browseCoverMsg:
+		m.applyBrowseCover(msg)
+		return m, nil
+
+	case browseTracksMsg:
+		m.applyBrowseTracks(msg)
+		return m, nil
+
+	case streamsMsg:
+		m.applyStreams(msg)
+		return m, nil
+
 	case metadataFetchedMsg:
 		if m.selected.id == msg.id {
 			m.selected.title = msg.title
@@ -789,46 +1499,112 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmd := m.progress.SetPercent(float64(msg))
 		return m, cmd
 
+	case downloadPausedMsg:
+		// Single downloads can be resumed after a restart; album progress is not persisted
+		if m.state == stateDownloading {
+			savePausedDownload(newPausedDownload(m.selected, msg.offset, m.dlOptions.itag))
+		}
+		return m, nil
+
 	case convertMsg:
-		m.state = stateConverting
+		if m.state == stateDownloading { // Not when cancelled meanwhile
+			m.state = stateConverting
+		}
+		return m, nil
+
+	case verifyFailedMsg:
+		m.fileName = msg.fileName
+		m.err = msg.err
+		m.state = stateVerifyFailed
 		return m, nil
 
 	case doneMsg:
-		m.fileName = string(msg)
+		if m.state == stateDownloadingAlbum {
+			m.albumResume = nil // Finished, so its record is gone
+		}
+		m.fileName, m.savedDetail = msg.path, msg.detail
+		m.albumGaps, m.gapCursor = msg.gaps, 0
+		m.dlOptions = downloadOptions{} // They were for this download only
 		m.state = stateFinished
-		return m, tea.Batch(
-			tea.Printf("\n  %s %s\n", statusStyle.Render("Saved:"), m.fileName),
-			tea.Quit,
-		)
+		return m, nil
 
-	case imageReadyMsg:
-		// When image is ready, just store the path - don't display immediately
-		// Let the View function handle the display timing
-		if m.state == statePlaying {
-			m.playback.kittyImage = msg.imagePath
+	case transferProgressMsg, transferDoneMsg:
+		m.applyTransfer(msg)
+		return m, nil
+
+	case watchImportedMsg:
+		return m, m.applyWatchImport(msg)
+
+	case handoffMsg:
+		err := m.acceptHandoff(msg.req)
+		msg.reply <- err
+		if err != nil {
+			return m, nil
 		}
+		return m, lookupHandoff(msg.req)
+
+	case handoffItemMsg:
+		return m, m.applyHandoff(msg)
+
+	case coverReadyMsg:
+		return m, m.applyCover(msg)
+
+	case artRenderedMsg:
+		m.applyArt(msg)
+		return m, nil
+
+	case coverImageMsg:
+		m.applyCoverImage(msg)
+		return m, nil
+
+	case streamStartedMsg:
+		m.applyStreamStarted(msg)
 		return m, nil
 
 	case playMsg:
 		m.playback.playingSong = fmt.Sprintf("%s - %s", msg.title, msg.author)
-		m.state = statePlaying
-		return m, tea.Batch(
-			m.spinner.Tick,
-			tea.Tick(time.Millisecond*200, func(t time.Time) tea.Msg {
-				return lyricTickMsg(t)
-			}),
-		)
+		// A track the queue advanced to while browsing keeps playing in the background
+		if m.state == stateLoading {
+			m.state = statePlaying
+		}
+		return m, tea.Batch(m.spinner.Tick, m.startTicker())
 
 	case lyricsFetchedMsg:
-		m.playback.lyrics = msg
+		if msg.session != m.playback.session {
+			return m, nil
+		}
+		m.playback.lyrics, m.playback.lyricsUnsynced = msg.lines, msg.unsynced
+		m.updateLyrics()
 		return m, nil
 
 	case noLyricsMsg:
+		if msg.session != m.playback.session {
+			return m, nil
+		}
 		m.playback.lyrics = []LyricLine{{Timestamp: 0, Text: "[No synced lyrics found]"}}
+		m.playback.lyricsUnsynced = false
 		return m, nil
 
 	case stopMsg:
-		if m.state == statePlaying {
+		if cmd, ok := m.playNext(); ok {
+			return m, cmd
+		}
+		if !m.showsPlayback() {
+			// The queue ran out behind another screen: stay on it
+			m.stopPlayback()
+			return m, nil
+		}
+		if m.endsWithMenu() {
+			// Finished, so the playback keys leave the menu's keys alone
+			m.stopPlayback()
+			m.state = stateTrackEnded
+			return m, nil
+		}
+		if m.state == statePlaying || m.state == stateLyricsSearch || m.state == stateLyricsResults {
+			if m.selected.path != "" {
+				m.state = stateLibrary
+				return m, nil
+			}
 			// Only return to album tracks view if we have a valid album track list
 			// Check if list is initialized (width > 0) and has tracks
 			if len(m.albumTracks) > 0 && m.albumTrackList.Width() > 0 {
@@ -836,49 +1612,113 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			} else {
 				// Fallback to selecting state if album track list is not valid
 				m.state = stateSelecting
-				m.list.ResetSelected()
 			}
 		} else {
 			m.state = stateSelecting
-			m.list.ResetSelected()
 		}
 		return m, nil
 
+	case gapFilledMsg:
+		m.applyGapFilled(msg)
+		return m, nil
+
+	case linkedPlaylistMsg:
+		m.applyLinkedPlaylist(msg)
+		return m, nil
+
 	case albumTracksFetchedMsg:
-		m.albumTracks = msg
-		// Create list of tracks for viewing with tree structure
-		var trackItems []list.Item
-		
-		// Add album header with download instruction
-		albumHeader := songItem{
-			id:      m.currentAlbum.id,
-			title:   fmt.Sprintf("📀 %s (Press ENTER to download full album)", m.currentAlbum.title),
-			author:  m.currentAlbum.author,
-			isAlbum: true,
-		}
-		trackItems = append(trackItems, albumHeader)
-		
-		// Add tracks with tree view formatting
-		for i, track := range msg {
-			// Create a copy for display with tree structure
-			displayTrack := track
-			// Use tree characters for visual hierarchy
-			if i == len(msg)-1 {
-				// Last track
-				displayTrack.title = fmt.Sprintf("└── %02d. %s", i+1, track.title)
-			} else {
-				// Middle tracks
-				displayTrack.title = fmt.Sprintf("├── %02d. %s", i+1, track.title)
+		m.filteredTracks = msg.filtered
+		m.albumExcluded = nil
+		m.setAlbumTracks(msg.tracks)
+		m.state = stateViewingAlbumTracks
+		return m, nil
+
+	case libraryScannedMsg:
+		m.libraryLoading = false
+		m.libraryErr = msg.err
+		var items []list.Item
+		for _, album := range msg.albums {
+			items = append(items, album)
+		}
+		m.libraryIndex = msg.index
+		markOwnedLibrary(msg.index)
+		previous, _ := m.libraryList.SelectedItem().(libraryAlbum)
+		m.libraryList = list.New(items, list.NewDefaultDelegate(), m.width-4, m.height-8)
+		m.libraryList.Title = "Library"
+		selectItem(&m.libraryList, func(item list.Item) bool {
+			album, ok := item.(libraryAlbum)
+			return ok && album.path == previous.path
+		})
+		return m, nil
+
+	case queueImportedMsg:
+		if msg.err != nil {
+			m.notice = "Queue import failed: " + msg.err.Error()
+			return m, nil
+		}
+		m.queue.set(msg.tracks, msg.index)
+		return m, m.startPlayback(msg.tracks[msg.index])
+
+	case localAlbumMsg:
+		m.libraryLoading = false
+		if msg.err != nil {
+			m.libraryErr = msg.err
+			return m, nil
+		}
+		m.queue.set(msg.tracks, msg.start)
+		return m, m.startPlayback(msg.tracks[msg.start])
+
+	case previewDoneMsg:
+		if m.state == stateDownloadOptions {
+			m.optionsStatus = ""
+			if msg.err != nil {
+				m.optionsErr = msg.err
 			}
-			trackItems = append(trackItems, displayTrack)
 		}
-		
-		m.albumTrackList = list.New(trackItems, list.NewDefaultDelegate(), m.width-4, m.height-8)
-		m.albumTrackList.Title = fmt.Sprintf("Album: %s (%d tracks)", m.currentAlbum.title, len(msg))
-		m.state = stateViewingAlbumTracks
+		return m, nil
+
+	case trackAdvancedMsg:
+		m.queue.advance()
+		m.selected = msg.item
+		m.playback.resetTrack()
+		m.playback.trackKey = waveformKey(msg.item)
+		m.playback.session = msg.session
+		return m, tea.Batch(loadWaveform(playbackJob.context(), msg.item), m.infoCmd())
+
+	case trackInfoMsg:
+		m.applyTrackInfo(msg)
+		return m, nil
+
+	case radioTracksMsg:
+		return m, m.applyRadio(msg)
+
+	case coverThemeMsg:
+		m.applyCoverTheme(msg)
+		return m, nil
+
+	case waveformMsg:
+		if msg.key == m.playback.trackKey {
+			m.playback.waveform = msg.wave
+		}
+		return m, nil
+
+	case relatedAlbumsMsg:
+		m.setRelatedAlbums(msg)
+		return m, nil
+
+	case cooldownMsg:
+		m.cooldownUntil = msg.until
+		return m, cooldownTick()
+
+	case cooldownTickMsg:
+		// Keep the countdown moving until the cool-down is over
+		if time.Until(m.cooldownUntil) > 0 {
+			return m, cooldownTick()
+		}
 		return m, nil
 
 	case albumTrackProgressMsg:
+		m.cooldownUntil = time.Time{}
 		m.albumProgress.current = msg.current
 		m.albumProgress.total = msg.total
 		m.albumProgress.title = msg.title
@@ -894,13 +1734,36 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		var preview tea.Cmd
 		if m.state == stateSelecting {
-			m.list.SetSize(msg.Width-4, msg.Height-8)
+			m.sizeResults()
+			m.browseKey = "" // Redraw the cover for the new pane size
+			preview = m.previewHighlighted()
 		}
 		if m.state == stateViewingAlbumTracks {
 			m.albumTrackList.SetSize(msg.Width-4, msg.Height-8)
 		}
+		if m.state == stateLyricsResults {
+			m.lyricsList.SetSize(msg.Width-4, msg.Height-8)
+		}
+		if m.state == stateLibrary {
+			m.libraryList.SetSize(msg.Width-4, msg.Height-8)
+		}
+		if m.state == stateFilteredTracks {
+			m.filteredList.SetSize(msg.Width-4, msg.Height-8)
+		}
+		for i := range m.relatedLists {
+			m.relatedLists[i].SetSize(msg.Width-4, msg.Height-10)
+		}
 		m.progress.Width = msg.Width - 4
+		// Redraw the cover for the new size
+		return m, tea.Batch(m.resized(), preview)
+
+	case resizeSettledMsg:
+		if msg.width == m.width && msg.height == m.height {
+			return m, m.refreshArt()
+		}
+		return m, nil
 	}
 
 	if m.state == stateInput {
@@ -912,7 +1775,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if m.state == stateSelecting {
 		var cmd tea.Cmd
 		m.list, cmd = m.list.Update(msg)
-		return m, cmd
+		return m, tea.Batch(cmd, m.previewHighlighted())
 	}
 
 	if m.state == stateViewingAlbumTracks {
@@ -921,26 +1784,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.albumTrackList.Width() == 0 {
 			// If list is invalid, recreate it from albumTracks
 			if len(m.albumTracks) > 0 {
-				var trackItems []list.Item
-				albumHeader := songItem{
-					id:      m.currentAlbum.id,
-					title:   fmt.Sprintf("📀 %s", m.currentAlbum.title),
-					author:  m.currentAlbum.author,
-					isAlbum: true,
-				}
-				trackItems = append(trackItems, albumHeader)
-				
-				for i, track := range m.albumTracks {
-					displayTrack := track
-					if i == len(m.albumTracks)-1 {
-						displayTrack.title = fmt.Sprintf("└── %02d. %s", i+1, track.title)
-					} else {
-						displayTrack.title = fmt.Sprintf("├── %02d. %s", i+1, track.title)
-					}
-					trackItems = append(trackItems, displayTrack)
-				}
-				m.albumTrackList = list.New(trackItems, list.NewDefaultDelegate(), m.width-4, m.height-8)
-				m.albumTrackList.Title = fmt.Sprintf("Album: %s (%d tracks)", m.currentAlbum.title, len(m.albumTracks))
+				m.setAlbumTracks(m.albumTracks)
 			} else {
 				// No tracks available, go back to selecting
 				m.state = stateSelecting
@@ -954,26 +1798,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if r := recover(); r != nil {
 					// If update panics, recreate the list
 					if len(m.albumTracks) > 0 {
-						var trackItems []list.Item
-						albumHeader := songItem{
-							id:      m.currentAlbum.id,
-							title:   fmt.Sprintf("📀 %s", m.currentAlbum.title),
-							author:  m.currentAlbum.author,
-							isAlbum: true,
-						}
-						trackItems = append(trackItems, albumHeader)
-						
-						for i, track := range m.albumTracks {
-							displayTrack := track
-							if i == len(m.albumTracks)-1 {
-								displayTrack.title = fmt.Sprintf("└── %02d. %s", i+1, track.title)
-							} else {
-								displayTrack.title = fmt.Sprintf("├── %02d. %s", i+1, track.title)
-							}
-							trackItems = append(trackItems, displayTrack)
-						}
-						m.albumTrackList = list.New(trackItems, list.NewDefaultDelegate(), m.width-4, m.height-8)
-						m.albumTrackList.Title = fmt.Sprintf("Album: %s (%d tracks)", m.currentAlbum.title, len(m.albumTracks))
+						m.setAlbumTracks(m.albumTracks)
 					}
 				}
 			}()
@@ -985,7 +1810,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m model) View() string {
+func (m model) view() string {
 	if m.quitting {
 		return "\n  Goodbye! 🎧\n\n"
 	}
@@ -1004,56 +1829,95 @@ func (m model) View() string {
 		s = fmt.Sprintf("\n  %s\n\n  %s\n\n  %s\n\n  %s",
 			titleStyle.Render("GoMusic Search"),
 			m.textInput.View(),
-			helpStyle.Render(fmt.Sprintf("Filter: %s  •  1: All  2: Songs  3: Albums", filterText)),
-			helpStyle.Render("Enter song name, artist, or album"),
+			helpStyle.Render(fmt.Sprintf("Filter: %s  •  1: All  2: Songs  3: Albums  •  Length: %s  •  4: Toggle", filterText, lengthFilterLabel(m.lengthFilter))),
+			helpStyle.Render("Enter song name, artist, or album  •  Ctrl+L: Library  •  Ctrl+S: Session stats  •  Ctrl+T: Settings"),
 		)
+		s += m.renderNotice()
+		s += audioBanner()
+		if m.watchStatus != "" {
+			s += "\n\n  " + helpStyle.Render(m.watchStatus)
+		}
+		if len(m.pausedDownloads) > 0 {
+			s += fmt.Sprintf("\n\n  %s", statusStyle.Render(fmt.Sprintf(
+				"Ctrl+R: Resume paused download \"%s\" (%d paused)",
+				m.pausedDownloads[0].Title, len(m.pausedDownloads))))
+		}
+		if r := m.albumResume; r != nil {
+			s += fmt.Sprintf("\n\n  %s", statusStyle.Render(fmt.Sprintf(
+				"Ctrl+O: Resume album download \"%s\" from track %d of %d",
+				r.Album.Title, r.firstMissing()+1, len(r.Tracks))))
+		}
+		s += m.renderPins()
 	case stateSearching:
 		s = fmt.Sprintf("\n  %s Searching YouTube Music...\n", m.spinner.View())
 	case stateSelecting:
-		return docStyle.Render(
-			lipgloss.JoinVertical(lipgloss.Left,
-				m.list.View(),
-				helpStyle.Render("\n  ENTER: Browse Album/Download Song  •  P: Play Song  •  Q: Quit"),
-			),
-		)
+		return m.viewResults()
 	case stateViewingAlbumTracks:
+		help := "\n  ENTER: Download (Album header = Full Album, Track = Single)  •  O: Options  •  P: Play Track  •  TAB: Related  •  Q: Back  •  ESC: Back" +
+			"\n  X: Include/Skip Track  •  Shift+↑/↓ or K/J: Move Track  •  *: Pin"
+		if len(m.filteredTracks) > 0 {
+			help += fmt.Sprintf("\n  V: Review %d filtered duplicate(s) and alternate versions", len(m.filteredTracks))
+		}
 		return docStyle.Render(
 			lipgloss.JoinVertical(lipgloss.Left,
+				m.renderAlbumTabs(),
 				m.albumTrackList.View(),
-				helpStyle.Render("\n  ENTER: Download (Album header = Full Album, Track = Single)  •  P: Play Track  •  Q: Back  •  ESC: Back"),
+				helpStyle.Render(help)+m.renderNotice(),
 			),
 		)
+	case stateFilteredTracks:
+		return m.viewFilteredTracks()
+	case stateSettings:
+		return m.viewSettings()
+	case stateConfirmAlbum:
+		s = m.viewAlbumConfirm()
+	case statePickStream:
+		s = m.viewStreamPicker()
+	case stateFillGaps:
+		s = m.viewFillGaps()
 	case stateDownloading:
-		s = fmt.Sprintf("\n  %s\n\n  %s\n\n  %s",
+		s = fmt.Sprintf("\n  %s\n\n  %s\n\n  %s\n\n  %s",
 			titleStyle.Render("Downloading: "+m.selected.title),
 			m.progress.View(),
 			helpStyle.Render("Selected: "+m.selected.author),
+			m.renderDownloadControls(),
 		)
 	case stateDownloadingAlbum:
 		trackInfo := fmt.Sprintf("Track %d/%d: %s", m.albumProgress.current, m.albumProgress.total, m.albumProgress.title)
-		s = fmt.Sprintf("\n  %s\n\n  %s\n\n  %s\n\n  %s",
+		s = fmt.Sprintf("\n  %s\n\n  %s\n\n  %s\n\n  %s\n\n  %s",
 			titleStyle.Render("Downloading Album: "+m.selected.title),
 			m.progress.View(),
 			statusStyle.Render(trackInfo),
-			helpStyle.Render("Downloading all tracks from album..."),
+			m.renderCooldown(),
+			m.renderDownloadControls(),
 		)
 	case stateConverting:
 		s = fmt.Sprintf("\n  %s %s\n\n  %s",
 			m.spinner.View(),
 			titleStyle.Render("Encoding & Tagging..."),
-			helpStyle.Render("Using FFmpeg to embed cover art and ID3 tags"),
+			helpStyle.Render("Using FFmpeg to embed cover art and ID3 tags  •  ESC: Cancel"),
 		)
 	case stateFinished:
-		s = fmt.Sprintf("\n  %s\n", titleStyle.Render("Success! Enjoy your music."))
+		s = m.viewFinished()
+	case stateTrackEnded:
+		s = m.viewTrackEnded()
 	case stateLoading:
 		s = fmt.Sprintf("\n  %s %s\n", m.spinner.View(), titleStyle.Render("Preparing stream..."))
 	case statePlaying:
+		panel := m.renderLyrics()
+		if m.showInfo {
+			panel = m.renderInfo()
+		}
 		// Create clean content
 		mainContent := fmt.Sprintf(
-			"%s\n\n%s\n\n%s",
-			titleStyle.Render("Now Playing: " + m.playback.playingSong),
-			m.renderLyrics(),
-			helpStyle.Render("SPACE: Play/Pause  •  S: Stop  •  Q: Exit"),
+			"%s%s%s%s%s\n\n%s\n\n%s",
+			m.nowPlayingStyle().Render("Now Playing: " + m.playback.playingSong),
+			m.renderAudioInfo(),
+			m.renderPlaybackProgress(),
+			m.renderQueueInfo(),
+			m.renderStreamHealth(),
+			panel,
+			helpStyle.Render(playingHelp())+m.renderNotice(),
 		)
 
 		// Check if we have ASCII art album cover
@@ -1064,7 +1928,7 @@ func (m model) View() string {
 				BorderForeground(lipgloss.Color("63")).
 				Padding(0, 1)
 			
-			styledCover := coverStyle.Render(m.playback.albumCover)
+			styledCover := coverStyle.Render(dimANSI(m.playback.albumCover, m.pulseBrightness()))
 			
 			// Add info about the ASCII art
 			asciiInfo := helpStyle.Render("🎨  Colorized ASCII album art")
@@ -1080,38 +1944,119 @@ func (m model) View() string {
 			// No cover available, show main content only
 			s = fmt.Sprintf("\n  %s", mainContent)
 		}
-	case stateError:
-		s = fmt.Sprintf("\n  %s\n\n  %v\n",
-			errorStyle.Render("Error"),
+	case stateLyricsSearch, stateLyricsResults:
+		return m.viewLyricsSearch()
+	case stateLibrary:
+		return m.viewLibrary()
+	case stateLibrarySearch:
+		return m.viewLibrarySearch()
+	case stateDownloadOptions:
+		return m.viewDownloadOptions()
+	case stateRelatedAlbums:
+		return m.viewRelatedAlbums()
+	case stateStats:
+		s = fmt.Sprintf("\n  %s\n\n  %s\n\n  %s\n",
+			titleStyle.Render("Session Statistics"),
+			strings.Join(session.snapshot().lines(), "\n  "),
+			helpStyle.Render("ESC: Back"),
+		)
+	case stateVerifyFailed:
+		s = fmt.Sprintf("\n  %s\n\n  %v\n\n  %s\n",
+			errorStyle.Render("Download may be corrupt"),
 			m.err,
+			helpStyle.Render("R: Re-download  •  Q: Quit and keep "+m.fileName),
 		)
+	case stateError:
+		fe := classifyError(m.err)
+		if fe.hint == "" {
+			s = fmt.Sprintf("\n  %s\n\n  %v\n",
+				errorStyle.Render(fe.title),
+				m.err,
+			)
+		} else {
+			s = fmt.Sprintf("\n  %s\n\n  %s\n\n  %s\n",
+				errorStyle.Render(fe.title),
+				fe.hint,
+				helpStyle.Render(fmt.Sprintf("Details: %v", m.err)),
+			)
+		}
 	}
 
 	return s
 }
 
-func (m *model) updateLyrics() {
-	if len(m.playback.lyrics) == 0 {
-		return
+// renderDownloadControls shows the pause state and keys for the active download
+func (m *model) renderDownloadControls() string {
+	if m.download.isPaused() {
+		hint := "⏸ Paused  •  P: Resume  •  ESC: Cancel  •  Q: Quit"
+		if m.state == stateDownloading {
+			hint += " (resume later with Ctrl+R)"
+		}
+		return statusStyle.Render(hint)
 	}
+	return helpStyle.Render("P: Pause  •  ESC: Cancel  •  Q: Quit")
+}
 
-	currentTime, ok := m.getCurrentPlaybackPosition()
-	if !ok {
-		return
+// renderStreamHealth shows buffer fill and network events for the live stream
+func (m *model) renderStreamHealth() string {
+	if m.playback.health == nil || m.selected.path != "" {
+		return ""
 	}
+	return "\n" + helpStyle.Render(m.playback.health.render())
+}
 
-	// Find the current lyric index
-	newIdx := -1
-	for i, l := range m.playback.lyrics {
-		if l.Timestamp <= currentTime {
-			newIdx = i
-		} else {
-			break
-		}
+// renderCooldown explains a pause caused by YouTube rate limiting
+func (m model) renderCooldown() string {
+	if wait := time.Until(m.cooldownUntil); wait > 0 {
+		return errorStyle.Render(fmt.Sprintf("YouTube is rate limiting requests, resuming in about %s", formatDuration(wait)))
+	}
+	return helpStyle.Render("Downloading all tracks from album...")
+}
+
+// renderAudioInfo shows the format of the source being played and the volume
+func (m *model) renderAudioInfo() string {
+	info := m.renderVolume()
+	if m.playback.audioInfo != "" {
+		info = "Source: " + m.playback.audioInfo + "  •  " + info
+	}
+	return "\n" + helpStyle.Render(info)
+}
+
+// renderQueueInfo shows the queue position and the upcoming track
+func (m *model) renderQueueInfo() string {
+	if m.queue.len() < 2 {
+		return ""
+	}
+	info := fmt.Sprintf("Track %d of %d in queue", m.queue.index+1, m.queue.len())
+	if next, ok := m.queue.upNext(); ok {
+		info += fmt.Sprintf("  •  Up next: %s - %s", next.title, next.author)
+	}
+	return "\n" + helpStyle.Render(info)
+}
+
+// syncPosition reads the playback position and moves the lyric highlight to it
+func (m *model) syncPosition() {
+	if pos, ok := m.getCurrentPlaybackPosition(); ok {
+		m.playback.position = pos
+	}
+	m.updateLyrics()
+}
+
+// updateLyrics highlights the last line that started by the known position
+func (m *model) updateLyrics() {
+	if m.playback.lyricsUnsynced {
+		return // No timestamps to follow
 	}
-	m.playback.currentLyricIndex = newIdx
+	lyrics := m.playback.lyrics
+	// Lines are sorted by time, so the first one still to come is found by bisection
+	m.playback.currentLyricIndex = sort.Search(len(lyrics), func(i int) bool {
+		return lyrics[i].Timestamp > m.playback.position
+	}) - 1
 }
 
+// unsyncedLyricRows is how many lines of lyrics without timestamps are shown
+const unsyncedLyricRows = 12
+
 func (m *model) renderLyrics() string {
 	if m.playback.lyrics == nil {
 		if m.playback.playingSong != "" {
@@ -1121,7 +2066,20 @@ func (m *model) renderLyrics() string {
 	}
 
 	if len(m.playback.lyrics) == 1 && m.playback.lyrics[0].Text == "[No synced lyrics found]" {
-		return "\n  " + helpStyle.Render("No synced lyrics found for this track.")
+		return "\n  " + helpStyle.Render("No synced lyrics found for this track. Press L to search manually.")
+	}
+
+	// Without timestamps the text is shown as it is, from the top
+	if m.playback.lyricsUnsynced {
+		var lines []string
+		for i, line := range m.playback.lyrics {
+			if i == unsyncedLyricRows {
+				lines = append(lines, "    "+helpStyle.Render(fmt.Sprintf("(%d more lines, unsynced)", len(m.playback.lyrics)-i)))
+				break
+			}
+			lines = append(lines, "    "+helpStyle.Render(line.Text))
+		}
+		return strings.Join(lines, "\n")
 	}
 
 	idx := m.playback.currentLyricIndex
@@ -1147,7 +2105,7 @@ func (m *model) renderLyrics() string {
 		text := m.playback.lyrics[i].Text
 		if i == idx {
 			lines = append(lines, "  "+lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#00FFFF")).
+				Foreground(m.lyricHighlightColor()).
 				Bold(true).
 				Render("> "+text))
 		} else {
@@ -1159,9 +2117,63 @@ func (m *model) renderLyrics() string {
 }
 
 func main() {
-	if len(os.Args) > 1 && os.Args[1] == "-v" {
-		fmt.Printf("gomusic version %s\n", appVersion)
-		return
+	if err := loadConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := applyNetworkConfig(cfg.Network); err != nil {
+		fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
+		os.Exit(1)
+	}
+	applyTitleCleaning(cfg.TitleCleaning)
+	applyChannelMix(cfg.Playback)
+	applyTheme(cfg.Theme)
+	applyKeys(cfg.Keys)
+	player = newPlayer(cfg.Playback)
+
+	var queueImport string
+	var handoff *handoffRequest
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "-v":
+			fmt.Printf("gomusic version %s\n", appVersion)
+			return
+		case "get":
+			os.Exit(runGet(os.Args[2:]))
+		case "download":
+			os.Exit(runDownload(os.Args[2:]))
+		case "search":
+			os.Exit(runSearch(os.Args[2:]))
+		case "status":
+			os.Exit(runStatus(os.Args[2:]))
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "send":
+			os.Exit(runSend(os.Args[2:]))
+		case "watch":
+			os.Exit(runWatch(os.Args[2:]))
+		case "export-state":
+			os.Exit(runExportState(os.Args[2:]))
+		case "import-state":
+			os.Exit(runImportState(os.Args[2:]))
+		case "video":
+			os.Exit(runVideo(os.Args[2:]))
+		case "open":
+			var code int
+			if handoff, code = runOpen(os.Args[2:]); handoff == nil {
+				os.Exit(code)
+			}
+		case "--offline":
+			offlineMode = true
+		case "--low-bandwidth":
+			lowBandwidthFlag = true
+		case "--queue":
+			if len(os.Args) < 3 {
+				fmt.Fprintln(os.Stderr, "usage: gomusic --queue queue.json")
+				os.Exit(2)
+			}
+			queueImport = os.Args[2]
+		}
 	}
 
 	ti := textinput.New()
@@ -1176,6 +2188,9 @@ func main() {
 
 	p := progress.New(progress.WithDefaultGradient())
 
+	// Before the paused downloads are loaded, as it forgets those whose file is gone
+	removedTemp := cleanupTempFiles()
+
 	m := &model{
 		state:        stateInput,
 		textInput:    ti,
@@ -1183,15 +2198,67 @@ func main() {
 		progress:     p,
 		playback:     &playbackState{},
 		searchFilter: filterAll,
+		lengthFilter: cfg.Search.LengthFilter,
+		download:     &downloadControl{},
+
+		pausedDownloads: loadPausedDownloads(),
+		volume:          loadVolume(),
+		albumResume:     loadAlbumDownload(),
+		pins:            loadPins(),
+		queueImport:     queueImport,
+		handoff:         handoff,
+	}
+	if offlineMode {
+		m.state = stateLibrary
+		m.libraryLoading = true
+	}
+	if removedTemp > 0 {
+		m.notice = fmt.Sprintf("Removed %d temp file(s) left behind by an earlier run", removedTemp)
 	}
 
-	program := tea.NewProgram(m)
+	var opts []tea.ProgramOption
+	if cfg.Output.toStdout() {
+		// stdout carries the audio, so the UI draws on stderr
+		opts = append(opts, tea.WithOutput(os.Stderr))
+	}
+	instance, err := claimInstance()
+	if errors.Is(err, errInstanceRunning) {
+		fmt.Fprintln(os.Stderr, "gomusic is already running. Hand it a track with: gomusic open [--download] URL")
+		os.Exit(1)
+	}
+	if _, ok := player.(builtinPlayer); ok {
+		// Without a sound card gomusic still searches and downloads
+		audioDeviceErr = initSpeaker()
+	}
+	program := tea.NewProgram(m, opts...)
 	m.program = program
+	if instance != nil {
+		go serveHandoffs(instance, program)
+	}
+	startWatching(program)
 
-	initSpeaker()
-
-	if _, err := program.Run(); err != nil {
+	_, err = program.Run()
+	quitJobs() // Abort requests and kill ffmpeg and mpv children still running
+	if instance != nil {
+		instance.Close()
+	}
+	removeStatus()
+	if err != nil {
 		fmt.Printf("Error running GoMusic: %v\n", err)
 		os.Exit(1)
 	}
+	if cfg.Output.toStdout() {
+		clearTerminalTitle(os.Stderr, m)
+	} else {
+		clearTerminalTitle(os.Stdout, m)
+	}
+
+	if stats := session.snapshot(); !stats.empty() {
+		summary := os.Stdout
+		if cfg.Output.toStdout() {
+			summary = os.Stderr
+		}
+		fmt.Fprintf(summary, "\n  %s\n  %s\n", titleStyle.Render("This session"), strings.Join(stats.lines(), "\n  "))
+		appendHistory(stats)
+	}
 }
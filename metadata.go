@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metadataConfig picks, for each tag, the sources that fill it in order of
+// precedence: the first with a value wins. The track's own YouTube Music
+// details ("youtube") are the last resort, so a missed lookup never blanks a tag.
+type metadataConfig struct {
+	Title  []string `toml:"title,omitempty"`  // e.g. ["youtube"]
+	Artist []string `toml:"artist,omitempty"` // e.g. ["musicbrainz", "youtube"]
+	Album  []string `toml:"album,omitempty"`
+	Year   []string `toml:"year,omitempty"`
+	Art    []string `toml:"art,omitempty"` // Embedded cover
+}
+
+// metadataFields are the tags the chain resolves, as named in [metadata]
+var metadataFields = []string{"title", "artist", "album", "year", "art"}
+
+// trackFields are the tags that differ between the tracks of an album, and
+// albumFields those they share, resolved once per album
+var (
+	trackFields = []string{"title", "artist"}
+	albumFields = []string{"album", "year", "art"}
+)
+
+// metadataSources are the sources the [metadata] lists can name
+var metadataSources = []string{"youtube", "itunes", "musicbrainz"}
+
+// minMetadataScore is the lowest confidence accepted for a lookup match
+const minMetadataScore = 0.5
+
+// musicBrainzUserAgent identifies gomusic, as MusicBrainz asks of clients
+const musicBrainzUserAgent = "gomusic/" + appVersion + " ( https://github.com/iiTzDante/gomusic )"
+
+// musicBrainzInterval is the gap MusicBrainz requires between requests of a client
+const musicBrainzInterval = time.Second
+
+// musicBrainzPace spaces out MusicBrainz requests, album tracks included
+var musicBrainzPace struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// sources returns the precedence list of field
+func (c metadataConfig) sources(field string) []string {
+	switch field {
+	case "title":
+		return c.Title
+	case "artist":
+		return c.Artist
+	case "album":
+		return c.Album
+	case "year":
+		return c.Year
+	}
+	return c.Art
+}
+
+func (c metadataConfig) validate() error {
+	for _, field := range metadataFields {
+		for _, source := range c.sources(field) {
+			if !slices.Contains(metadataSources, source) {
+				return fmt.Errorf("metadata: unknown %s source %q, use %s", field, source, strings.Join(metadataSources, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// trackMetadata is what the tagger writes to a downloaded file
+type trackMetadata struct {
+	Title  string
+	Artist string
+	Album  string
+	Year   string
+	Art    string // Cover URL
+}
+
+// field returns the value of the named tag
+func (t *trackMetadata) field(name string) *string {
+	switch name {
+	case "title":
+		return &t.Title
+	case "artist":
+		return &t.Artist
+	case "album":
+		return &t.Album
+	case "year":
+		return &t.Year
+	}
+	return &t.Art
+}
+
+// metadataProvider looks a track up in one metadata source
+type metadataProvider interface {
+	lookup(ctx context.Context, item songItem) (trackMetadata, error)
+}
+
+// metadataProviders are the sources looked up over the network
+var metadataProviders = map[string]metadataProvider{
+	"itunes":      itunesProvider{},
+	"musicbrainz": musicBrainzProvider{},
+}
+
+// resolveMetadata fills each of fields from the first source in its
+// precedence list that has it, starting from base, the YouTube Music details.
+// Each source is looked up at most once, and only if one of fields names it.
+func resolveMetadata(ctx context.Context, item songItem, base trackMetadata, fields []string) trackMetadata {
+	if offlineMode {
+		return base
+	}
+	found := map[string]*trackMetadata{"youtube": &base}
+	resolved := base
+	for _, field := range fields {
+		for _, source := range cfg.Metadata.sources(field) {
+			r, ok := found[source]
+			if !ok {
+				// A failed lookup isn't repeated for the next tag
+				if md, err := metadataProviders[source].lookup(ctx, item); err == nil {
+					r = &md
+				}
+				found[source] = r
+			}
+			if r != nil && *r.field(field) != "" {
+				*resolved.field(field) = *r.field(field)
+				break
+			}
+		}
+	}
+	return resolved
+}
+
+// metadataCover fetches the cover resolved picked when it isn't the YouTube
+// Music one of base; ok is false when there is none or it can't be fetched
+func (m *model) metadataCover(ctx context.Context, resolved, base trackMetadata) (path string, ok bool) {
+	if resolved.Art == "" || resolved.Art == base.Art {
+		return "", false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(resolved.Art))
+	path, err := m.cachedArt(ctx, fmt.Sprintf("meta_%08x", h.Sum32()), resolved.Art)
+	return path, err == nil
+}
+
+// resolveAlbumMetadata resolves the tags the tracks of an album share once,
+// looking the album up through its first track, so every track gets the same.
+// cover is the cover file to embed: thumb, unless another source's was picked.
+func (m *model) resolveAlbumMetadata(ctx context.Context, first songItem, base trackMetadata, thumb string) (meta trackMetadata, cover string) {
+	meta = resolveMetadata(ctx, first, base, albumFields)
+	if path, ok := m.metadataCover(ctx, meta, base); ok {
+		return meta, path
+	}
+	meta.Art = base.Art
+	return meta, thumb
+}
+
+// scoreMetadataMatch rates a candidate between 0 and 1 like a lyric
+// candidate, reporting false when its length rules it out
+func scoreMetadataMatch(item songItem, title, artist string, seconds int) (float64, bool) {
+	return scoreLyricCandidate(lrclibResponse{TrackName: title, ArtistName: artist, Duration: float64(seconds)},
+		cleanString(item.title), cleanArtist(item.author), item.duration)
+}
+
+// metadataGet fetches u and decodes its JSON reply into v
+func metadataGet(ctx context.Context, u string, v any, userAgent string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// itunesProvider looks tracks up in the iTunes Search API, whose covers go
+// up to 1200px
+type itunesProvider struct{}
+
+func (itunesProvider) lookup(ctx context.Context, item songItem) (trackMetadata, error) {
+	params := url.Values{}
+	params.Set("term", cleanArtist(item.author)+" "+cleanString(item.title))
+	params.Set("media", "music")
+	params.Set("entity", "song")
+	params.Set("limit", "10")
+	var reply struct {
+		Results []struct {
+			TrackName       string `json:"trackName"`
+			ArtistName      string `json:"artistName"`
+			CollectionName  string `json:"collectionName"`
+			ReleaseDate     string `json:"releaseDate"`
+			ArtworkURL100   string `json:"artworkUrl100"`
+			TrackTimeMillis int    `json:"trackTimeMillis"`
+		} `json:"results"`
+	}
+	if err := metadataGet(ctx, "https://itunes.apple.com/search?"+params.Encode(), &reply, ""); err != nil {
+		return trackMetadata{}, err
+	}
+	best, bestScore := -1, 0.0
+	for i, r := range reply.Results {
+		score, ok := scoreMetadataMatch(item, r.TrackName, r.ArtistName, r.TrackTimeMillis/1000)
+		if ok && score >= minMetadataScore && (best < 0 || score > bestScore) {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return trackMetadata{}, fmt.Errorf("iTunes has no confident match for %q", item.title)
+	}
+	r := reply.Results[best]
+	return trackMetadata{
+		Title:  r.TrackName,
+		Artist: r.ArtistName,
+		Album:  r.CollectionName,
+		Year:   yearOf(r.ReleaseDate),
+		Art:    strings.Replace(r.ArtworkURL100, "100x100bb", "1200x1200bb", 1),
+	}, nil
+}
+
+// musicBrainzProvider looks tracks up in MusicBrainz, with covers from the
+// Cover Art Archive
+type musicBrainzProvider struct{}
+
+func (musicBrainzProvider) lookup(ctx context.Context, item songItem) (trackMetadata, error) {
+	unquote := strings.NewReplacer(`"`, "", `\`, "")
+	params := url.Values{}
+	params.Set("query", fmt.Sprintf(`recording:"%s" AND artist:"%s"`,
+		unquote.Replace(cleanString(item.title)), unquote.Replace(primaryArtist(cleanArtist(item.author)))))
+	params.Set("fmt", "json")
+	params.Set("limit", "10")
+	if err := waitMusicBrainz(ctx); err != nil {
+		return trackMetadata{}, err
+	}
+	var reply struct {
+		Recordings []struct {
+			Title            string `json:"title"`
+			Length           int    `json:"length"` // Milliseconds
+			FirstReleaseDate string `json:"first-release-date"`
+			ArtistCredit     []struct {
+				Name       string `json:"name"`
+				JoinPhrase string `json:"joinphrase"`
+			} `json:"artist-credit"`
+			Releases []struct {
+				ID    string `json:"id"`
+				Title string `json:"title"`
+			} `json:"releases"`
+		} `json:"recordings"`
+	}
+	if err := metadataGet(ctx, "https://musicbrainz.org/ws/2/recording?"+params.Encode(), &reply, musicBrainzUserAgent); err != nil {
+		return trackMetadata{}, err
+	}
+	for _, r := range reply.Recordings {
+		var artist strings.Builder
+		for _, credit := range r.ArtistCredit {
+			artist.WriteString(credit.Name + credit.JoinPhrase)
+		}
+		if score, ok := scoreMetadataMatch(item, r.Title, artist.String(), r.Length/1000); !ok || score < minMetadataScore {
+			continue
+		}
+		md := trackMetadata{Title: r.Title, Artist: artist.String(), Year: yearOf(r.FirstReleaseDate)}
+		if len(r.Releases) > 0 {
+			md.Album = r.Releases[0].Title
+			md.Art = "https://coverartarchive.org/release/" + r.Releases[0].ID + "/front-500"
+		}
+		return md, nil // Results come best first
+	}
+	return trackMetadata{}, fmt.Errorf("MusicBrainz has no confident match for %q", item.title)
+}
+
+// waitMusicBrainz blocks until the next MusicBrainz request may go out
+func waitMusicBrainz(ctx context.Context) error {
+	musicBrainzPace.mu.Lock()
+	defer musicBrainzPace.mu.Unlock()
+	select {
+	case <-time.After(time.Until(musicBrainzPace.last.Add(musicBrainzInterval))):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	musicBrainzPace.last = time.Now()
+	return nil
+}
+
+// yearOf is the year of a "2006-01-02" style date
+func yearOf(date string) string {
+	if len(date) < 4 || !yearPattern.MatchString(date[:4]) {
+		return ""
+	}
+	return date[:4]
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+const (
+	mpvConnectTimeout = 5 * time.Second
+	mpvRequestTimeout = 500 * time.Millisecond
+)
+
+// mpvPlayer hands playback to an mpv process driven over its JSON IPC socket.
+// mpv does its own decoding, buffering and seeking.
+type mpvPlayer struct {
+	path string
+	mu   sync.Mutex
+	cur  *mpvProcess
+}
+
+func newMPVPlayer(path string) *mpvPlayer {
+	if path == "" {
+		path = "mpv"
+	}
+	return &mpvPlayer{path: path}
+}
+
+// mpvProcess is one running mpv and its IPC connection
+type mpvProcess struct {
+	cmd     *exec.Cmd
+	socket  string
+	conn    net.Conn
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan mpvReply
+	stopped atomic.Bool // Set when gomusic stopped it rather than the track ending
+}
+
+type mpvReply struct {
+	Data      json.RawMessage `json:"data"`
+	Error     string          `json:"error"`
+	RequestID int             `json:"request_id"`
+}
+
+var mpvSockets atomic.Int64
+
+// errMPVStopped means playback was stopped while mpv was still starting
+var errMPVStopped = errors.New("mpv: stopped")
+
+// start launches mpv on target and connects to its IPC socket. mpv is killed
+// once ctx is cancelled. An exclusive start leaves out everything that would
+// touch the samples.
+func (p *mpvPlayer) start(ctx context.Context, target string, exclusive bool, extra ...string) (*mpvProcess, error) {
+	socket := filepath.Join(os.TempDir(), fmt.Sprintf("gomusic-mpv-%d-%d.sock", os.Getpid(), mpvSockets.Add(1)))
+	args := []string{
+		"--no-video",
+		"--no-terminal",
+		"--ytdl=no",
+		"--gapless-audio=yes",
+		"--audio-client-name=" + cfg.Output.clientName(),
+		"--input-ipc-server=" + socket,
+	}
+	if exclusive {
+		args = append(args, mpvExclusiveArgs(cfg.Output)...)
+	} else {
+		args = append(args, mpvOutputArgs(cfg.Output)...)
+		args = append(args, mpvMixArgs(cfg.Playback)...)
+		args = append(args, mpvRateArgs(cfg.Playback)...)
+		args = append(args, mpvGainArgs()...)
+	}
+	args = append(args, extra...)
+	args = append(args, "--", target)
+
+	proc := &mpvProcess{
+		cmd:     exec.Command(p.path, args...),
+		socket:  socket,
+		pending: map[int]chan mpvReply{},
+	}
+	if ctx.Err() != nil {
+		return nil, errMPVStopped
+	}
+	if err := proc.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("mpv: %w", err)
+	}
+	context.AfterFunc(ctx, proc.kill)
+
+	p.mu.Lock()
+	p.cur = proc
+	p.mu.Unlock()
+
+	// The socket appears once mpv has initialised
+	deadline := time.Now().Add(mpvConnectTimeout)
+	for {
+		conn, err := net.Dial("unix", socket)
+		if err == nil {
+			proc.conn = conn
+			go proc.readReplies()
+			return proc, nil
+		}
+		if proc.stopped.Load() {
+			return nil, errMPVStopped
+		}
+		if time.Now().After(deadline) {
+			proc.kill()
+			return nil, fmt.Errorf("mpv: no IPC socket: %w", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// mpvOutputArgs points mpv's own audio output at PipeWire or JACK when the
+// output mode asks for it
+func mpvOutputArgs(o outputConfig) []string {
+	switch o.Mode {
+	case "pipewire":
+		args := []string{"--ao=pipewire"}
+		if o.Path != "" {
+			args = append(args, "--audio-device=pipewire/"+o.Path)
+		}
+		return args
+	case "jack":
+		args := []string{"--ao=jack", "--jack-name=" + o.clientName()}
+		if o.Path != "" {
+			args = append(args, "--jack-port="+o.Path)
+		}
+		return args
+	}
+	return nil
+}
+
+// mpvExclusiveArgs has mpv bypass the system mixer and pass the file's
+// samples through at their own rate, with no volume, pan or ReplayGain.
+// output.path picks the device, e.g. alsa/hw:0,0 for bit-perfect ALSA on
+// Linux, where mpv has no exclusive mode of its own.
+func mpvExclusiveArgs(o outputConfig) []string {
+	args := []string{"--audio-exclusive=yes", "--volume=100", "--replaygain=no"}
+	if o.Path != "" {
+		args = append(args, "--audio-device="+o.Path)
+	}
+	return args
+}
+
+// mpvRateArgs passes a configured sample rate and buffer on to mpv, which
+// otherwise picks its own
+func mpvRateArgs(c playbackConfig) []string {
+	var args []string
+	if c.SampleRate != 0 {
+		args = append(args, "--audio-samplerate="+strconv.Itoa(c.SampleRate))
+	}
+	if c.Buffer != 0 {
+		args = append(args, "--audio-buffer="+strconv.FormatFloat(c.Buffer.Seconds(), 'f', 3, 64))
+	}
+	return args
+}
+
+// mpvMixArgs applies mono and balance through mpv's pan filter
+func mpvMixArgs(c playbackConfig) []string {
+	if !c.Mono && c.Balance == 0 {
+		return nil
+	}
+	left := 1 - math.Max(0, c.Balance)
+	right := 1 + math.Min(0, c.Balance)
+	channel := func(n int, gain float64) string {
+		if c.Mono {
+			return fmt.Sprintf("c%d=%g*c0+%g*c1", n, gain/2, gain/2)
+		}
+		return fmt.Sprintf("c%d=%g*c%d", n, gain, n)
+	}
+	return []string{"--af=lavfi=[pan=stereo|" + channel(0, left) + "|" + channel(1, right) + "]"}
+}
+
+// readReplies hands command replies to their waiting requests. Events are ignored.
+func (proc *mpvProcess) readReplies() {
+	scanner := bufio.NewScanner(proc.conn)
+	for scanner.Scan() {
+		var reply mpvReply
+		if json.Unmarshal(scanner.Bytes(), &reply) != nil || reply.RequestID == 0 {
+			continue
+		}
+		proc.mu.Lock()
+		ch := proc.pending[reply.RequestID]
+		delete(proc.pending, reply.RequestID)
+		proc.mu.Unlock()
+		if ch != nil {
+			ch <- reply
+		}
+	}
+}
+
+// request sends an IPC command and waits briefly for its reply
+func (proc *mpvProcess) request(args ...any) (mpvReply, error) {
+	if proc.conn == nil {
+		return mpvReply{}, fmt.Errorf("mpv: not connected")
+	}
+	ch := make(chan mpvReply, 1)
+	proc.mu.Lock()
+	proc.nextID++
+	id := proc.nextID
+	proc.pending[id] = ch
+	data, _ := json.Marshal(map[string]any{"command": args, "request_id": id})
+	_, err := proc.conn.Write(append(data, '\n'))
+	proc.mu.Unlock()
+	if err != nil {
+		return mpvReply{}, err
+	}
+
+	select {
+	case reply := <-ch:
+		if reply.Error != "success" {
+			return reply, fmt.Errorf("mpv: %s", reply.Error)
+		}
+		return reply, nil
+	case <-time.After(mpvRequestTimeout):
+		proc.mu.Lock()
+		delete(proc.pending, id)
+		proc.mu.Unlock()
+		return mpvReply{}, fmt.Errorf("mpv: no reply")
+	}
+}
+
+func (proc *mpvProcess) kill() {
+	proc.stopped.Store(true)
+	if proc.conn != nil {
+		proc.conn.Close()
+	}
+	if proc.cmd.Process != nil {
+		proc.cmd.Process.Kill()
+	}
+	os.Remove(proc.socket)
+}
+
+// wait blocks until mpv exits and reports whether the track ran to its end
+func (proc *mpvProcess) wait() (finished bool, err error) {
+	err = proc.cmd.Wait()
+	if proc.conn != nil {
+		proc.conn.Close()
+	}
+	os.Remove(proc.socket)
+	return !proc.stopped.Load(), err
+}
+
+func (p *mpvPlayer) current() *mpvProcess {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cur
+}
+
+func (p *mpvPlayer) Play(ctx context.Context, m *model, item songItem) {
+	target, title, author, exclusive, err := m.prepareMPVTrack(ctx, item)
+	if err != nil {
+		m.playbackFailed(ctx, err)
+		return
+	}
+
+	proc, err := p.start(ctx, target, exclusive, "--force-media-title="+title)
+	if errors.Is(err, errMPVStopped) {
+		return
+	}
+	if err != nil {
+		m.program.Send(errMsg(err))
+		return
+	}
+	m.program.Send(playMsg{title: title, author: author})
+
+	finished, err := proc.wait()
+	switch {
+	case !finished:
+		// Stopped from the UI, which already moved on
+	case err != nil:
+		m.program.Send(errMsg(fmt.Errorf("mpv could not play %s: %w", title, err)))
+	default:
+		m.program.Send(stopMsg{})
+	}
+}
+
+// prepareMPVTrack resolves what mpv should open and starts the cover and
+// lyric lookups like the built-in player does. exclusive reports whether the
+// track is played bit-perfect: a lossless local file with output.exclusive
+// set, outside quiet hours, whose cap needs mpv's volume.
+func (m *model) prepareMPVTrack(ctx context.Context, item songItem) (target, title, author string, exclusive bool, err error) {
+	if item.path != "" {
+		key := localCoverKey(item.path)
+		started := streamStartedMsg{key: key}
+		if info, err := probeAudioInfo(item.path); err == nil {
+			started.audioInfo = info.String()
+			if cfg.Output.Exclusive && info.lossless() && currentGain() == 1 {
+				exclusive = true
+				started.exclusive = true
+				started.audioInfo += " • bit-perfect, exclusive"
+			}
+		}
+		m.program.Send(started)
+		m.fetchTrackExtras(ctx, item)
+		return item.path, item.title, item.author, exclusive, nil
+	}
+
+	if item.id == "" || len(item.id) < 10 {
+		return "", "", "", false, fmt.Errorf("cannot play this track - invalid track ID")
+	}
+	m.fetchTrackExtras(ctx, item)
+	client := youtube.Client{}
+	track, err := client.GetVideoContext(ctx, item.id)
+	if err != nil {
+		return "", "", "", false, err
+	}
+	format := playbackAudioFormat(track.Formats.Type("audio"))
+	if format == nil {
+		return "", "", "", false, fmt.Errorf("no audio format found")
+	}
+	streamURL, err := client.GetStreamURLContext(ctx, track, format)
+	if err != nil {
+		return "", "", "", false, err
+	}
+	m.program.Send(streamStartedMsg{key: item.id, audioInfo: formatAudioInfo(format).String()})
+	return streamURL, track.Title, track.Author, false, nil
+}
+
+func (p *mpvPlayer) Preview(ctx context.Context, m *model, item songItem, from, length time.Duration) {
+	client := youtube.Client{}
+	track, err := client.GetVideoContext(ctx, item.id)
+	if err != nil {
+		m.program.Send(previewDoneMsg{err: err})
+		return
+	}
+	format := playbackAudioFormat(track.Formats.Type("audio"))
+	if format == nil {
+		m.program.Send(previewDoneMsg{err: fmt.Errorf("no audio format found")})
+		return
+	}
+	streamURL, err := client.GetStreamURLContext(ctx, track, format)
+	if err != nil {
+		m.program.Send(previewDoneMsg{err: err})
+		return
+	}
+
+	proc, err := p.start(ctx, streamURL, false,
+		"--start="+strconv.FormatFloat(from.Seconds(), 'f', 3, 64),
+		"--length="+strconv.FormatFloat(length.Seconds(), 'f', 3, 64))
+	if errors.Is(err, errMPVStopped) {
+		return
+	}
+	if err != nil {
+		m.program.Send(previewDoneMsg{err: err})
+		return
+	}
+	if finished, err := proc.wait(); finished {
+		m.program.Send(previewDoneMsg{err: err})
+	}
+}
+
+func (p *mpvPlayer) TogglePause(m *model) {
+	proc := p.current()
+	if proc == nil {
+		return
+	}
+	if _, err := proc.request("set_property", "pause", !m.playback.isPaused); err == nil {
+		m.playback.isPaused = !m.playback.isPaused
+	}
+}
+
+func (p *mpvPlayer) Stop(m *model) {
+	p.mu.Lock()
+	proc := p.cur
+	p.cur = nil
+	p.mu.Unlock()
+	if proc != nil {
+		proc.kill()
+	}
+}
+
+func (p *mpvPlayer) Seek(m *model, offset time.Duration) {
+	if proc := p.current(); proc != nil {
+		proc.request("seek", offset.Seconds(), "relative")
+	}
+}
+
+func (p *mpvPlayer) SetVolume(m *model, gain float64) {
+	if proc := p.current(); proc != nil {
+		proc.request("set_property", "volume", gain*100)
+	}
+}
+
+func (p *mpvPlayer) Position(m *model) (time.Duration, bool) {
+	proc := p.current()
+	if proc == nil {
+		return 0, false
+	}
+	reply, err := proc.request("get_property", "time-pos")
+	if err != nil {
+		return 0, false
+	}
+	var seconds float64
+	if json.Unmarshal(reply.Data, &seconds) != nil {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// customized reports whether the network settings differ from the system defaults
+func (n networkConfig) customized() bool {
+	return n.Interface != "" || n.SourceAddress != "" || n.IPVersion != 0
+}
+
+// network returns the dial network for the configured IP version
+func (n networkConfig) network(requested string) string {
+	switch n.IPVersion {
+	case 4:
+		return "tcp4"
+	case 6:
+		return "tcp6"
+	}
+	return requested
+}
+
+// localIP resolves the address outgoing connections are bound to, nil for any
+func (n networkConfig) localIP() (net.IP, error) {
+	if n.SourceAddress != "" {
+		ip := net.ParseIP(n.SourceAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("network: invalid source_address %q", n.SourceAddress)
+		}
+		if (n.IPVersion == 4 && ip.To4() == nil) || (n.IPVersion == 6 && ip.To4() != nil) {
+			return nil, fmt.Errorf("network: source_address %s does not match ip_version %d", ip, n.IPVersion)
+		}
+		return ip, nil
+	}
+	if n.Interface == "" {
+		return nil, nil
+	}
+
+	iface, err := net.InterfaceByName(n.Interface)
+	if err != nil {
+		return nil, fmt.Errorf("network: interface %q: %w", n.Interface, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("network: interface %q: %w", n.Interface, err)
+	}
+	// Prefer IPv4 unless IPv6 is forced, and skip link-local addresses that can't route
+	var fallback net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		isV4 := ipNet.IP.To4() != nil
+		switch {
+		case n.IPVersion == 6 && !isV4, n.IPVersion != 6 && isV4:
+			return ipNet.IP, nil
+		case n.IPVersion == 0 && fallback == nil:
+			fallback = ipNet.IP
+		}
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, fmt.Errorf("network: interface %q has no usable address", n.Interface)
+}
+
+// lowBandwidthFlag is set by --low-bandwidth, for this run only, so saving
+// the settings doesn't write it into the config
+var lowBandwidthFlag bool
+
+// lowBandwidth reports whether low-bandwidth mode is on, by config or flag
+func lowBandwidth() bool {
+	return cfg.Network.LowBandwidth || lowBandwidthFlag
+}
+
+// systemTransport is the transport used without custom network settings
+var systemTransport = http.DefaultTransport
+
+// applyNetworkConfig routes every HTTP request (YouTube, YouTube Music, LRCLIB)
+// through a transport bound to the configured address and IP version, with
+// the configured timeouts, retries, user agent and connection pool
+func applyNetworkConfig(n networkConfig) error {
+	dialer := &net.Dialer{Timeout: n.ConnectTimeout, KeepAlive: 30 * time.Second}
+	if n.customized() {
+		ip, err := n.localIP()
+		if err != nil {
+			return err
+		}
+		if ip != nil {
+			dialer.LocalAddr = &net.TCPAddr{IP: ip}
+		}
+	}
+
+	transport := systemTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, n.network(network), addr)
+	}
+	transport.TLSHandshakeTimeout = n.ConnectTimeout
+	transport.ResponseHeaderTimeout = n.ConnectTimeout
+	transport.MaxIdleConnsPerHost = n.MaxIdleConns
+	http.DefaultTransport = &retryTransport{base: transport, userAgent: n.UserAgent, retries: n.Retries}
+	apiClient.Timeout = n.Timeout
+	return nil
+}
+
+// ffmpegStreamInput returns what to pass to ffmpeg's "-i" for a remote format.
+// ffmpeg can't bind to an address or force an IP version for HTTP, so with
+// custom network settings the stream is fetched by gomusic and piped in.
+func ffmpegStreamInput(ctx context.Context, client youtube.Client, video *youtube.Video, format *youtube.Format) (string, io.ReadCloser, error) {
+	if !cfg.Network.customized() {
+		streamURL, err := client.GetStreamURLContext(ctx, video, format)
+		return streamURL, nil, err
+	}
+	stream, _, err := client.GetStreamContext(ctx, video, format)
+	if err != nil {
+		return "", nil, err
+	}
+	return "pipe:0", stream, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// albumNFO is the album.nfo Kodi and Jellyfin read from an album folder
+type albumNFO struct {
+	XMLName     xml.Name   `xml:"album"`
+	Title       string     `xml:"title"`
+	Artist      string     `xml:"artist"`
+	AlbumArtist string     `xml:"albumartist"`
+	Year        string     `xml:"year,omitempty"`
+	Type        string     `xml:"type,omitempty"`
+	Thumb       string     `xml:"thumb,omitempty"`
+	Tracks      []nfoTrack `xml:"track"`
+}
+
+type nfoTrack struct {
+	Position int    `xml:"position"`
+	Title    string `xml:"title"`
+	Duration string `xml:"duration,omitempty"`
+}
+
+// artistNFO is the artist.nfo of a media center's artist information folder
+type artistNFO struct {
+	XMLName xml.Name     `xml:"artist"`
+	Name    string       `xml:"name"`
+	Albums  []nfoAlbum   `xml:"album"`
+	Other   []nfoElement `xml:",any"` // Biography, images and the like, kept as they are
+}
+
+type nfoAlbum struct {
+	Title string `xml:"title"`
+	Year  string `xml:"year,omitempty"`
+}
+
+// nfoElement is an element gomusic doesn't know, written back unchanged
+type nfoElement struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+// writeAlbumNFO describes a downloaded album for media centers: album.nfo
+// and folder.jpg in the album folder, and the album added to the artist's
+// discography in the artist information folder when one is set
+func writeAlbumNFO(albumDir string, album songItem, tracks []songItem, coverPath string) error {
+	nfo := albumNFO{
+		Title:       albumDirName(album),
+		Artist:      album.author,
+		AlbumArtist: primaryArtist(album.author),
+		Year:        album.year,
+		Type:        album.category,
+		Thumb:       album.thumb,
+	}
+	for i, t := range tracks {
+		track := nfoTrack{Position: i + 1, Title: t.title}
+		if t.duration > 0 {
+			track.Duration = formatDuration(time.Duration(t.duration) * time.Second)
+		}
+		nfo.Tracks = append(nfo.Tracks, track)
+	}
+	if err := writeNFO(filepath.Join(albumDir, "album.nfo"), nfo); err != nil {
+		return err
+	}
+	if coverPath != "" {
+		data, err := os.ReadFile(coverPath)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(albumDir, "folder.jpg"), data, 0644); err != nil {
+			return err
+		}
+	}
+	if cfg.Download.ArtistInfoDir == "" || nfo.AlbumArtist == "" {
+		return nil
+	}
+	return addToArtistNFO(safeJoin(expandHome(cfg.Download.ArtistInfoDir), nfo.AlbumArtist), nfo)
+}
+
+// addToArtistNFO adds album to the discography in dir/artist.nfo, keeping
+// whatever a media center or the user put there
+func addToArtistNFO(dir string, album albumNFO) error {
+	path := filepath.Join(dir, "artist.nfo")
+	nfo := artistNFO{Name: album.AlbumArtist}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := xml.Unmarshal(data, &nfo); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	for _, a := range nfo.Albums {
+		if strings.EqualFold(a.Title, album.Title) {
+			return nil
+		}
+	}
+	nfo.Albums = append(nfo.Albums, nfoAlbum{Title: album.Title, Year: album.Year})
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeNFO(path, nfo)
+}
+
+// writeNFO saves v as an indented XML document
+func writeNFO(path string, v any) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	return os.WriteFile(path, data, 0644)
+}
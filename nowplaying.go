@@ -0,0 +1,152 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// lyricTick is how often the position, lyrics and pulse are refreshed while a track plays
+const lyricTick = 200 * time.Millisecond
+
+// playing reports whether a track is loaded, paused or not
+func (m *model) playing() bool {
+	return m.playback.playingSong != ""
+}
+
+// startTicker starts the lyric ticker unless it is already running. One
+// ticker serves every screen, so leaving and re-entering the playing screen
+// doesn't stack a second one.
+func (m *model) startTicker() tea.Cmd {
+	if m.playback.ticking {
+		return nil
+	}
+	m.playback.ticking = true
+	return tea.Tick(lyricTick, func(t time.Time) tea.Msg {
+		return lyricTickMsg(t)
+	})
+}
+
+// showsPlayback reports whether the current screen is about the playing track
+// itself, so it needs no now-playing bar
+func (m *model) showsPlayback() bool {
+	switch m.state {
+	case statePlaying, stateLoading, stateLyricsSearch, stateLyricsResults:
+		return true
+	}
+	return false
+}
+
+// browseBack leaves the playing screen for the list the track was started
+// from and keeps it playing
+func (m *model) browseBack() {
+	clearKittyImages()
+	switch {
+	case m.selected.path != "":
+		m.state = stateLibrary
+	case len(m.albumTracks) > 0 && m.albumTrackList.Width() > 0:
+		m.state = stateViewingAlbumTracks
+	default:
+		m.state = stateSelecting
+	}
+}
+
+// typing reports whether the current screen takes text, so keys belong to
+// the input instead of controlling playback
+func (m *model) typing() bool {
+	switch m.state {
+	case stateInput, stateLyricsSearch, stateDownloadOptions, stateLibrarySearch:
+		return true
+	case stateSettings:
+		return m.settingsEditing
+	case stateSelecting:
+		return m.list.FilterState() == list.Filtering
+	case stateViewingAlbumTracks:
+		return m.albumTrackList.FilterState() == list.Filtering
+	case stateLibrary:
+		return m.libraryList.FilterState() == list.Filtering
+	case stateRelatedAlbums:
+		return m.relatedLists[m.relatedTab].FilterState() == list.Filtering
+	case stateFilteredTracks:
+		return m.filteredList.FilterState() == list.Filtering
+	}
+	return false
+}
+
+// playbackKey applies the playback keys that work on every screen while a
+// track plays, reporting whether key was one of them. Screens taking text
+// keep the keys for their input.
+func (m *model) playbackKey(key string) (tea.Cmd, bool) {
+	if !m.playing() || m.typing() {
+		return nil, false
+	}
+	switch key {
+	case " ":
+		m.togglePause()
+		if m.playback.isPaused {
+			return nil, true
+		}
+		m.syncPosition()
+		return m.startTicker(), true
+	case "n":
+		cmd, ok := m.playNext()
+		if !ok {
+			m.notice = "No more tracks in the queue"
+		}
+		return cmd, true
+	case "s":
+		m.stopPlayback()
+		return nil, true
+	}
+	return nil, false
+}
+
+// playNext starts the next queued track, staying on the current screen unless
+// it is about the playing track. It returns false at the end of the queue.
+func (m *model) playNext() (tea.Cmd, bool) {
+	next, ok := m.queue.advance()
+	if !ok {
+		return nil, false
+	}
+	if m.showsPlayback() {
+		return m.startPlayback(next), true
+	}
+	state := m.state
+	cmd := m.startPlayback(next)
+	m.state = state
+	return cmd, true
+}
+
+// renderNowPlayingBar is the one-line summary of the playing track pinned
+// under the other screens, empty when nothing plays
+func (m *model) renderNowPlayingBar() string {
+	if !m.playing() || m.showsPlayback() {
+		return ""
+	}
+	pos := formatDuration(m.playback.position)
+	if w := m.playback.waveform; w != nil && w.Duration > 0 {
+		pos += " / " + formatDuration(w.Duration)
+	}
+	status := "▶"
+	if m.playback.isPaused {
+		status = "⏸"
+	}
+	bar := statusStyle.Render("♪ "+m.playback.playingSong+"  "+pos+"  "+status) +
+		helpStyle.Render("  •  "+keyLabel("pause")+": Play/Pause  •  "+keyLabel("next")+": Next  •  "+keyLabel("stop")+": Stop  •  Ctrl+P: Player")
+	if m.width > 0 {
+		bar = lipgloss.NewStyle().MaxWidth(m.width - 2).Render(bar)
+	}
+	return "  " + bar
+}
+
+// View draws the current screen with the now-playing bar at the bottom while
+// a track plays behind it
+func (m model) View() string {
+	s := m.view()
+	if bar := m.renderNowPlayingBar(); bar != "" {
+		s += "\n" + bar
+	}
+	return s
+}
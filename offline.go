@@ -0,0 +1,25 @@
+package main
+
+import "fmt"
+
+// offlineMode is set by --offline: only the local library is available and
+// nothing touches the network
+var offlineMode bool
+
+// requireNetwork reports whether a network feature may run. In offline mode it
+// leaves a notice explaining why the key did nothing.
+func (m *model) requireNetwork(feature string) bool {
+	if !offlineMode {
+		return true
+	}
+	m.notice = fmt.Sprintf("Offline: %s needs a network connection (started with --offline)", feature)
+	return false
+}
+
+// renderNotice shows the last notice, if any, below a screen
+func (m model) renderNotice() string {
+	if m.notice == "" {
+		return ""
+	}
+	return "\n\n  " + errorStyle.Render(m.notice)
+}
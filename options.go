@@ -0,0 +1,579 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kkdai/youtube/v2"
+)
+
+// previewLength is how much audio is played around a cut point
+const previewLength = 5 * time.Second
+
+// downloadConfig controls single-track downloads
+type downloadConfig struct {
+	Prompt bool `toml:"prompt,omitempty"` // Ask for per-track options before every download
+	Zip    bool `toml:"zip,omitempty"`    // Also package finished albums as a zip
+
+	NFO           bool   `toml:"nfo,omitempty"`             // Write album.nfo and folder.jpg for Kodi and Jellyfin
+	ArtistInfoDir string `toml:"artist_info_dir,omitempty"` // Media center artist information folder that gets artist.nfo files
+
+	Dir      string `toml:"dir,omitempty"`      // Folder downloads are saved in and the library is read from, the working directory if not set
+	Template string `toml:"template,omitempty"` // Path of each download under dir, e.g. "{artist}/{album}/{track:02d} - {title}.{ext}"
+
+	Format      string `toml:"format,omitempty"`      // A downloadFormats key, "mp3" by default
+	Quality     string `toml:"quality,omitempty"`     // "best", "high" (default), "medium" or a bitrate like "256k"
+	Passthrough bool   `toml:"passthrough,omitempty"` // Save YouTube's Opus or AAC audio as it is instead of re-encoding it
+
+	StreamQuality string `toml:"stream_quality,omitempty"` // Which YouTube stream to download: "high" (default), "medium" or "low"
+	PickStream    bool   `toml:"pick_stream,omitempty"`    // List the streams to pick from before every track download
+}
+
+// downloadTemplateFields are the fields a download template can use
+var downloadTemplateFields = []string{"artist", "album_artist", "album", "title", "track", "year", "ext"}
+
+func (c downloadConfig) validate() error {
+	if _, ok := downloadFormats[c.Format]; !ok && c.Format != "" {
+		return fmt.Errorf("download: format must be %s, got %q", strings.Join(formatOrder, ", "), c.Format)
+	}
+	if _, ok := qualityPresets[c.Quality]; !ok && c.Quality != "" && !isBitrate(c.Quality) {
+		return fmt.Errorf("download: quality must be best, high, medium or a bitrate like 256k, got %q", c.Quality)
+	}
+	if err := validStreamQuality("download", c.StreamQuality); err != nil {
+		return err
+	}
+	if c.Template == "" {
+		return nil
+	}
+	return validateTemplate("download", c.Template, downloadTemplateFields)
+}
+
+// templateValues are the download template fields of a track. Tracks
+// without an album go to "Singles" and track numbers are two digits unless
+// the template gives a width.
+func templateValues(title, artist, albumArtist, album string, track int, year, ext string) map[string]string {
+	values := map[string]string{
+		"artist": artist, "album_artist": albumArtist, "album": album,
+		"title": title, "year": year, "ext": ext,
+	}
+	if album == "" {
+		values["album"] = "Singles"
+	}
+	if albumArtist == "" {
+		values["album_artist"] = artist
+	}
+	if track > 0 {
+		values["track"] = fmt.Sprintf("%02d", track)
+	}
+	return values
+}
+
+// templatePath returns the download.template path of a track under dir. The
+// extension is added when the template leaves out {ext}.
+func templatePath(dir string, values map[string]string) string {
+	path := expandTemplate(cfg.Download.Template, values)
+	if !strings.Contains(cfg.Download.Template, "{ext}") {
+		path += "." + values["ext"]
+	}
+	return filepath.Join(dir, path)
+}
+
+// downloadOptions are per-download tweaks applied while converting
+type downloadOptions struct {
+	trimStart time.Duration
+	trimEnd   time.Duration // Zero keeps the track up to its end
+	fadeIn    time.Duration
+	fadeOut   time.Duration
+	format    string    // A downloadFormats key, empty for download.format
+	quality   string    // "best", "high", "medium" or a bitrate like "256k", empty for download.quality
+	folder    string    // Directory to save into, empty for the working directory
+	fileName  string    // Name without extension, empty for the track title
+	tracklist []chapter // Songs to split the download into, nil to keep it whole
+	copy      bool      // Keep the source audio as it is, see forSource
+	itag      int       // YouTube stream picked to download, 0 for bestAudioFormat's choice
+}
+
+// downloadFormat is a container tracks can be saved as
+type downloadFormat struct {
+	ext     string
+	label   string // Shown on the album download confirmation
+	cover   bool   // ffmpeg can embed the cover as an attached picture
+	bitrate int    // Rough bits per second at the default quality, for size estimates
+}
+
+// downloadFormats are the containers tracks can be saved as
+var downloadFormats = map[string]downloadFormat{
+	"mp3":  {".mp3", "MP3 (VBR ~190 kbit/s)", true, 190_000},
+	"m4a":  {".m4a", "M4A (AAC 192 kbit/s)", true, 192_000},
+	"opus": {".opus", "Opus (160 kbit/s)", false, 160_000},
+	"ogg":  {".ogg", "Ogg Vorbis (~190 kbit/s)", false, 190_000},
+	"flac": {".flac", "FLAC (lossless copy of the lossy source)", true, 900_000},
+}
+
+// formatOrder is the order F on the album download confirmation cycles through
+var formatOrder = []string{"mp3", "m4a", "opus", "ogg", "flac"}
+
+// qualityPresets map named qualities to an MP3 VBR level, an AAC and an Opus
+// bitrate and a Vorbis quality
+var qualityPresets = map[string]struct{ vbr, aac, opus, vorbis string }{
+	"best":   {"0", "256k", "192k", "8"},
+	"high":   {"2", "192k", "160k", "6"},
+	"medium": {"5", "128k", "96k", "4"},
+}
+
+// outputPath returns where the converted track is saved, creating its folder.
+// A file name given for this download wins over download.template.
+func (o downloadOptions) outputPath(item songItem, title, artist, year string) (string, error) {
+	ext := o.container().ext
+	dir := o.folder
+	if dir == "" {
+		dir = libraryDir()
+	}
+	var path string
+	if o.fileName != "" || cfg.Download.Template == "" {
+		name := title
+		if o.fileName != "" {
+			name = o.fileName
+		}
+		path = filepath.Join(dir, sanitizeFilename(name+ext))
+	} else {
+		path = templatePath(dir, templateValues(title, artist, "", item.album, 0, year, strings.TrimPrefix(ext, ".")))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// sourceFormat returns the format that holds the audio of f unchanged
+func sourceFormat(f *youtube.Format) (string, bool) {
+	switch codec := formatAudioInfo(f).codec; {
+	case codec == "opus":
+		return "opus", true
+	case strings.HasPrefix(codec, "mp4a"):
+		return "m4a", true
+	}
+	return "", false
+}
+
+// forSource switches to saving the audio of f without re-encoding when
+// passthrough is on and nothing calls for a new encode: a different format
+// picked for this download, or fades
+func (o downloadOptions) forSource(f *youtube.Format, passthrough bool) downloadOptions {
+	src, ok := sourceFormat(f)
+	if !passthrough || !ok || o.fadeIn > 0 || o.fadeOut > 0 || (o.format != "" && o.format != src) {
+		return o
+	}
+	o.format, o.copy = src, true
+	return o
+}
+
+// container returns the format the track is saved in
+func (o downloadOptions) container() downloadFormat {
+	if f, ok := downloadFormats[o.withDefaults().format]; ok {
+		return f
+	}
+	return downloadFormats["mp3"]
+}
+
+// withDefaults fills in the format and quality left empty from download.format
+// and download.quality
+func (o downloadOptions) withDefaults() downloadOptions {
+	if o.format == "" {
+		o.format = cfg.Download.Format
+	}
+	if o.quality == "" {
+		o.quality = cfg.Download.Quality
+	}
+	return o
+}
+
+// mapArgs picks the audio of the first input and, where the container can
+// hold it, the cover of the second
+func (o downloadOptions) mapArgs(hasCover bool) []string {
+	if hasCover && o.container().cover {
+		return []string{"-map", "0:0", "-map", "1:0"}
+	}
+	return []string{"-map", "0:0"}
+}
+
+// codecArgs returns the ffmpeg encoder, tag and cover options for the chosen
+// format and quality
+func (o downloadOptions) codecArgs() []string {
+	o = o.withDefaults()
+	preset, named := qualityPresets[o.quality]
+	if o.quality == "" {
+		preset, named = qualityPresets["high"], true
+	}
+	// bitrate is the rate of the named quality, or the one given as quality
+	bitrate := func(presetRate string) []string {
+		if named {
+			return []string{"-b:a", presetRate}
+		}
+		return []string{"-b:a", o.quality}
+	}
+	switch {
+	case o.copy && o.format == "m4a":
+		return []string{"-c:a", "copy", "-c:v", "copy", "-disposition:v:0", "attached_pic", "-movflags", "use_metadata_tags"}
+	case o.copy:
+		return []string{"-c:a", "copy"}
+	}
+	switch o.format {
+	case "m4a":
+		return append(append([]string{"-c:a", "aac"}, bitrate(preset.aac)...),
+			"-c:v", "copy", "-disposition:v:0", "attached_pic", "-movflags", "use_metadata_tags")
+	case "opus":
+		// Vorbis comments; ffmpeg can't mux the cover into Ogg
+		return append([]string{"-c:a", "libopus"}, bitrate(preset.opus)...)
+	case "ogg":
+		if named {
+			return []string{"-c:a", "libvorbis", "-q:a", preset.vorbis}
+		}
+		return []string{"-c:a", "libvorbis", "-b:a", o.quality}
+	case "flac":
+		// Lossless, so quality doesn't apply
+		return []string{"-c:a", "flac", "-c:v", "mjpeg", "-disposition:v:0", "attached_pic",
+			"-metadata:s:v", "comment=Cover (front)"}
+	}
+	args := []string{"-c:a", "libmp3lame"}
+	if named {
+		args = append(args, "-q:a", preset.vbr)
+	} else {
+		args = append(args, "-b:a", o.quality)
+	}
+	return append(args,
+		"-id3v2_version", "3",
+		"-metadata:s:v", "title=\"Album cover\"",
+		"-metadata:s:v", "comment=\"Cover (Front)\"",
+	)
+}
+
+// expandHome replaces a leading ~ with the home directory
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// inputArgs returns the ffmpeg options placed before the audio "-i". Cutting
+// on the input side leaves the cover image input untouched.
+func (o downloadOptions) inputArgs() []string {
+	var args []string
+	if o.trimStart > 0 {
+		args = append(args, "-ss", ffmpegTime(o.trimStart))
+	}
+	if o.trimEnd > 0 {
+		args = append(args, "-to", ffmpegTime(o.trimEnd))
+	}
+	return args
+}
+
+// filterArgs returns the audio filter for fades. The fade-out is placed
+// relative to the end of the saved file, so it needs the source length full.
+func (o downloadOptions) filterArgs(full time.Duration) []string {
+	var filters []string
+	if o.fadeIn > 0 {
+		filters = append(filters, fmt.Sprintf("afade=t=in:st=0:d=%s", ffmpegTime(o.fadeIn)))
+	}
+	if out := o.outputDuration(full); o.fadeOut > 0 && out > 0 {
+		start := out - o.fadeOut
+		if start < 0 {
+			start = 0
+		}
+		filters = append(filters, fmt.Sprintf("afade=t=out:st=%s:d=%s", ffmpegTime(start), ffmpegTime(o.fadeOut)))
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	return []string{"-af", strings.Join(filters, ",")}
+}
+
+// outputDuration is the expected length of the saved file for a source of
+// length full (zero when unknown, which skips the duration check)
+func (o downloadOptions) outputDuration(full time.Duration) time.Duration {
+	if full == 0 {
+		return 0
+	}
+	end := full
+	if o.trimEnd > 0 && o.trimEnd < end {
+		end = o.trimEnd
+	}
+	return end - o.trimStart
+}
+
+// adjustLyrics shifts synced lyrics onto the trimmed timeline and drops the
+// lines that were cut
+func (o downloadOptions) adjustLyrics(lines []LyricLine) []LyricLine {
+	if o.trimStart == 0 && o.trimEnd == 0 {
+		return lines
+	}
+	var out []LyricLine
+	for _, l := range lines {
+		if l.Timestamp < o.trimStart || (o.trimEnd > 0 && l.Timestamp >= o.trimEnd) {
+			continue
+		}
+		out = append(out, LyricLine{Timestamp: l.Timestamp - o.trimStart, Text: l.Text})
+	}
+	return out
+}
+
+func ffmpegTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// parseOffset reads a position written as seconds ("90"), m:ss ("1:30") or
+// h:mm:ss ("1:02:03.5"). An empty string is zero.
+func parseOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	var total float64
+	for _, part := range strings.Split(s, ":") {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid time %q, use seconds or m:ss", s)
+		}
+		total = total*60 + v
+	}
+	return time.Duration(total * float64(time.Second)), nil
+}
+
+// Fields of the download options prompt, in focus order
+const (
+	optTrimStart = iota
+	optTrimEnd
+	optFadeIn
+	optFadeOut
+	optFormat
+	optQuality
+	optFolder
+	optFileName
+	optTracklist
+	optFieldCount
+)
+
+var optionLabels = [optFieldCount]string{
+	optTrimStart: "Trim start",
+	optTrimEnd:   "Trim end",
+	optFadeIn:    "Fade in",
+	optFadeOut:   "Fade out",
+	optFormat:    "Format",
+	optQuality:   "Quality",
+	optFolder:    "Folder",
+	optFileName:  "File name",
+	optTracklist: "Tracklist",
+}
+
+var optionPlaceholders = [optFieldCount]string{
+	optTrimStart: "0:00",
+	optTrimEnd:   "end of track",
+	optFadeIn:    "none",
+	optFadeOut:   "none",
+	optFormat:    "mp3 (m4a, opus, ogg, flac)",
+	optQuality:   "high (best, medium, 256k)",
+	optFolder:    "current directory",
+	optFileName:  "track title",
+	optTracklist: "0:00 Song A / 3:45 Song B to split",
+}
+
+// openDownloadOptions shows the options prompt for downloading item
+func (m *model) openDownloadOptions(item songItem) tea.Cmd {
+	m.optionsItem = item
+	m.optionsReturn = m.state
+	m.optionsErr = nil
+	m.optionsStatus = ""
+	m.optionsFocus = 0
+	m.optionInputs = make([]textinput.Model, optFieldCount)
+	for i := range m.optionInputs {
+		ti := textinput.New()
+		ti.Placeholder = optionPlaceholders[i]
+		ti.CharLimit = 12
+		ti.Width = 14
+		if i >= optFormat {
+			ti.CharLimit = 200
+			ti.Width = 30
+		}
+		if i == optTracklist {
+			ti.CharLimit = 10000 // Full album uploads list dozens of songs
+		}
+		m.optionInputs[i] = ti
+	}
+	m.optionInputs[0].Focus()
+	m.state = stateDownloadOptions
+	return textinput.Blink
+}
+
+// parseDownloadOptions validates the prompt fields
+func (m *model) parseDownloadOptions() (downloadOptions, error) {
+	var opts downloadOptions
+	var err error
+	if opts.trimStart, err = parseOffset(m.optionInputs[optTrimStart].Value()); err != nil {
+		return opts, err
+	}
+	if opts.trimEnd, err = parseOffset(m.optionInputs[optTrimEnd].Value()); err != nil {
+		return opts, err
+	}
+	if opts.fadeIn, err = parseOffset(m.optionInputs[optFadeIn].Value()); err != nil {
+		return opts, err
+	}
+	if opts.fadeOut, err = parseOffset(m.optionInputs[optFadeOut].Value()); err != nil {
+		return opts, err
+	}
+	opts.format = strings.ToLower(strings.TrimSpace(m.optionInputs[optFormat].Value()))
+	if _, ok := downloadFormats[opts.format]; !ok && opts.format != "" {
+		return opts, fmt.Errorf("format must be %s", strings.Join(formatOrder, ", "))
+	}
+	opts.quality = strings.ToLower(strings.TrimSpace(m.optionInputs[optQuality].Value()))
+	if _, ok := qualityPresets[opts.quality]; !ok && opts.quality != "" && !isBitrate(opts.quality) {
+		return opts, fmt.Errorf("quality must be best, high, medium or a bitrate like 256k")
+	}
+	opts.folder = expandHome(strings.TrimSpace(m.optionInputs[optFolder].Value()))
+	opts.fileName = strings.TrimSpace(m.optionInputs[optFileName].Value())
+	if opts.trimEnd > 0 && opts.trimEnd <= opts.trimStart {
+		return opts, fmt.Errorf("trim end must be after trim start")
+	}
+	dur := time.Duration(m.optionsItem.duration) * time.Second
+	if dur > 0 && opts.trimStart >= dur {
+		return opts, fmt.Errorf("trim start is past the end of the track (%s)", formatDuration(dur))
+	}
+	if out := opts.outputDuration(dur); out > 0 && opts.fadeIn+opts.fadeOut > out {
+		return opts, fmt.Errorf("fades are longer than the saved track (%s)", formatDuration(out))
+	}
+	if text := strings.TrimSpace(m.optionInputs[optTracklist].Value()); text != "" {
+		if opts.trimStart > 0 || opts.trimEnd > 0 || opts.fadeIn > 0 || opts.fadeOut > 0 {
+			return opts, fmt.Errorf("a tracklist can't be combined with trims or fades")
+		}
+		if opts.tracklist, err = parseTracklist(text); err != nil {
+			return opts, err
+		}
+		if err := tracklistEnd(opts.tracklist, dur); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// isBitrate reports whether s is a bitrate such as 192k
+func isBitrate(s string) bool {
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "k"))
+	return err == nil && strings.HasSuffix(s, "k") && n >= 32 && n <= 320
+}
+
+// previewRange returns the stretch of audio to play for the focused cut point
+func (m *model) previewRange(opts downloadOptions) (time.Duration, error) {
+	if m.optionsFocus == optTrimStart || m.optionsFocus == optFadeIn {
+		return opts.trimStart, nil
+	}
+	end := opts.trimEnd
+	if end == 0 {
+		end = time.Duration(m.optionsItem.duration) * time.Second
+	}
+	if end == 0 {
+		return 0, fmt.Errorf("enter a trim end to preview it")
+	}
+	if end < previewLength {
+		return 0, nil
+	}
+	return end - previewLength, nil
+}
+
+func (m *model) focusOption(i int) {
+	m.optionInputs[m.optionsFocus].Blur()
+	m.optionsFocus = (i + optFieldCount) % optFieldCount
+	m.optionInputs[m.optionsFocus].Focus()
+}
+
+func (m model) updateDownloadOptions(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.stopPlayback()
+		m.state = m.optionsReturn
+		return m, nil
+	case "tab", "down":
+		m.focusOption(m.optionsFocus + 1)
+		return m, nil
+	case "shift+tab", "up":
+		m.focusOption(m.optionsFocus - 1)
+		return m, nil
+	case "ctrl+p":
+		if m.optionsFocus > optFadeOut {
+			m.optionsErr = fmt.Errorf("move to a trim or fade field to preview it")
+			return m, nil
+		}
+		opts, err := m.parseDownloadOptions()
+		if err == nil && audioDeviceErr != nil {
+			err = errNoAudio()
+		}
+		if err == nil {
+			var from time.Duration
+			if from, err = m.previewRange(opts); err == nil {
+				m.stopPlayback()
+				m.optionsErr = nil
+				m.startVolume()
+				m.optionsStatus = fmt.Sprintf("Previewing %s – %s...", formatDuration(from), formatDuration(from+previewLength))
+				go m.previewSegment(playbackJob.start(), m.optionsItem, from, previewLength)
+				return m, nil
+			}
+		}
+		m.optionsErr = err
+		return m, nil
+	case "enter", "ctrl+s":
+		opts, err := m.parseDownloadOptions()
+		if err != nil {
+			m.optionsErr = err
+			return m, nil
+		}
+		m.stopPlayback()
+		m.dlOptions = opts
+		if cfg.Download.PickStream || msg.String() == "ctrl+s" {
+			return m, m.openStreamPicker(m.optionsItem)
+		}
+		m.selected = m.optionsItem
+		m.state = stateDownloading
+		go m.runDownloadConvert(downloadJob.start())
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.optionInputs[m.optionsFocus], cmd = m.optionInputs[m.optionsFocus].Update(msg)
+	return m, cmd
+}
+
+func (m model) viewDownloadOptions() string {
+	var rows []string
+	for i, input := range m.optionInputs {
+		if i == optFormat {
+			rows = append(rows, "")
+		}
+		label := fmt.Sprintf("%-12s", optionLabels[i])
+		if i == m.optionsFocus {
+			label = statusStyle.Render(label)
+		}
+		rows = append(rows, label+" "+input.View())
+	}
+
+	status := helpStyle.Render("Times as seconds or m:ss; empty fields keep the defaults")
+	if m.optionsErr != nil {
+		status = errorStyle.Render(m.optionsErr.Error())
+	} else if m.optionsStatus != "" {
+		status = statusStyle.Render(m.optionsStatus)
+	}
+
+	return fmt.Sprintf("\n  %s\n  %s\n\n  %s\n\n  %s\n\n  %s\n",
+		titleStyle.Render("Download Options"),
+		helpStyle.Render(m.optionsItem.title+" - "+m.optionsItem.author),
+		strings.Join(rows, "\n  "),
+		status,
+		helpStyle.Render("TAB: Next field  •  CTRL+P: Preview cut point  •  ENTER: Download  •  CTRL+S: Pick stream  •  ESC: Cancel"),
+	)
+}
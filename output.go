@@ -0,0 +1,174 @@
+//go:build !noplayback
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+// audioSink is where decoded playback goes: the sound card, or a pipe
+// feeding another player
+type audioSink interface {
+	Play(s ...beep.Streamer)
+	Lock()
+	Unlock()
+}
+
+// audioOut is the sink chosen by initSpeaker
+var audioOut audioSink = speakerSink{}
+
+type speakerSink struct{}
+
+func (speakerSink) Play(s ...beep.Streamer) { speaker.Play(s...) }
+func (speakerSink) Lock()                   { speaker.Lock() }
+func (speakerSink) Unlock()                 { speaker.Unlock() }
+
+// pipeSink mixes playback like the speaker does and writes it out in real
+// time, so pausing, seeking and lyric timing behave the same
+type pipeSink struct {
+	mu    sync.Mutex
+	mixer beep.Mixer
+}
+
+func (p *pipeSink) Play(s ...beep.Streamer) {
+	p.mu.Lock()
+	p.mixer.Add(s...)
+	p.mu.Unlock()
+}
+func (p *pipeSink) Lock()   { p.mu.Lock() }
+func (p *pipeSink) Unlock() { p.mu.Unlock() }
+
+// run streams the mix to w as 16-bit little-endian stereo PCM, one buffer
+// per tick. Silence keeps flowing between tracks so readers don't see EOF.
+func (p *pipeSink) run(w io.Writer, sr beep.SampleRate, tick time.Duration) error {
+	samples := make([][2]float64, sr.N(tick))
+	buf := make([]byte, len(samples)*4)
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for range ticker.C {
+		p.mu.Lock()
+		p.mixer.Stream(samples)
+		p.mu.Unlock()
+		for i, s := range samples {
+			binary.LittleEndian.PutUint16(buf[i*4:], uint16(pcm16(s[0])))
+			binary.LittleEndian.PutUint16(buf[i*4+2:], uint16(pcm16(s[1])))
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pcm16(v float64) int16 {
+	return int16(math.Max(-1, math.Min(v, 1)) * 32767)
+}
+
+// openOutputTarget opens the FIFO, file or stdout playback is written to.
+// Opening a FIFO blocks until a reader attaches.
+func openOutputTarget(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return os.Stdout, nil
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+}
+
+// nativeOutputCommand returns the helper that opens a PipeWire or JACK stream
+// named after the client and reads PCM on its stdin. o.Path, when set, picks
+// the target node (PipeWire) or the ports to connect to (JACK).
+func nativeOutputCommand(o outputConfig, sr beep.SampleRate) *exec.Cmd {
+	name := o.clientName()
+	if o.Mode == "jack" {
+		sink := fmt.Sprintf("jackaudiosink client-name=%q", name)
+		if o.Path != "" {
+			sink += fmt.Sprintf(" port-pattern=%q", o.Path)
+		}
+		return exec.Command("gst-launch-1.0", "-q",
+			"fdsrc", "fd=0", "!",
+			"rawaudioparse", "use-sink-caps=false", "format=pcm", "pcm-format=s16le",
+			fmt.Sprintf("sample-rate=%d", int(sr)), "num-channels=2", "!",
+			"audioconvert", "!",
+			sink,
+		)
+	}
+	args := []string{"--playback", "--raw",
+		"--format", "s16", "--rate", fmt.Sprint(int(sr)), "--channels", "2",
+		"--media-category", "Playback", "--media-role", "Music",
+		"--properties", fmt.Sprintf("{ application.name = %q node.name = %q node.description = %q media.name = %q }",
+			name, name, name, name),
+	}
+	if o.Path != "" {
+		args = append(args, "--target", o.Path)
+	}
+	return exec.Command("pw-cat", append(args, "-")...)
+}
+
+// startNativeOutput feeds the sink into a PipeWire or JACK stream
+func startNativeOutput(o outputConfig, sink *pipeSink, sr beep.SampleRate, buffer time.Duration) {
+	cmd := nativeOutputCommand(o, sr)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Output error: %v\n", err)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Output error: %s: %v\n", o.Mode, err)
+		return
+	}
+	defer cmd.Wait()
+	defer stdin.Close()
+
+	if err := sink.run(stdin, sr, buffer); err != nil {
+		fmt.Fprintf(os.Stderr, "Output error: %v\n", err)
+	}
+}
+
+// startPipeOutput feeds the sink into the configured target, through an
+// ffmpeg encoder when MP3 is wanted
+func startPipeOutput(o outputConfig, sink *pipeSink, sr beep.SampleRate, buffer time.Duration) {
+	target, err := openOutputTarget(o.Path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Output error: %v\n", err)
+		return
+	}
+	defer target.Close()
+
+	var w io.Writer = target
+	if o.Format == "mp3" {
+		cmd := exec.Command("ffmpeg",
+			"-loglevel", "error",
+			"-f", "s16le", "-ar", fmt.Sprint(int(sr)), "-ac", "2",
+			"-i", "pipe:0",
+			"-c:a", "libmp3lame", "-q:a", "2",
+			"-flush_packets", "1",
+			"-f", "mp3", "pipe:1",
+		)
+		cmd.Stdout = target
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Output error: %v\n", err)
+			return
+		}
+		if err := cmd.Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "Output error: %v\n", err)
+			return
+		}
+		defer cmd.Wait()
+		defer stdin.Close()
+		w = stdin
+	}
+
+	if err := sink.run(w, sr, buffer); err != nil {
+		fmt.Fprintf(os.Stderr, "Output error: %v\n", err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// owned tracks the music already on disk, so results can be marked with a ✓:
+// the IDs of the download archive and the artist and title of library files
+var owned = struct {
+	sync.RWMutex
+	ids    map[string]bool
+	titles map[string]bool // ownedKey of library tracks
+}{ids: map[string]bool{}, titles: map[string]bool{}}
+
+// ownedKey is how a track is matched against the library when it isn't in
+// the archive, e.g. downloaded before it existed
+func ownedKey(artist, title string) string {
+	return strings.ToLower(primaryArtist(artist) + "\x00" + strings.Join(strings.Fields(title), " "))
+}
+
+// markOwned adds a track just downloaded
+func markOwned(id string) {
+	owned.Lock()
+	defer owned.Unlock()
+	owned.ids[id] = true
+}
+
+// markOwnedLibrary replaces the library tracks with those of a new scan
+func markOwnedLibrary(index []libraryEntry) {
+	titles := make(map[string]bool, len(index))
+	for _, e := range index {
+		titles[ownedKey(e.artist, e.title)] = true
+	}
+	owned.Lock()
+	defer owned.Unlock()
+	owned.titles = titles
+}
+
+// loadOwned reads the archive in the background at startup. The library
+// tracks come from the startup library scan, see markOwnedLibrary.
+func loadOwned() tea.Cmd {
+	return func() tea.Msg {
+		ids := loadArchive()
+		owned.Lock()
+		for id := range ids {
+			owned.ids[id] = true
+		}
+		owned.Unlock()
+		return nil
+	}
+}
+
+// owned reports whether the track is in the download archive or the library
+func (i songItem) owned() bool {
+	if i.isAlbum || i.path != "" || i.id == "" {
+		return false
+	}
+	owned.RLock()
+	defer owned.RUnlock()
+	return owned.ids[i.id] || owned.titles[ownedKey(i.author, i.title)]
+}
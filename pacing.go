@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kkdai/youtube/v2"
+)
+
+// pacingConfig spaces out YouTube requests during album and batch downloads
+type pacingConfig struct {
+	Delay    time.Duration `toml:"delay"`    // Minimum gap between tracks
+	Jitter   time.Duration `toml:"jitter"`   // Random extra gap, up to this much
+	Cooldown time.Duration `toml:"cooldown"` // Pause after YouTube starts throttling
+}
+
+// pacer enforces the configured gaps between successive track downloads
+type pacer struct {
+	mu        sync.Mutex
+	cfg       pacingConfig
+	last      time.Time
+	coolUntil time.Time
+	strikes   int // Consecutive throttled attempts, doubles the cool-down
+}
+
+func newPacer(c pacingConfig) *pacer {
+	return &pacer{cfg: c}
+}
+
+// wait blocks until the next request may go out
+func (p *pacer) wait() {
+	p.mu.Lock()
+	next := p.last.Add(p.cfg.Delay)
+	if p.cfg.Jitter > 0 {
+		next = next.Add(rand.N(p.cfg.Jitter))
+	}
+	if p.coolUntil.After(next) {
+		next = p.coolUntil
+	}
+	p.mu.Unlock()
+
+	if d := time.Until(next); d > 0 {
+		time.Sleep(d)
+	}
+
+	p.mu.Lock()
+	p.last = time.Now()
+	p.mu.Unlock()
+}
+
+// coolDown records a throttled request and returns when requests may resume.
+// Each consecutive strike doubles the pause.
+func (p *pacer) coolDown() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.coolUntil = time.Now().Add(p.cfg.Cooldown << min(p.strikes, 4))
+	p.strikes++
+	return p.coolUntil
+}
+
+// succeeded resets the cool-down escalation after a request went through
+func (p *pacer) succeeded() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.strikes = 0
+}
+
+func cooldownTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return cooldownTickMsg{}
+	})
+}
+
+// isThrottled reports whether err means YouTube is rate limiting us
+func isThrottled(err error) bool {
+	var status youtube.ErrUnexpectedStatusCode
+	if errors.As(err, &status) && status == 429 {
+		return true
+	}
+	var stale *staleStreamError
+	return errors.As(err, &stale) && stale.reason == "throttled"
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFilenameBytes keeps names comfortably below the 255 byte limit of common filesystems
+const maxFilenameBytes = 200
+
+// reservedWindowsNames are device names Windows refuses as file names, even with an extension
+var reservedWindowsNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// maxExtensionBytes is the longest suffix taken for a file extension, so the
+// ". Remix" of "Song ft. Remix" isn't one
+const maxExtensionBytes = 6
+
+// sanitizeFilename makes s usable as a single path component on every platform.
+// Separators and characters invalid on Windows become "_", trailing dots and
+// spaces (silently stripped by Windows) are removed, and reserved device names
+// are prefixed so "CON" or "nul.mp3" don't end up addressing a device. Long
+// names are shortened before their extension, which is kept.
+func sanitizeFilename(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			b.WriteRune('_')
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	name := strings.TrimLeft(b.String(), " ")
+	name = strings.TrimRight(name, ". ")
+
+	if len(name) > maxFilenameBytes {
+		stem, ext := name, filepath.Ext(name)
+		if len(ext) <= maxExtensionBytes && !strings.ContainsRune(ext, ' ') {
+			stem = strings.TrimSuffix(name, ext)
+		} else {
+			ext = ""
+		}
+		for len(stem)+len(ext) > maxFilenameBytes {
+			_, size := utf8.DecodeLastRuneInString(stem)
+			stem = strings.TrimRight(stem[:len(stem)-size], ". ")
+		}
+		name = stem + ext
+	}
+
+	base := name
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	if reservedWindowsNames[strings.ToUpper(strings.TrimSpace(base))] {
+		name = "_" + name
+	}
+
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// safeJoin sanitizes each name and joins them under dir. dir itself is trusted
+// (it comes from the user), while names come from remote metadata and can never
+// introduce extra directories, drive letters or an absolute path.
+func safeJoin(dir string, names ...string) string {
+	parts := []string{dir}
+	for _, name := range names {
+		parts = append(parts, sanitizeFilename(name))
+	}
+	return filepath.Join(parts...)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFilenameKeepsExtension(t *testing.T) {
+	for _, title := range []string{
+		strings.Repeat("Some Long Title ", 19)[:300],
+		strings.Repeat("長いタイトル", 17), // 306 bytes
+	} {
+		name := sanitizeFilename("01 - " + title + ".mp3")
+		if len(name) > maxFilenameBytes {
+			t.Errorf("%d bytes, want at most %d", len(name), maxFilenameBytes)
+		}
+		if !strings.HasSuffix(name, ".mp3") || !isAudioFile(name) {
+			t.Errorf("%q lost its extension", name)
+		}
+		if !utf8.ValidString(name) {
+			t.Errorf("%q was cut mid-rune", name)
+		}
+	}
+}
+
+func TestSanitizeFilenameShort(t *testing.T) {
+	tests := map[string]string{
+		"a/b:c.mp3": "a_b_c.mp3",
+		"CON.mp3":   "_CON.mp3",
+		"trail. ":   "trail",
+		"":          "_",
+	}
+	for in, want := range tests {
+		if got := sanitizeFilename(in); got != want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
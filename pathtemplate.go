@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templateField matches the {placeholders} of a path template, with an
+// optional printf width for numbers as in {track:02d}
+var templateField = regexp.MustCompile(`\{([a-z_]+)(?::(\d*)d)?\}`)
+
+// validateTemplate checks the path template of a config section against the
+// fields it may use
+func validateTemplate(section, tmpl string, fields []string) error {
+	if filepath.IsAbs(tmpl) {
+		return fmt.Errorf("%s: template is relative to the library, got %q", section, tmpl)
+	}
+	known := map[string]bool{}
+	for _, f := range fields {
+		known[f] = true
+	}
+	for _, field := range templateField.FindAllStringSubmatch(tmpl, -1) {
+		if !known[field[1]] {
+			names := make([]string, len(fields))
+			for i, f := range fields {
+				names[i] = "{" + f + "}"
+			}
+			return fmt.Errorf("%s: unknown template field {%s}, use %s or %s",
+				section, field[1], strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+		}
+	}
+	if !strings.Contains(tmpl, "{title") {
+		return fmt.Errorf("%s: template needs {title}, or tracks would overwrite each other", section)
+	}
+	return nil
+}
+
+// expandTemplate fills in the fields of tmpl and returns the relative path it
+// names. Every folder and the file name are made safe on their own, and
+// separators left over by empty fields are dropped, like " - Title" without
+// a track number.
+func expandTemplate(tmpl string, values map[string]string) string {
+	var parts []string
+	for _, segment := range strings.Split(filepath.ToSlash(tmpl), "/") {
+		segment = templateField.ReplaceAllStringFunc(segment, func(field string) string {
+			match := templateField.FindStringSubmatch(field)
+			value := values[match[1]]
+			if strings.Contains(field, ":") {
+				if n, err := strconv.Atoi(value); err == nil {
+					value = fmt.Sprintf("%"+match[2]+"d", n)
+				}
+			}
+			return value
+		})
+		segment = strings.Trim(segment, " -_.")
+		if segment != "" {
+			parts = append(parts, sanitizeFilename(segment))
+		}
+	}
+	return filepath.Join(parts...)
+}
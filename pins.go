@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// pinsKey is the state entry of the albums and artists pinned to the search screen
+const pinsKey = "pins"
+
+// maxPins is how many pins fit the row on the search screen
+const maxPins = 9
+
+// pin is an album or artist pinned for quick access. Artist pins keep the
+// artist's name as the title.
+type pin struct {
+	Artist bool       `json:"artist,omitempty"`
+	Item   savedTrack `json:"item"`
+}
+
+// key identifies the pin; artists go by name since not every result has their ID
+func (p pin) key() string {
+	if p.Artist {
+		return "artist:" + strings.ToLower(p.Item.Title)
+	}
+	return p.Item.ID
+}
+
+// label is how the pin shows in the row
+func (p pin) label() string {
+	if p.Artist {
+		return "♪ " + p.Item.Title
+	}
+	return "📀 " + strings.TrimSpace(strings.TrimSuffix(p.Item.Title, "("+p.Item.Year+")"))
+}
+
+func loadPins() []pin {
+	var pins []pin
+	loadState(pinsKey, &pins)
+	return pins
+}
+
+// pinFor is the pin for item: the album itself, or the artist of a track
+func pinFor(item songItem) pin {
+	if item.isAlbum {
+		return pin{Item: newSavedTrack(item)}
+	}
+	artist := savedTrack{Title: primaryArtist(item.author)}
+	if len(item.artistIDs) > 0 {
+		artist.ArtistIDs = item.artistIDs[:1]
+	}
+	return pin{Artist: true, Item: artist}
+}
+
+// togglePin pins the album item, or the artist of the track item, or unpins
+// it if it is pinned already
+func (m *model) togglePin(item songItem) {
+	p := pinFor(item)
+	if p.Item.Title == "" {
+		return
+	}
+	if i := slices.IndexFunc(m.pins, func(q pin) bool { return q.key() == p.key() }); i >= 0 {
+		m.removePin(i)
+		return
+	}
+	if len(m.pins) >= maxPins {
+		m.notice = fmt.Sprintf("Only %d pins fit; unpin one on the search screen with X first", maxPins)
+		return
+	}
+	m.pins = append(m.pins, p)
+	m.notice = "Pinned " + p.label() + " to the search screen"
+	m.savePins()
+}
+
+// removePin unpins the pin at i
+func (m *model) removePin(i int) {
+	m.notice = "Unpinned " + m.pins[i].label()
+	m.pins = slices.Delete(m.pins, i, i+1)
+	m.pinCursor = min(m.pinCursor, max(len(m.pins)-1, 0))
+	m.savePins()
+}
+
+func (m *model) savePins() {
+	if err := saveState(pinsKey, m.pins); err != nil {
+		m.notice = "Saving pins failed: " + err.Error()
+	}
+}
+
+// updatePins handles keys while the pin row of the search screen has focus
+func (m model) updatePins(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "left", "h", "shift+tab":
+		m.pinCursor = (m.pinCursor - 1 + len(m.pins)) % len(m.pins)
+	case "right", "l", "tab":
+		m.pinCursor = (m.pinCursor + 1) % len(m.pins)
+	case "up", "esc":
+		m.pinsFocused = false
+		m.textInput.Focus()
+	case "x", "delete":
+		m.removePin(m.pinCursor)
+		if len(m.pins) == 0 {
+			m.pinsFocused = false
+			m.textInput.Focus()
+		}
+	case "enter":
+		return m, m.openPin(m.pins[m.pinCursor])
+	}
+	return m, nil
+}
+
+// openPin shows the tracks of a pinned album, or searches the songs of a
+// pinned artist
+func (m *model) openPin(p pin) tea.Cmd {
+	if !m.requireNetwork("browsing pins") {
+		return nil
+	}
+	m.pinsFocused = false
+	m.textInput.Focus()
+	if p.Artist {
+		m.textInput.SetValue(p.Item.Title)
+		m.searchFilter = filterSongs
+		if m.restoreSearch(newSearchKey(p.Item.Title, m.searchFilter, m.lengthFilter)) {
+			return m.previewHighlighted()
+		}
+		m.state = stateSearching
+		return tea.Batch(m.spinner.Tick, searchSongs(searchJob.start(), p.Item.Title, m.searchFilter, m.lengthFilter))
+	}
+	item := p.Item.item()
+	item.isAlbum = true
+	m.selected = item
+	m.currentAlbum = item
+	m.albumFromInput = true
+	m.state = stateSearching
+	return tea.Batch(m.spinner.Tick, searchAlbumWithTracks(searchJob.start(), item))
+}
+
+// albumBack leaves the album view for the results it was opened from, or
+// the search screen for an album opened from a pin or a link
+func (m *model) albumBack() {
+	if m.albumFromInput {
+		m.state = stateInput
+		return
+	}
+	m.state = stateSelecting
+}
+
+// renderPins is the pin row of the search screen
+func (m model) renderPins() string {
+	if len(m.pins) == 0 {
+		return ""
+	}
+	labels := make([]string, len(m.pins))
+	for i, p := range m.pins {
+		style := lipgloss.NewStyle().Padding(0, 1)
+		if m.pinsFocused && i == m.pinCursor {
+			style = style.Reverse(true)
+		}
+		labels[i] = style.Render(p.label())
+	}
+	help := "↓: Pins"
+	if m.pinsFocused {
+		help = "←/→: Select  •  ENTER: Open  •  X: Unpin  •  ↑: Back to search"
+	}
+	return "\n\n  " + helpStyle.Render("Pinned") + "\n  " + strings.Join(labels, " ") + "\n  " + helpStyle.Render(help)
+}
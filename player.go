@@ -3,156 +3,207 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"os/exec"
 
 	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
 	"github.com/faiface/beep/mp3"
 	"github.com/faiface/beep/speaker"
 	"github.com/kkdai/youtube/v2"
 )
 
-func initSpeaker() {
-	sr := beep.SampleRate(44100)
-	speaker.Init(sr, sr.N(time.Second/10))
+// deviceRate is the sample rate everything is decoded to and played at
+var deviceRate = beep.SampleRate(defaultSampleRate)
+
+// handoffMP3Quality is the VBR level of the MP3 ffmpeg hands the decoder, V0
+// so it adds no audible loss to the stream playback.stream_codec and
+// playback.stream_bitrate picked
+const handoffMP3Quality = "0"
+
+// rateArg is deviceRate for ffmpeg's -ar, which resamples the source to it
+func rateArg() string {
+	return strconv.Itoa(int(deviceRate))
+}
+
+// initSpeaker opens the configured output; an error means the sound card
+// couldn't be opened
+func initSpeaker() error {
+	deviceRate = beep.SampleRate(cfg.Playback.rate())
+	buffer := cfg.Playback.bufferLength()
+	if cfg.Output.pipe() {
+		sink := &pipeSink{}
+		audioOut = sink
+		go startPipeOutput(cfg.Output, sink, deviceRate, buffer)
+		return nil
+	}
+	if cfg.Output.native() {
+		sink := &pipeSink{}
+		audioOut = sink
+		go startNativeOutput(cfg.Output, sink, deviceRate, buffer)
+		return nil
+	}
+	return speaker.Init(deviceRate, deviceRate.N(buffer))
+}
+
+// builtinStream holds the handles of whatever the built-in player is playing,
+// so the UI can pause, seek and stop it without the stream goroutine writing
+// model state
+type builtinStream struct {
+	mu     sync.Mutex
+	ctrl   *beep.Ctrl
+	volume *effects.Volume // Wraps ctrl
+	stop   func()          // Kills ffmpeg or closes a local queue
+	health *streamHealth   // nil for local files
+}
+
+var builtin builtinStream
+
+// set makes ctrl the stream the controls act on
+func (s *builtinStream) set(ctrl *beep.Ctrl, volume *effects.Volume, stop func(), health *streamHealth) {
+	s.mu.Lock()
+	s.ctrl, s.volume, s.stop, s.health = ctrl, volume, stop, health
+	s.mu.Unlock()
+}
+
+// current returns the playing stream's controller, nil when nothing plays
+func (s *builtinStream) current() *beep.Ctrl {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ctrl
+}
+
+// take forgets the current stream and returns its handles for shutdown
+func (s *builtinStream) take() (*beep.Ctrl, func(), *streamHealth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctrl, stop, health := s.ctrl, s.stop, s.health
+	s.ctrl, s.volume, s.stop, s.health = nil, nil, nil, nil
+	return ctrl, stop, health
 }
 
-func (m *model) runInternalPlayback(item songItem) {
+// currentVolume returns the playing stream's volume stage, nil when nothing plays
+func (s *builtinStream) currentVolume() *effects.Volume {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.volume
+}
+
+func (m *model) runInternalPlayback(ctx context.Context, item songItem) {
+	if item.path != "" {
+		m.runLocalPlayback(ctx, item)
+		return
+	}
+
 	// Validate track ID before attempting playback
 	if item.id == "" || len(item.id) < 10 {
 		m.program.Send(errMsg(fmt.Errorf("cannot play this track - invalid track ID")))
 		return
 	}
+	m.fetchTrackExtras(ctx, item)
 
 	client := youtube.Client{}
-	track, err := client.GetVideo(item.id) // GetVideo works for music tracks
+	track, err := client.GetVideoContext(ctx, item.id) // GetVideo works for music tracks
 	if err != nil {
-		m.program.Send(errMsg(err))
+		m.playbackFailed(ctx, err)
 		return
 	}
 
-	formats := track.Formats.Type("audio")
-	if len(formats) == 0 {
-		m.program.Send(errMsg(fmt.Errorf("no audio format found")))
+	format := playbackAudioFormat(track.Formats.Type("audio"))
+	if format == nil {
+		m.playbackFailed(ctx, fmt.Errorf("no audio format found"))
 		return
 	}
-	format := &formats[0]
 
-	streamURL, err := client.GetStreamURL(track, format)
+	input, stdin, err := ffmpegStreamInput(ctx, client, track, format)
 	if err != nil {
-		m.program.Send(errMsg(err))
+		m.playbackFailed(ctx, err)
 		return
 	}
 
 	// Use reconnect flags to handle network fluctuations
 	// Add user agent to prevent YouTube from throttling or closing the connection
-	cmd := exec.Command("ffmpeg",
-		"-user_agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-user_agent", streamUserAgent(),
 		"-reconnect", "1",
 		"-reconnect_at_eof", "1",
 		"-reconnect_streamed", "1",
 		"-reconnect_delay_max", "5",
 		"-probesize", "5000000",
 		"-analyzeduration", "5000000",
-		"-i", streamURL,
-		"-loglevel", "error",
-		"-vn", "-c:a", "libmp3lame",
-		"-ar", "44100",
+		"-i", input,
+		"-loglevel", "warning", // Warnings include reconnect attempts for the health indicator
+		"-vn", "-c:a", "libmp3lame", "-q:a", handoffMP3Quality,
+		"-ar", rateArg(),
 		"-ac", "2",
 		"-f", "mp3",
 		"pipe:1",
 	)
+	if stdin != nil {
+		cmd.Stdin = stdin
+		defer stdin.Close()
+	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		m.program.Send(errMsg(err))
+		m.playbackFailed(ctx, err)
+		return
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		m.playbackFailed(ctx, err)
 		return
 	}
 
 	if err := cmd.Start(); err != nil {
-		m.program.Send(errMsg(err))
+		m.playbackFailed(ctx, err)
 		return
 	}
 
-	// Store cmd so we can kill it
-	m.playback.cmd = cmd
+	// Read ahead of the decoder and watch ffmpeg for network trouble
+	health := &streamHealth{buffer: newStreamBuffer(stdout)}
+	go health.watchFFmpegLog(stderr)
 
-	streamer, _, err := mp3.Decode(io.NopCloser(stdout))
+	streamer, _, err := mp3.Decode(io.NopCloser(health.buffer))
 	if err != nil {
-		m.program.Send(errMsg(err))
+		m.playbackFailed(ctx, err)
 		return
 	}
 	defer streamer.Close()
 
-	ctrl := &beep.Ctrl{Streamer: streamer, Paused: false}
-	m.playback.player = ctrl
-	m.playback.playingSong = track.Title
-	m.playback.isPaused = false
-	m.playback.lyrics = nil
-	m.playback.currentLyricIndex = -1
-	m.playback.albumCover = ""
-	m.playback.coverPath = ""
-	m.playback.kittyImage = ""
-	m.playback.resizedCoverPath = ""
-
-	m.program.Send(playMsg{title: track.Title, author: track.Author})
-
-	// Use WaitGroup to fetch image and lyrics concurrently
-	var wg sync.WaitGroup
-	
-	// Fetch album cover in background
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if item.thumb != "" {
-			coverPath := fmt.Sprintf("temp_cover_%s.jpg", item.id)
-			err := m.downloadAndCacheThumb(item.thumb, coverPath)
-			if err == nil {
-				// Always generate ASCII art for stable display
-				asciiArt := convertImageToASCII(coverPath, 40, 20) // Large colorized ASCII art
-				if asciiArt != "" {
-					m.playback.albumCover = asciiArt
-					m.playback.coverPath = coverPath
-				}
-				
-				// Also try terminal image display if supported
-				if isImageCapableTerminal() {
-					// Resize image for better display (200x200 pixels max)
-					resizedPath := fmt.Sprintf("temp_cover_resized_%s.jpg", item.id)
-					err := resizeImage(coverPath, resizedPath, 200, 200)
-					if err == nil {
-						// Store paths and notify TUI that image is ready
-						m.playback.resizedCoverPath = resizedPath
-						m.playback.kittyImage = "ready" // Signal that image is ready
-						m.program.Send(imageReadyMsg{imagePath: resizedPath})
-					}
-				}
-			}
-		}
-	}()
-
-	// Fetch lyrics in background
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		durSeconds := int(track.Duration.Seconds())
-		lyrics, err := fetchLyrics(track.Title, track.Author, durSeconds)
-		if err != nil || len(lyrics) == 0 {
-			m.program.Send(noLyricsMsg{})
-		} else {
-			m.program.Send(lyricsFetchedMsg(lyrics))
+	if ctx.Err() != nil {
+		return // Stopped while the stream was starting
+	}
+	// The waveform comes from the audio as it plays, unless cached already
+	recorder := newWaveformRecorder(streamer, deviceRate, track.Duration)
+	recording, stopRecording := context.WithCancel(ctx)
+	defer stopRecording()
+	if path, err := waveformPath(item.id); err == nil {
+		if _, err := os.Stat(path); err != nil {
+			go recorder.report(recording, m.program, item.id)
 		}
-	}()
-
-	// Don't wait for image/lyrics to complete - let them load in background
+	}
+	ctrl := &beep.Ctrl{Streamer: recorder, Paused: false}
+	volume := volumeStage(ctrl, currentGain())
+	builtin.set(ctrl, volume, func() { cmd.Process.Kill() }, health)
+
+	m.program.Send(streamStartedMsg{
+		key:       item.id,
+		health:    health,
+		audioInfo: formatAudioInfo(format).String(),
+	})
+	m.program.Send(playMsg{title: track.Title, author: track.Author})
 
-	done := make(chan bool)
-	speaker.Play(beep.Seq(ctrl, beep.Callback(func() {
+	done := make(chan bool, 1)
+	audioOut.Play(beep.Seq(channelMixer{volume}, beep.Callback(func() {
 		done <- true
 	})))
 
@@ -161,84 +212,145 @@ func (m *model) runInternalPlayback(item songItem) {
 		cmd.Wait()
 	}()
 
-	<-done
-	m.program.Send(stopMsg{})
+	select {
+	case <-done:
+		m.program.Send(stopMsg{})
+	case <-ctx.Done():
+	}
 }
 
-func (m *model) togglePause() {
-	if ctrl, ok := m.playback.player.(*beep.Ctrl); ok && ctrl != nil {
+// previewBuiltin plays length of item starting at from, so cut points can be
+// checked before downloading
+func (m *model) previewBuiltin(ctx context.Context, item songItem, from, length time.Duration) {
+	client := youtube.Client{}
+	track, err := client.GetVideoContext(ctx, item.id)
+	if err != nil {
+		m.program.Send(previewDoneMsg{err: err})
+		return
+	}
+	format := playbackAudioFormat(track.Formats.Type("audio"))
+	if format == nil {
+		m.program.Send(previewDoneMsg{err: fmt.Errorf("no audio format found")})
+		return
+	}
+	input, stdin, err := ffmpegStreamInput(ctx, client, track, format)
+	if err != nil {
+		m.program.Send(previewDoneMsg{err: err})
+		return
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-ss", ffmpegTime(from),
+		"-t", ffmpegTime(length),
+		"-i", input,
+		"-loglevel", "error",
+		"-vn", "-c:a", "libmp3lame", "-q:a", handoffMP3Quality,
+		"-ar", rateArg(),
+		"-ac", "2",
+		"-f", "mp3",
+		"pipe:1",
+	)
+	if stdin != nil {
+		cmd.Stdin = stdin
+		defer stdin.Close()
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		m.program.Send(previewDoneMsg{err: err})
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		m.program.Send(previewDoneMsg{err: err})
+		return
+	}
+	go cmd.Wait()
+
+	health := &streamHealth{buffer: newStreamBuffer(stdout)}
+
+	streamer, _, err := mp3.Decode(io.NopCloser(health.buffer))
+	if err != nil {
+		m.program.Send(previewDoneMsg{err: err})
+		return
+	}
+	defer streamer.Close()
+
+	if ctx.Err() != nil {
+		return // Stopped while the stream was starting
+	}
+	ctrl := &beep.Ctrl{Streamer: streamer, Paused: false}
+	volume := volumeStage(ctrl, currentGain())
+	builtin.set(ctrl, volume, func() { cmd.Process.Kill() }, health)
+
+	done := make(chan bool, 1)
+	audioOut.Play(beep.Seq(channelMixer{volume}, beep.Callback(func() {
+		done <- true
+	})))
+	select {
+	case <-done:
+		m.program.Send(previewDoneMsg{})
+	case <-ctx.Done():
+	}
+}
+
+func (m *model) togglePauseBuiltin() {
+	if ctrl := builtin.current(); ctrl != nil {
 		m.playback.isPaused = !m.playback.isPaused
+		audioOut.Lock()
 		ctrl.Paused = m.playback.isPaused
+		audioOut.Unlock()
+	}
+}
+
+// setVolumeBuiltin sets the gain of the playing stream
+func (m *model) setVolumeBuiltin(g float64) {
+	if volume := builtin.currentVolume(); volume != nil {
+		audioOut.Lock()
+		setVolumeStage(volume, g)
+		audioOut.Unlock()
 	}
 }
 
-func (m *model) stopPlayback() {
-	// 1. Kill the ffmpeg process first
-	if cmd, ok := m.playback.cmd.(*exec.Cmd); ok && cmd != nil && cmd.Process != nil {
-		cmd.Process.Kill()
-		m.playback.cmd = nil
+func (m *model) stopBuiltin() {
+	ctrl, stop, health := builtin.take()
+
+	// 1. Kill the ffmpeg process or close the local queue first
+	if stop != nil {
+		stop()
 	}
 
 	// 2. Stop the audio engine
-	if ctrl, ok := m.playback.player.(*beep.Ctrl); ok && ctrl != nil {
+	if health != nil {
+		health.buffer.Close()
+	}
+	if ctrl != nil {
+		audioOut.Lock()
 		ctrl.Paused = true
-		m.playback.player = nil
-	}
-	
-	// 3. Clear images from terminal
-	clearKittyImages()
-	
-	// 4. Clean up cover files
-	if m.playback.coverPath != "" {
-		os.Remove(m.playback.coverPath)
-		m.playback.coverPath = ""
-	}
-	if m.playback.resizedCoverPath != "" {
-		os.Remove(m.playback.resizedCoverPath)
-		m.playback.resizedCoverPath = ""
-	}
-	
-	m.playback.playingSong = ""
-	m.playback.albumCover = ""
-	m.playback.kittyImage = ""
+		audioOut.Unlock()
+	}
 }
 
-func (m *model) seekForward() {
-	if ctrl, ok := m.playback.player.(*beep.Ctrl); ok && ctrl != nil {
+// seekBuiltin moves playback by offset, clamped to the track
+func (m *model) seekBuiltin(offset time.Duration) {
+	if ctrl := builtin.current(); ctrl != nil {
 		if seeker, ok := ctrl.Streamer.(beep.StreamSeeker); ok {
-			speaker.Lock()
-			newPos := seeker.Position() + 5*44100
+			audioOut.Lock()
+			newPos := seeker.Position() + deviceRate.N(offset)
 			if newPos >= seeker.Len() {
 				newPos = seeker.Len() - 1
 			}
-			seeker.Seek(newPos)
-			speaker.Unlock()
-		}
-	}
-}
-
-func (m *model) seekBackward() {
-	if ctrl, ok := m.playback.player.(*beep.Ctrl); ok && ctrl != nil {
-		if seeker, ok := ctrl.Streamer.(beep.StreamSeeker); ok {
-			speaker.Lock()
-			newPos := seeker.Position() - 5*44100
 			if newPos < 0 {
 				newPos = 0
 			}
 			seeker.Seek(newPos)
-			speaker.Unlock()
+			audioOut.Unlock()
 		}
 	}
 }
 
 // Get current playback position for lyrics synchronization
-func (m *model) getCurrentPlaybackPosition() (time.Duration, bool) {
-	if m.playback.player == nil {
-		return 0, false
-	}
-
-	ctrl, ok := m.playback.player.(*beep.Ctrl)
-	if !ok || ctrl == nil {
+func (m *model) positionBuiltin() (time.Duration, bool) {
+	ctrl := builtin.current()
+	if ctrl == nil {
 		return 0, false
 	}
 
@@ -248,10 +360,10 @@ func (m *model) getCurrentPlaybackPosition() (time.Duration, bool) {
 	}
 
 	// Use speaker lock to safely read position without interfering with playback
-	speaker.Lock()
+	audioOut.Lock()
 	pos := seeker.Position()
-	speaker.Unlock()
+	audioOut.Unlock()
 
-	currentTime := time.Duration(float64(pos) / 44100.0 * float64(time.Second))
+	currentTime := deviceRate.D(pos)
 	return currentTime, true
 }
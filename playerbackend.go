@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// seekStep is how far the arrow keys move playback
+const seekStep = 5 * time.Second
+
+// Player is a playback backend. Methods get the model whose playback state
+// they drive; Play and Preview block until the audio ends, is stopped or ctx
+// is cancelled.
+type Player interface {
+	Play(ctx context.Context, m *model, item songItem)
+	Preview(ctx context.Context, m *model, item songItem, from, length time.Duration)
+	TogglePause(m *model)
+	Stop(m *model)
+	Seek(m *model, offset time.Duration)
+	SetVolume(m *model, gain float64)
+	Position(m *model) (time.Duration, bool)
+}
+
+// player is the backend picked by playback.backend at startup
+var player Player = builtinPlayer{}
+
+// newPlayer returns the backend named in the config
+func newPlayer(c playbackConfig) Player {
+	if c.Backend == "mpv" {
+		return newMPVPlayer(c.MPVPath)
+	}
+	return builtinPlayer{}
+}
+
+// playbackConfig selects the playback backend and tunes how playback sounds and shows
+type playbackConfig struct {
+	Backend       string  `toml:"backend,omitempty"`        // "builtin" (ffmpeg + beep, default) or "mpv"
+	MPVPath       string  `toml:"mpv_path,omitempty"`       // mpv executable, found on PATH by default
+	Mono          bool    `toml:"mono,omitempty"`           // Downmix both channels to each ear
+	Balance       float64 `toml:"balance,omitzero"`         // -1 (left only) to 1 (right only), 0 centred
+	Pulse         bool    `toml:"pulse,omitempty"`          // Dim the album art and lyric highlight with the music's loudness
+	TerminalTitle bool    `toml:"terminal_title,omitempty"` // Show "♪ Artist – Title" as the terminal title while playing
+	CoverTheme    bool    `toml:"cover_theme,omitempty"`    // Tint the playing screen with colors from the album cover
+	StreamQuality string  `toml:"stream_quality,omitempty"` // Which YouTube stream to play: "high" (default), "medium" or "low"
+	StreamCodec   string  `toml:"stream_codec,omitempty"`   // Play "opus" or "aac" streams when YouTube has them, any by default
+	StreamBitrate int     `toml:"stream_bitrate,omitzero"`  // Play the stream nearest this many kbit/s, overriding stream_quality
+
+	SampleRate int           `toml:"sample_rate,omitzero"` // Output rate in Hz, 44100 by default; match the sound card to skip its resampling
+	Buffer     time.Duration `toml:"buffer,omitzero"`      // Sound card buffer, 100ms by default; raise it if playback stutters
+}
+
+// Output defaults when playback.sample_rate and playback.buffer are left out
+const (
+	defaultSampleRate = 44100
+	defaultBuffer     = 100 * time.Millisecond
+)
+
+// rate is the sample rate playback runs at
+func (c playbackConfig) rate() int {
+	if c.SampleRate == 0 {
+		return defaultSampleRate
+	}
+	return c.SampleRate
+}
+
+// bufferLength is how much audio the output buffers
+func (c playbackConfig) bufferLength() time.Duration {
+	if c.Buffer == 0 {
+		return defaultBuffer
+	}
+	return c.Buffer
+}
+
+func (c playbackConfig) validate() error {
+	if c.Balance < -1 || c.Balance > 1 {
+		return fmt.Errorf("playback: balance must be between -1 and 1, got %g", c.Balance)
+	}
+	if c.SampleRate != 0 && (c.SampleRate < 8000 || c.SampleRate > 192000) {
+		return fmt.Errorf("playback: sample_rate must be between 8000 and 192000 Hz, got %d", c.SampleRate)
+	}
+	if c.Buffer != 0 && (c.Buffer < 10*time.Millisecond || c.Buffer > 2*time.Second) {
+		return fmt.Errorf("playback: buffer must be between 10ms and 2s, got %s", c.Buffer)
+	}
+	if err := validStreamQuality("playback", c.StreamQuality); err != nil {
+		return err
+	}
+	if c.StreamCodec != "" && !slices.Contains(streamCodecs, c.StreamCodec) {
+		return fmt.Errorf("playback: stream_codec must be %s, got %q", strings.Join(streamCodecs, " or "), c.StreamCodec)
+	}
+	if c.StreamBitrate != 0 && (c.StreamBitrate < 16 || c.StreamBitrate > 512) {
+		return fmt.Errorf("playback: stream_bitrate must be between 16 and 512 kbit/s, got %d", c.StreamBitrate)
+	}
+	switch c.Backend {
+	case "", "builtin", "mpv":
+		return nil
+	}
+	return fmt.Errorf("playback: backend must be builtin or mpv, got %q", c.Backend)
+}
+
+// builtinPlayer decodes through an ffmpeg pipe and plays with beep
+type builtinPlayer struct{}
+
+func (builtinPlayer) Play(ctx context.Context, m *model, item songItem) {
+	m.runInternalPlayback(ctx, item)
+}
+func (builtinPlayer) Preview(ctx context.Context, m *model, item songItem, from, length time.Duration) {
+	m.previewBuiltin(ctx, item, from, length)
+}
+func (builtinPlayer) TogglePause(m *model)                    { m.togglePauseBuiltin() }
+func (builtinPlayer) Stop(m *model)                           { m.stopBuiltin() }
+func (builtinPlayer) Seek(m *model, offset time.Duration)     { m.seekBuiltin(offset) }
+func (builtinPlayer) SetVolume(m *model, gain float64)        { m.setVolumeBuiltin(gain) }
+func (builtinPlayer) Position(m *model) (time.Duration, bool) { return m.positionBuiltin() }
+
+// playbackFailed reports err unless the track was called off, since every
+// step after a cancellation fails as well
+func (m *model) playbackFailed(ctx context.Context, err error) {
+	if ctx.Err() == nil {
+		m.program.Send(errMsg(err))
+	}
+}
+
+func (m *model) previewSegment(ctx context.Context, item songItem, from, length time.Duration) {
+	player.Preview(ctx, m, item, from, length)
+}
+
+func (m *model) togglePause() {
+	player.TogglePause(m)
+}
+
+func (m *model) seekForward() {
+	player.Seek(m, seekStep)
+}
+
+func (m *model) seekBackward() {
+	player.Seek(m, -seekStep)
+}
+
+func (m *model) getCurrentPlaybackPosition() (time.Duration, bool) {
+	return player.Position(m)
+}
+
+func (m *model) stopPlayback() {
+	playbackJob.stop()
+	player.Stop(m)
+
+	// Clear images from terminal
+	clearKittyImages()
+
+	// Forget the cover; the files stay in the art cache for the next play
+	m.playback.coverPath = ""
+	m.playback.resizedCoverPath = ""
+
+	m.playback.playingSong = ""
+	m.playback.albumCover = ""
+	m.playback.kittyImage = ""
+	m.playback.audioInfo = ""
+	m.playback.exclusive = false
+	m.playback.health = nil
+	m.playback.position = 0
+	m.playback.trackKey = ""
+	m.playback.waveform = nil
+	m.playback.pulse = 0
+}
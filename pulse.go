@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	pulseFloor   = 0.45 // Brightness during silence
+	pulseLoudRMS = 0.3  // RMS that counts as full brightness
+)
+
+// outputLevel is the RMS of the last buffer the built-in player mixed, as float64 bits
+var outputLevel atomic.Uint64
+
+// recordLevel stores the RMS of samples for the pulse effect
+func recordLevel(samples [][2]float64) {
+	if len(samples) == 0 {
+		return
+	}
+	sum := 0.0
+	for _, s := range samples {
+		sum += s[0]*s[0] + s[1]*s[1]
+	}
+	outputLevel.Store(math.Float64bits(math.Sqrt(sum / float64(2*len(samples)))))
+}
+
+// updatePulse moves the art and lyric brightness towards the current loudness.
+// Backends that don't expose samples fall back to the track's waveform.
+func (m *model) updatePulse() {
+	if !cfg.Playback.Pulse {
+		return
+	}
+	var rms float64
+	if _, ok := player.(builtinPlayer); ok {
+		rms = math.Float64frombits(outputLevel.Load())
+	} else if w := m.playback.waveform; w != nil && w.Duration > 0 && !m.playback.isPaused {
+		i := int(float64(len(w.Peaks)) * float64(m.playback.position) / float64(w.Duration))
+		rms = w.Peaks[max(0, min(i, len(w.Peaks)-1))] * pulseLoudRMS
+	}
+	target := pulseFloor + (1-pulseFloor)*math.Min(1, rms/pulseLoudRMS)
+	// Rise quickly, fall slowly, so beats flash rather than flicker
+	if target > m.playback.pulse {
+		m.playback.pulse = target
+	} else {
+		m.playback.pulse += (target - m.playback.pulse) * 0.3
+	}
+}
+
+// pulseBrightness is the factor art and lyric colors are scaled by
+func (m *model) pulseBrightness() float64 {
+	if !cfg.Playback.Pulse || m.playback.pulse == 0 {
+		return 1
+	}
+	return m.playback.pulse
+}
+
+var ansiTrueColor = regexp.MustCompile(`\x1b\[38;2;(\d+);(\d+);(\d+)m`)
+
+// dimANSI scales the truecolor foregrounds in s, as used by the ASCII album art
+func dimANSI(s string, f float64) string {
+	if f >= 1 {
+		return s
+	}
+	return ansiTrueColor.ReplaceAllStringFunc(s, func(code string) string {
+		rgb := ansiTrueColor.FindStringSubmatch(code)
+		var c [3]int
+		for i := range c {
+			v, _ := strconv.Atoi(rgb[i+1])
+			c[i] = int(float64(v) * f)
+		}
+		return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", c[0], c[1], c[2])
+	})
+}
+
+// lyricHighlightColor is the current lyric's color, dimmed with the pulse
+func (m *model) lyricHighlightColor() lipgloss.Color {
+	c := defaultHighlight
+	if m.playback.theme != nil {
+		c = m.playback.theme.highlight
+	}
+	return c.scale(m.pulseBrightness()).hex()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// playQueue is the ordered list of tracks lined up for playback
+type playQueue struct {
+	tracks []songItem
+	index  int
+}
+
+// set replaces the queue contents and positions it at index
+func (q *playQueue) set(tracks []songItem, index int) {
+	q.tracks = tracks
+	q.index = index
+}
+
+// add appends tracks to the end of the queue
+func (q *playQueue) add(tracks []songItem) {
+	q.tracks = append(q.tracks, tracks...)
+}
+
+func (q playQueue) len() int {
+	return len(q.tracks)
+}
+
+// upNext returns the track that will play after the current one
+func (q playQueue) upNext() (songItem, bool) {
+	if q.index+1 >= len(q.tracks) {
+		return songItem{}, false
+	}
+	return q.tracks[q.index+1], true
+}
+
+// advance moves to the next track and returns it
+func (q *playQueue) advance() (songItem, bool) {
+	next, ok := q.upNext()
+	if ok {
+		q.index++
+	}
+	return next, ok
+}
+
+// startPlayback stops the current stream and starts loading item
+func (m *model) startPlayback(item songItem) tea.Cmd {
+	if !m.requireAudio() {
+		return nil
+	}
+	m.stopPlayback() // Cleanup any existing playback first
+	m.selected = item
+	m.state = stateLoading
+	m.playback.resetTrack()
+	m.playback.trackKey = waveformKey(item)
+	ctx, session := newPlaybackSession(playbackJob.start())
+	m.playback.session = session
+	m.playback.quietCap = m.startVolume()
+	go player.Play(ctx, m, item)
+	return tea.Batch(m.spinner.Tick, loadWaveform(ctx, item), m.infoCmd())
+}
+
+// queueResults lines up every track the results list shows, leaving albums
+// out. While a track plays they join the end of the queue; otherwise
+// playback starts with the first of them.
+func (m *model) queueResults() tea.Cmd {
+	var tracks []songItem
+	for _, li := range m.list.VisibleItems() {
+		if item, ok := li.(songItem); ok && !item.isAlbum && len(item.id) >= 10 {
+			tracks = append(tracks, item)
+		}
+	}
+	if len(tracks) == 0 {
+		m.notice = "No playable tracks in the results"
+		return nil
+	}
+	if m.playing() {
+		m.queue.add(tracks)
+		m.notice = fmt.Sprintf("Queued %d tracks", len(tracks))
+		return nil
+	}
+	m.queue.set(tracks, 0)
+	return m.startPlayback(tracks[0])
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const queueFileVersion = 1
+
+// queueFile is an exported play queue. Position is the index of the track
+// that was playing, so an import picks up where the export left off.
+type queueFile struct {
+	Version  int          `json:"version"`
+	Exported time.Time    `json:"exported"`
+	Position int          `json:"position"`
+	Tracks   []savedTrack `json:"tracks"`
+}
+
+// queueImportedMsg carries a queue read from a file, ready to play
+type queueImportedMsg struct {
+	tracks []songItem
+	index  int
+	err    error
+}
+
+// exportQueue writes the queue to a timestamped JSON file in the working
+// directory, next to downloads, and returns its path
+func (m *model) exportQueue() (string, error) {
+	if m.queue.len() == 0 {
+		return "", fmt.Errorf("the queue is empty")
+	}
+	name := "gomusic-queue-" + time.Now().Format("20060102-150405") + ".json"
+	if err := writeQueueFile(name, m.queue.tracks, m.queue.index); err != nil {
+		return "", err
+	}
+	if abs, err := filepath.Abs(name); err == nil {
+		name = abs
+	}
+	return name, nil
+}
+
+// writeQueueFile saves tracks as a queue file positioned at index
+func writeQueueFile(path string, tracks []songItem, index int) error {
+	f := queueFile{
+		Version:  queueFileVersion,
+		Exported: time.Now(),
+		Position: index,
+	}
+	for _, t := range tracks {
+		f.Tracks = append(f.Tracks, newSavedTrack(t))
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// importQueue reads a queue file written by exportQueue
+func importQueue(path string) tea.Cmd {
+	return func() tea.Msg {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return queueImportedMsg{err: err}
+		}
+		var f queueFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return queueImportedMsg{err: fmt.Errorf("%s is not a queue file: %w", path, err)}
+		}
+		if f.Version > queueFileVersion {
+			return queueImportedMsg{err: fmt.Errorf("%s was exported by a newer gomusic", path)}
+		}
+
+		var tracks []songItem
+		index := 0
+		for i, t := range f.Tracks {
+			item := t.item()
+			// Local files only make sense on the machine that exported them
+			if item.path != "" {
+				if _, err := os.Stat(item.path); err != nil {
+					continue
+				}
+			} else if len(item.id) < 10 {
+				continue
+			}
+			if i <= f.Position {
+				index = len(tracks)
+			}
+			tracks = append(tracks, item)
+		}
+		if len(tracks) == 0 {
+			return queueImportedMsg{err: fmt.Errorf("%s has no playable tracks", path)}
+		}
+		return queueImportedMsg{tracks: tracks, index: index}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/raitonoberu/ytmusic"
+)
+
+// maxRelatedAlbums caps each related tab
+const maxRelatedAlbums = 20
+
+// Tabs of the related albums view, after the album's own track list
+const (
+	relatedByArtist = iota
+	relatedSimilar
+	relatedTabCount
+)
+
+var relatedTabNames = [relatedTabCount]string{
+	relatedByArtist: "More by this artist",
+	relatedSimilar:  "Related albums",
+}
+
+// fetchRelatedAlbums looks up other albums of the artist via album search and
+// albums of other artists via the radio playlist of seedTrack
+func fetchRelatedAlbums(album songItem, seedTrack string) tea.Cmd {
+	return func() tea.Msg {
+		msg := relatedAlbumsMsg{albumID: album.id}
+		artist := strings.ToLower(primaryArtist(album.author))
+
+		result, err := ytmusic.AlbumSearch(primaryArtist(album.author)).Next()
+		if err != nil {
+			msg.err = err
+			return msg
+		}
+		for _, a := range result.Albums {
+			if a.BrowseID == album.id || !sameArtist(album, a.Artists, artist) {
+				continue
+			}
+			msg.byArtist = append(msg.byArtist, convertYTMusicAlbum(a))
+			if len(msg.byArtist) == maxRelatedAlbums {
+				break
+			}
+		}
+
+		if seedTrack == "" {
+			return msg
+		}
+		radio, err := ytmusic.GetWatchPlaylist(seedTrack)
+		if err != nil {
+			// The artist tab is still useful on its own
+			return msg
+		}
+		seen := map[string]bool{}
+		for _, t := range radio {
+			if t.Album.ID == "" || t.Album.ID == album.id || seen[t.Album.ID] || sameArtist(album, t.Artists, artist) {
+				continue
+			}
+			seen[t.Album.ID] = true
+			msg.related = append(msg.related, songItem{
+				id:        t.Album.ID,
+				title:     t.Album.Name,
+				author:    strings.Join(getArtistNames(t.Artists), ", "),
+				thumb:     getBestThumbnail(t.Thumbnails),
+				isAlbum:   true,
+				artistIDs: getArtistIDs(t.Artists),
+			})
+			if len(msg.related) == maxRelatedAlbums {
+				break
+			}
+		}
+		return msg
+	}
+}
+
+// primaryArtist returns the first of a comma separated artist list
+func primaryArtist(author string) string {
+	return strings.TrimSpace(strings.SplitN(author, ",", 2)[0])
+}
+
+// openRelatedAlbums switches to the related tabs, fetching them on first use for this album
+func (m *model) openRelatedAlbums() tea.Cmd {
+	m.state = stateRelatedAlbums
+	m.relatedTab = relatedByArtist
+	if m.relatedFor == m.currentAlbum.id {
+		return nil
+	}
+	m.relatedFor = m.currentAlbum.id
+	m.relatedErr = nil
+	m.relatedLoading = true
+	m.relatedLists = [relatedTabCount]list.Model{}
+
+	seed := ""
+	if len(m.albumTracks) > 0 {
+		seed = m.albumTracks[0].id
+	}
+	return tea.Batch(m.spinner.Tick, fetchRelatedAlbums(m.currentAlbum, seed))
+}
+
+// setRelatedAlbums fills the tabs from a finished lookup
+func (m *model) setRelatedAlbums(msg relatedAlbumsMsg) {
+	if msg.albumID != m.relatedFor {
+		return // The user moved on to another album meanwhile
+	}
+	m.relatedLoading = false
+	m.relatedErr = msg.err
+	for tab, albums := range [relatedTabCount][]songItem{msg.byArtist, msg.related} {
+		var items []list.Item
+		for _, a := range albums {
+			items = append(items, a)
+		}
+		l := list.New(items, list.NewDefaultDelegate(), m.width-4, m.height-10)
+		l.Title = relatedTabNames[tab]
+		m.relatedLists[tab] = l
+	}
+}
+
+func (m model) updateRelatedAlbums(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	current := &m.relatedLists[m.relatedTab]
+	if current.FilterState() != list.Filtering {
+		switch msg.String() {
+		case "tab":
+			if m.relatedTab+1 == relatedTabCount {
+				m.state = stateViewingAlbumTracks
+			} else {
+				m.relatedTab++
+			}
+			return m, nil
+		case "shift+tab":
+			if m.relatedTab == 0 {
+				m.state = stateViewingAlbumTracks
+			} else {
+				m.relatedTab--
+			}
+			return m, nil
+		case "esc", "q":
+			m.state = stateViewingAlbumTracks
+			return m, nil
+		case "enter":
+			if item, ok := current.SelectedItem().(songItem); ok && !m.relatedLoading {
+				// Browse the picked album the same way as from search results
+				m.selected = item
+				m.currentAlbum = item
+				m.state = stateSearching
+				return m, tea.Batch(m.spinner.Tick, searchAlbumWithTracks(searchJob.start(), item))
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	*current, cmd = current.Update(msg)
+	return m, cmd
+}
+
+// renderAlbumTabs shows the tab bar shared by the album tracks and related views
+func (m model) renderAlbumTabs() string {
+	names := append([]string{"Tracks"}, relatedTabNames[:]...)
+	active := 0
+	if m.state == stateRelatedAlbums {
+		active = m.relatedTab + 1
+	}
+	var tabs []string
+	for i, name := range names {
+		if i == active {
+			tabs = append(tabs, titleStyle.Render(name))
+		} else {
+			tabs = append(tabs, helpStyle.Render(name))
+		}
+	}
+	return strings.Join(tabs, helpStyle.Render("  │  "))
+}
+
+func (m model) viewRelatedAlbums() string {
+	var body string
+	current := m.relatedLists[m.relatedTab]
+	switch {
+	case m.relatedLoading:
+		body = fmt.Sprintf("\n  %s Finding albums...\n", m.spinner.View())
+	case m.relatedErr != nil:
+		body = "\n  " + errorStyle.Render(m.relatedErr.Error()) + "\n"
+	case len(current.Items()) == 0:
+		body = "\n  " + helpStyle.Render("Nothing found for this album.") + "\n"
+	default:
+		body = current.View()
+	}
+	return docStyle.Render(
+		lipgloss.JoinVertical(lipgloss.Left,
+			m.renderAlbumTabs(),
+			body,
+			helpStyle.Render("\n  ENTER: Browse Album  •  TAB: Next tab  •  ESC: Back to tracks"),
+		),
+	)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// searchConfig tunes which search results are listed
+type searchConfig struct {
+	Prefer       string        `toml:"prefer,omitempty"`        // "songs" (default) or "videos", listed first
+	LengthFilter bool          `toml:"length_filter,omitempty"` // Start with the length filter on
+	MinLength    time.Duration `toml:"min_length"`              // Shortest track the length filter keeps
+	MaxLength    time.Duration `toml:"max_length"`              // Longest track the length filter keeps, 0 for no limit
+}
+
+func (c searchConfig) validate() error {
+	switch c.Prefer {
+	case "", "songs", "videos":
+	default:
+		return fmt.Errorf("search: prefer must be songs or videos, got %q", c.Prefer)
+	}
+	if c.MinLength < 0 || c.MaxLength < 0 {
+		return fmt.Errorf("search: lengths can't be negative")
+	}
+	if c.MaxLength != 0 && c.MaxLength < c.MinLength {
+		return fmt.Errorf("search: max_length must be longer than min_length")
+	}
+	return nil
+}
+
+// fits reports whether item passes the length filter.
+// Albums and tracks of unknown length always do.
+func (c searchConfig) fits(item songItem) bool {
+	if item.isAlbum || item.duration == 0 {
+		return true
+	}
+	length := time.Duration(item.duration) * time.Second
+	return length >= c.MinLength && (c.MaxLength == 0 || length <= c.MaxLength)
+}
+
+// filterLength drops the tracks outside the configured length range, such as
+// shorts and teasers or full album uploads
+func filterLength(items []songItem) []songItem {
+	var kept []songItem
+	for _, item := range items {
+		if cfg.Search.fits(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// lengthFilterLabel describes the length filter for the search screen
+func lengthFilterLabel(on bool) string {
+	if !on {
+		return "Any length"
+	}
+	if cfg.Search.MaxLength == 0 {
+		return "From " + formatDuration(cfg.Search.MinLength)
+	}
+	return formatDuration(cfg.Search.MinLength) + "–" + formatDuration(cfg.Search.MaxLength)
+}
+
+// groupResults lists YouTube Music song entries and video uploads in groups,
+// the preferred kind first, followed by albums and playlists. Song entries
+// come with clean audio and album metadata, so they lead by default.
+func groupResults(items []songItem) []songItem {
+	var songs, videos, albums []songItem
+	for _, item := range items {
+		switch {
+		case item.isAlbum:
+			albums = append(albums, item)
+		case item.video:
+			videos = append(videos, item)
+		default:
+			songs = append(songs, item)
+		}
+	}
+	first, second := songs, videos
+	if cfg.Search.Prefer == "videos" {
+		first, second = videos, songs
+	}
+	grouped := append(first, second...)
+	return append(grouped, albums...)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// maxCachedSearches is how many recent result lists are kept for going back
+const maxCachedSearches = 10
+
+// searchKey identifies a search by what was typed and the filters it ran with
+type searchKey struct {
+	query        string
+	filter       searchFilter
+	lengthFilter bool
+}
+
+func newSearchKey(query string, filter searchFilter, lengthFilter bool) searchKey {
+	return searchKey{strings.ToLower(strings.Join(strings.Fields(query), " ")), filter, lengthFilter}
+}
+
+// cachedSearch is a result list as it was left, scroll position and list
+// filter included
+type cachedSearch struct {
+	key  searchKey
+	list list.Model
+}
+
+// rememberSearch keeps the current result list as the most recent search
+func (m *model) rememberSearch() {
+	searches := []cachedSearch{{key: m.searchKey, list: m.list}}
+	for _, s := range m.searches {
+		if s.key != m.searchKey && len(searches) < maxCachedSearches {
+			searches = append(searches, s)
+		}
+	}
+	m.searches = searches
+}
+
+// restoreSearch shows the cached results of key where they were left,
+// reporting whether there were any
+func (m *model) restoreSearch(key searchKey) bool {
+	for _, s := range m.searches {
+		if s.key == key {
+			m.searchKey = key
+			m.list = s.list
+			m.sizeResults()
+			m.state = stateSelecting
+			m.rememberSearch()
+			return true
+		}
+	}
+	return false
+}
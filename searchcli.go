@@ -0,0 +1,103 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// searchResult is a search result as `gomusic search --json` prints it
+type searchResult struct {
+	ID        string   `json:"id"`
+	Type      string   `json:"type"` // "song", "video", "album", "single", "ep" or "playlist"
+	Title     string   `json:"title"`
+	Artists   []string `json:"artists"`
+	Album     string   `json:"album,omitempty"`
+	Year      string   `json:"year,omitempty"`
+	Duration  int      `json:"duration,omitempty"` // Seconds
+	Thumbnail string   `json:"thumbnail,omitempty"`
+	URL       string   `json:"url"`
+}
+
+func newSearchResult(item songItem) searchResult {
+	r := searchResult{
+		ID:        item.id,
+		Type:      "song",
+		Title:     item.title,
+		Artists:   []string{},
+		Album:     item.album,
+		Year:      item.year,
+		Duration:  item.duration,
+		Thumbnail: item.thumb,
+		URL:       "https://music.youtube.com/watch?v=" + item.id,
+	}
+	if item.author != "" {
+		r.Artists = strings.Split(item.author, ", ")
+	}
+	switch {
+	case item.isAlbum:
+		r.Type = strings.ToLower(cmp.Or(item.category, "playlist"))
+		r.Title = strings.TrimSuffix(item.title, " ("+item.year+")")
+		r.URL = "https://music.youtube.com/browse/" + item.id
+	case item.video:
+		r.Type = "video"
+	}
+	return r
+}
+
+// runSearch implements `gomusic search [--json] [--songs|--albums] QUERY`:
+// the results the search screen would list, as JSON for other tools or one
+// tab separated line each
+func runSearch(args []string) int {
+	asJSON := false
+	filter := filterAll
+	var words []string
+	for _, arg := range args {
+		switch arg {
+		case "--json":
+			asJSON = true
+		case "--songs":
+			filter = filterSongs
+		case "--albums":
+			filter = filterAlbums
+		default:
+			words = append(words, arg)
+		}
+	}
+	query := strings.TrimSpace(strings.Join(words, " "))
+	if query == "" {
+		fmt.Fprintln(os.Stderr, `usage: gomusic search [--json] [--songs|--albums] "query"`)
+		return 2
+	}
+
+	var items []songItem
+	switch msg := searchYTMusic(appCtx, query, filter, cfg.Search.LengthFilter)().(type) {
+	case searchResultsMsg:
+		items = msg.items
+	case errMsg:
+		fmt.Fprintln(os.Stderr, msg)
+		return 1
+	}
+
+	results := make([]searchResult, 0, len(items))
+	for _, item := range items {
+		if item.id != "" {
+			results = append(results, newSearchResult(item))
+		}
+	}
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	for _, r := range results {
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", r.ID, r.Type, strings.Join(r.Artists, ", "), r.Title, r.Album)
+	}
+	return 0
+}
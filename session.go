@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Every track that starts playing gets a new playback session ID. Its cover
+// and lyric lookups carry the ID and Update drops results for any other
+// session, so a slow lookup for a track that was stopped or skipped can't
+// land on the one playing now, even when the same track is played again.
+
+// trackExtrasDeadline is how long the cover and lyric lookups of a track may
+// take before they give up
+const trackExtrasDeadline = 20 * time.Second
+
+var lastPlaybackSession atomic.Uint64
+
+type playbackSessionKey struct{}
+
+// newPlaybackSession returns ctx tagged with a new playback session ID
+func newPlaybackSession(ctx context.Context) (context.Context, uint64) {
+	id := lastPlaybackSession.Add(1)
+	return context.WithValue(ctx, playbackSessionKey{}, id), id
+}
+
+// playbackSession returns the session ID ctx was tagged with, 0 if none
+func playbackSession(ctx context.Context) uint64 {
+	id, _ := ctx.Value(playbackSessionKey{}).(uint64)
+	return id
+}
+
+// fetchTrackExtras looks up the cover and lyrics of item side by side, while
+// the stream is still being set up. Local files use their embedded art and
+// .lrc sidecar.
+func (m *model) fetchTrackExtras(ctx context.Context, item songItem) {
+	session := playbackSession(ctx)
+	key := waveformKey(item)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, trackExtrasDeadline)
+		defer cancel()
+		var coverPath string
+		var err error
+		switch {
+		case item.path != "":
+			coverPath, err = cachedEmbeddedArt(key, item.path)
+		case item.thumb != "" && !lowBandwidth():
+			coverPath, err = m.cachedArt(ctx, item.id, item.thumb)
+		default:
+			return
+		}
+		if err == nil && ctx.Err() == nil {
+			m.program.Send(coverReadyMsg{session: session, key: key, coverPath: coverPath})
+		}
+	}()
+
+	go func() {
+		lookup, cancel := context.WithTimeout(ctx, trackExtrasDeadline)
+		defer cancel()
+		var lyrics []LyricLine
+		var unsynced bool
+		var err error
+		if item.path != "" {
+			// The sidecar, then the file's own lyrics, then LRCLIB by its tags
+			if cfg.Lyrics.uses("sidecar") {
+				lyrics = localLyrics(item.path)
+			}
+			if len(lyrics) == 0 && cfg.Lyrics.uses("embedded") {
+				lyrics, unsynced = embeddedLyrics(item.path)
+			}
+			if len(lyrics) == 0 && cfg.Lyrics.uses("lrclib") && !offlineMode {
+				lyrics, err = fetchLyrics(lookup, item.title, item.author, item.duration)
+			}
+		} else {
+			lyrics, err = lyricsCache.get(lookup, item.id, item.title, item.author, item.duration)
+		}
+		if ctx.Err() != nil {
+			return // Stopped; a lookup that merely timed out still reports no lyrics
+		}
+		if err != nil || len(lyrics) == 0 {
+			m.program.Send(noLyricsMsg{session: session})
+		} else {
+			m.program.Send(lyricsFetchedMsg{session: session, lines: lyrics, unsynced: unsynced})
+		}
+	}()
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// setting is one config value editable from the settings screen
+type setting struct {
+	key  string // Name in config.toml as section.key
+	help string
+	get  func(c *config) string
+	set  func(c *config, value string) error
+}
+
+// settings lists every config value in the order the screen shows them
+var settings = []setting{
+	stringSetting("network.interface", "Bind to the first address of this interface, e.g. eth0",
+		func(c *config) *string { return &c.Network.Interface }),
+	stringSetting("network.source_address", "Bind to this local IP address",
+		func(c *config) *string { return &c.Network.SourceAddress }),
+	intSetting("network.ip_version", "4 or 6 to force a protocol, 0 for either",
+		func(c *config) *int { return &c.Network.IPVersion }),
+	boolSetting("network.force_ipv4", "Shorthand for ip_version = 4",
+		func(c *config) *bool { return &c.Network.ForceIPv4 }),
+	durationSetting("network.timeout", "Limit for metadata, lyric and cover requests, e.g. 15s",
+		func(c *config) *time.Duration { return &c.Network.Timeout }),
+	durationSetting("network.connect_timeout", "Limit for connecting and for a reply to start, downloads included",
+		func(c *config) *time.Duration { return &c.Network.ConnectTimeout }),
+	intSetting("network.retries", "Extra attempts after a connection error or server error",
+		func(c *config) *int { return &c.Network.Retries }),
+	stringSetting("network.user_agent", "User agent for lyric, cover and stream requests, built-in if not set",
+		func(c *config) *string { return &c.Network.UserAgent }),
+	intSetting("network.max_idle_conns", "Idle connections kept open per host for reuse",
+		func(c *config) *int { return &c.Network.MaxIdleConns }),
+	boolSetting("network.low_bandwidth", "Play the smallest streams and skip covers and art; downloads keep their quality",
+		func(c *config) *bool { return &c.Network.LowBandwidth }),
+	durationSetting("pacing.delay", "Minimum gap between album tracks, e.g. 1.5s",
+		func(c *config) *time.Duration { return &c.Pacing.Delay }),
+	durationSetting("pacing.jitter", "Random extra gap between album tracks, up to this much",
+		func(c *config) *time.Duration { return &c.Pacing.Jitter }),
+	durationSetting("pacing.cooldown", "Pause when YouTube starts throttling; doubles if it persists",
+		func(c *config) *time.Duration { return &c.Pacing.Cooldown }),
+	listSetting("title_cleaning.languages", "Comma separated title cleaning languages, empty for English only",
+		func(c *config) *[]string { return &c.TitleCleaning.Languages }),
+	listSetting("title_cleaning.extra", "Comma separated extra phrases to strip from titles",
+		func(c *config) *[]string { return &c.TitleCleaning.Extra }),
+	stringSetting("playback.backend", "builtin (ffmpeg + beep) or mpv (applies on restart)",
+		func(c *config) *string { return &c.Playback.Backend }),
+	stringSetting("playback.mpv_path", "mpv executable for the mpv backend, mpv on PATH if not set",
+		func(c *config) *string { return &c.Playback.MPVPath }),
+	intSetting("playback.sample_rate", "Output rate in Hz, 44100 if 0; match the sound card, e.g. 48000 (applies on restart)",
+		func(c *config) *int { return &c.Playback.SampleRate }),
+	durationSetting("playback.buffer", "Sound card buffer, 100ms if 0; raise it if playback stutters (applies on restart)",
+		func(c *config) *time.Duration { return &c.Playback.Buffer }),
+	boolSetting("playback.mono", "Downmix both channels to each ear",
+		func(c *config) *bool { return &c.Playback.Mono }),
+	floatSetting("playback.balance", "-1 (left only) to 1 (right only), 0 centred",
+		func(c *config) *float64 { return &c.Playback.Balance }),
+	boolSetting("playback.pulse", "Dim the album art and lyric highlight with the music's loudness",
+		func(c *config) *bool { return &c.Playback.Pulse }),
+	boolSetting("playback.terminal_title", "Show \"♪ Artist – Title\" as the terminal title while playing",
+		func(c *config) *bool { return &c.Playback.TerminalTitle }),
+	boolSetting("playback.cover_theme", "Tint the playing screen with colors from the album cover",
+		func(c *config) *bool { return &c.Playback.CoverTheme }),
+	stringSetting("playback.stream_quality", "high, medium (about 128 kbps) or low: which YouTube stream to play; high if empty",
+		func(c *config) *string { return &c.Playback.StreamQuality }),
+	stringSetting("playback.stream_codec", "opus or aac: play streams of this codec when YouTube has them; any if empty",
+		func(c *config) *string { return &c.Playback.StreamCodec }),
+	intSetting("playback.stream_bitrate", "Play the stream nearest this many kbit/s, e.g. 64; 0 follows stream_quality",
+		func(c *config) *int { return &c.Playback.StreamBitrate }),
+	stringSetting("search.prefer", "songs or videos: which kind of track result is listed first",
+		func(c *config) *string { return &c.Search.Prefer }),
+	boolSetting("search.length_filter", "Start with the search length filter on (4 on the search screen toggles it)",
+		func(c *config) *bool { return &c.Search.LengthFilter }),
+	durationSetting("search.min_length", "Shortest track the length filter keeps, e.g. 1m",
+		func(c *config) *time.Duration { return &c.Search.MinLength }),
+	durationSetting("search.max_length", "Longest track the length filter keeps, 0 for no limit",
+		func(c *config) *time.Duration { return &c.Search.MaxLength }),
+	boolSetting("download.prompt", "Show the download options before every track download",
+		func(c *config) *bool { return &c.Download.Prompt }),
+	boolSetting("download.zip", "Also package finished album downloads as a zip with the cover and an M3U playlist",
+		func(c *config) *bool { return &c.Download.Zip }),
+	boolSetting("download.nfo", "Write album.nfo and folder.jpg into album downloads for Kodi and Jellyfin",
+		func(c *config) *bool { return &c.Download.NFO }),
+	stringSetting("download.artist_info_dir", "Artist information folder that gets an artist.nfo per artist, with download.nfo",
+		func(c *config) *string { return &c.Download.ArtistInfoDir }),
+	stringSetting("download.dir", "Folder downloads are saved in and the library is read from, the working directory if empty",
+		func(c *config) *string { return &c.Download.Dir }),
+	stringSetting("download.template", "Path of each download under download.dir, e.g. {artist}/{album}/{track:02d} - {title}.{ext}",
+		func(c *config) *string { return &c.Download.Template }),
+	stringSetting("download.format", "mp3, m4a, opus, ogg or flac, the format downloads are saved in unless the download options say otherwise",
+		func(c *config) *string { return &c.Download.Format }),
+	stringSetting("download.quality", "best, high, medium or a bitrate like 256k; high if empty",
+		func(c *config) *string { return &c.Download.Quality }),
+	boolSetting("download.passthrough", "Save YouTube's Opus or AAC audio as it is instead of re-encoding it (P on the album confirmation toggles it)",
+		func(c *config) *bool { return &c.Download.Passthrough }),
+	stringSetting("download.stream_quality", "high, medium (about 128 kbps) or low: which YouTube stream to download; high if empty",
+		func(c *config) *string { return &c.Download.StreamQuality }),
+	boolSetting("download.pick_stream", "List YouTube's audio streams with codec, bitrate and size to pick from before every track download",
+		func(c *config) *bool { return &c.Download.PickStream }),
+	stringSetting("transfer.target", "Device folder (mounted MTP or SD card) or rsync destination like host:Music, for T in the library",
+		func(c *config) *string { return &c.Transfer.Target }),
+	stringSetting("transfer.on_conflict", "skip, overwrite or rename (mounted targets only) when the device already has a file",
+		func(c *config) *string { return &c.Transfer.OnConflict }),
+	listSetting("watch.folders", "Comma-separated folders whose new audio files are moved into the library (applies on restart)",
+		func(c *config) *[]string { return &c.Watch.Folders }),
+	durationSetting("watch.interval", "Time between checks of the watch folders, 10s if 0 (applies on restart)",
+		func(c *config) *time.Duration { return &c.Watch.Interval }),
+	stringSetting("watch.template", "Library path for imported files, e.g. {album}/{track} - {title}; keeps the file name if empty",
+		func(c *config) *string { return &c.Watch.Template }),
+	boolSetting("watch.enrich", "Tag imported files missing an artist or title from an \"Artist - Title\" file name",
+		func(c *config) *bool { return &c.Watch.Enrich }),
+	stringSetting("info.genius_token", "Genius API client access token, to show Genius song descriptions on the I panel",
+		func(c *config) *string { return &c.Info.GeniusToken }),
+	stringSetting("info.language", "Wikipedia edition the I panel reads from, e.g. en or pt",
+		func(c *config) *string { return &c.Info.Language }),
+	stringSetting("quiet_hours.start", "Time of day (HH:MM) from which playback starts at the quiet hours volume",
+		func(c *config) *string { return &c.QuietHours.Start }),
+	stringSetting("quiet_hours.end", "Time of day (HH:MM) at which quiet hours end; they may run past midnight",
+		func(c *config) *string { return &c.QuietHours.End }),
+	intSetting("quiet_hours.volume", "Volume cap during quiet hours, in percent of full volume",
+		func(c *config) *int { return &c.QuietHours.Volume }),
+	stringSetting("theme.title", "Title background as #RRGGBB or an ANSI color number, #F456D3 if empty",
+		func(c *config) *string { return &c.Theme.Title }),
+	stringSetting("theme.status", "Status and selection text color, #04B575 if empty",
+		func(c *config) *string { return &c.Theme.Status }),
+	stringSetting("theme.error", "Error text color, #EF4444 if empty",
+		func(c *config) *string { return &c.Theme.Error }),
+	stringSetting("theme.help", "Help line color, #626262 if empty",
+		func(c *config) *string { return &c.Theme.Help }),
+	stringSetting("theme.highlight", "Current lyric and played progress color as #RRGGBB, #00FFFF if empty",
+		func(c *config) *string { return &c.Theme.Highlight }),
+	listSetting("lyrics.providers", "Comma-separated lyric sources: sidecar (.lrc next to local files), embedded (tags of local files) and lrclib; all if empty",
+		func(c *config) *[]string { return &c.Lyrics.Providers }),
+	listSetting("metadata.title", "Comma-separated sources of the title tag, first with a value wins: youtube, itunes, musicbrainz; youtube if empty",
+		func(c *config) *[]string { return &c.Metadata.Title }),
+	listSetting("metadata.artist", "Comma-separated sources of the artist tag: youtube, itunes, musicbrainz; youtube if empty",
+		func(c *config) *[]string { return &c.Metadata.Artist }),
+	listSetting("metadata.album", "Comma-separated sources of the album tag: youtube, itunes, musicbrainz; youtube if empty",
+		func(c *config) *[]string { return &c.Metadata.Album }),
+	listSetting("metadata.year", "Comma-separated sources of the year tag: youtube, itunes, musicbrainz; youtube if empty",
+		func(c *config) *[]string { return &c.Metadata.Year }),
+	listSetting("metadata.art", "Comma-separated sources of the embedded cover: youtube, itunes, musicbrainz; youtube if empty",
+		func(c *config) *[]string { return &c.Metadata.Art }),
+	stringSetting("art.mode", "auto (terminal images where supported, else ASCII art), ascii or off",
+		func(c *config) *string { return &c.Art.Mode }),
+	stringSetting("keys.pause", "Play/pause key, SPACE if empty",
+		func(c *config) *string { return &c.Keys.Pause }),
+	stringSetting("keys.next", "Next track key, n if empty",
+		func(c *config) *string { return &c.Keys.Next }),
+	stringSetting("keys.stop", "Stop key, s if empty",
+		func(c *config) *string { return &c.Keys.Stop }),
+	stringSetting("keys.volume_up", "Volume up key, + if empty",
+		func(c *config) *string { return &c.Keys.VolumeUp }),
+	stringSetting("keys.volume_down", "Volume down key, - if empty",
+		func(c *config) *string { return &c.Keys.VolumeDown }),
+	stringSetting("keys.mute", "Mute key, m if empty",
+		func(c *config) *string { return &c.Keys.Mute }),
+	stringSetting("keys.lyrics", "Lyrics search key on the playing screen, l if empty",
+		func(c *config) *string { return &c.Keys.Lyrics }),
+	stringSetting("keys.info", "Info panel key on the playing screen, i if empty",
+		func(c *config) *string { return &c.Keys.Info }),
+	stringSetting("keys.export", "Queue export key on the playing screen, e if empty",
+		func(c *config) *string { return &c.Keys.Export }),
+	stringSetting("keys.browse", "Browse key on the playing screen, b if empty",
+		func(c *config) *string { return &c.Keys.Browse }),
+	stringSetting("keys.seek_forward", "Seek forward key, right if empty",
+		func(c *config) *string { return &c.Keys.SeekForward }),
+	stringSetting("keys.seek_back", "Seek back key, left if empty",
+		func(c *config) *string { return &c.Keys.SeekBack }),
+	stringSetting("output.mode", "speaker, pipe, pipewire or jack (applies on restart)",
+		func(c *config) *string { return &c.Output.Mode }),
+	stringSetting("output.path", "FIFO or file for pipe mode, - for stdout; target node or ports for pipewire/jack; mpv audio device for exclusive (applies on restart)",
+		func(c *config) *string { return &c.Output.Path }),
+	stringSetting("output.format", "pcm (s16le stereo at playback.sample_rate) or mp3 (applies on restart)",
+		func(c *config) *string { return &c.Output.Format }),
+	stringSetting("output.name", "Application and stream name shown in mixers, gomusic if not set (applies on restart)",
+		func(c *config) *string { return &c.Output.Name }),
+	boolSetting("output.exclusive", "Play lossless local files bit-perfect with exclusive use of the sound card (mpv backend)",
+		func(c *config) *bool { return &c.Output.Exclusive }),
+}
+
+func stringSetting(key, help string, field func(*config) *string) setting {
+	return setting{key: key, help: help,
+		get: func(c *config) string { return *field(c) },
+		set: func(c *config, v string) error {
+			*field(c) = strings.TrimSpace(v)
+			return nil
+		},
+	}
+}
+
+func intSetting(key, help string, field func(*config) *int) setting {
+	return setting{key: key, help: help,
+		get: func(c *config) string { return strconv.Itoa(*field(c)) },
+		set: func(c *config, v string) error {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("%s: %q is not a number", key, v)
+			}
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+func boolSetting(key, help string, field func(*config) *bool) setting {
+	return setting{key: key, help: help,
+		get: func(c *config) string { return strconv.FormatBool(*field(c)) },
+		set: func(c *config, v string) error {
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("%s: use true or false", key)
+			}
+			*field(c) = b
+			return nil
+		},
+	}
+}
+
+func floatSetting(key, help string, field func(*config) *float64) setting {
+	return setting{key: key, help: help,
+		get: func(c *config) string { return strconv.FormatFloat(*field(c), 'g', -1, 64) },
+		set: func(c *config, v string) error {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return fmt.Errorf("%s: %q is not a number", key, v)
+			}
+			*field(c) = f
+			return nil
+		},
+	}
+}
+
+func durationSetting(key, help string, field func(*config) *time.Duration) setting {
+	return setting{key: key, help: help,
+		get: func(c *config) string { return field(c).String() },
+		set: func(c *config, v string) error {
+			d, err := time.ParseDuration(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("%s: %q is not a duration like 1.5s or 2m", key, v)
+			}
+			*field(c) = d
+			return nil
+		},
+	}
+}
+
+func listSetting(key, help string, field func(*config) *[]string) setting {
+	return setting{key: key, help: help,
+		get: func(c *config) string { return strings.Join(*field(c), ", ") },
+		set: func(c *config, v string) error {
+			var items []string
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			*field(c) = items
+			return nil
+		},
+	}
+}
+
+// applySetting changes one setting, puts it into effect and saves config.toml.
+// An invalid value leaves the running configuration untouched.
+func applySetting(s setting, value string) error {
+	next := cfg
+	if err := s.set(&next, value); err != nil {
+		return err
+	}
+	if err := next.validate(); err != nil {
+		return err
+	}
+	if err := applyNetworkConfig(next.Network); err != nil {
+		return err
+	}
+	applyTitleCleaning(next.TitleCleaning)
+	applyChannelMix(next.Playback)
+	applyTheme(next.Theme)
+	applyKeys(next.Keys)
+	cfg = next
+	return saveConfig()
+}
+
+// saveConfig writes cfg back to config.toml
+func saveConfig() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+	if err := enc.Encode(cfg); err != nil {
+		return err
+	}
+	// Replace atomically so a failed write leaves the old config in place
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (m *model) openSettings() {
+	m.settingsCursor = 0
+	m.settingsEditing = false
+	m.settingsErr = nil
+	m.settingsStatus = ""
+	m.state = stateSettings
+}
+
+func (m model) updateSettings(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.settingsEditing {
+		switch msg.String() {
+		case "esc":
+			m.settingsEditing = false
+			m.settingsErr = nil
+			return m, nil
+		case "enter":
+			s := settings[m.settingsCursor]
+			if err := applySetting(s, m.settingsInput.Value()); err != nil {
+				m.settingsErr = err
+				return m, nil
+			}
+			m.settingsEditing = false
+			m.settingsErr = nil
+			m.settingsStatus = "Saved " + s.key
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.settingsInput, cmd = m.settingsInput.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "esc", "q", "ctrl+t":
+		m.state = stateInput
+	case "up", "k":
+		m.settingsCursor = (m.settingsCursor - 1 + len(settings)) % len(settings)
+	case "down", "j":
+		m.settingsCursor = (m.settingsCursor + 1) % len(settings)
+	case "enter":
+		ti := textinput.New()
+		ti.SetValue(settings[m.settingsCursor].get(&cfg))
+		ti.CharLimit = 200
+		ti.Width = 40
+		ti.Focus()
+		m.settingsInput = ti
+		m.settingsEditing = true
+		m.settingsStatus = ""
+		return m, textinput.Blink
+	}
+	return m, nil
+}
+
+func (m model) viewSettings() string {
+	width := 0
+	for _, s := range settings {
+		width = max(width, len(s.key))
+	}
+	var rows []string
+	for i, s := range settings {
+		label := fmt.Sprintf("%-*s", width, s.key)
+		value := s.get(&cfg)
+		if value == "" {
+			value = helpStyle.Render("(not set)")
+		}
+		switch {
+		case i == m.settingsCursor && m.settingsEditing:
+			rows = append(rows, "> "+statusStyle.Render(label)+"  "+m.settingsInput.View())
+		case i == m.settingsCursor:
+			rows = append(rows, "> "+statusStyle.Render(label)+"  "+value)
+		default:
+			rows = append(rows, "  "+label+"  "+value)
+		}
+	}
+
+	status := helpStyle.Render(settings[m.settingsCursor].help)
+	if m.settingsErr != nil {
+		status = errorStyle.Render(m.settingsErr.Error())
+	} else if m.settingsStatus != "" {
+		status = statusStyle.Render(m.settingsStatus)
+	}
+	path, _ := configPath()
+	keys := "↑/↓: Select  •  ENTER: Edit  •  ESC: Back"
+	if m.settingsEditing {
+		keys = "ENTER: Save  •  ESC: Cancel"
+	}
+
+	return fmt.Sprintf("\n  %s\n  %s\n\n  %s\n\n  %s\n\n  %s\n",
+		titleStyle.Render("Settings"),
+		helpStyle.Render(path),
+		strings.Join(rows, "\n  "),
+		status,
+		helpStyle.Render(keys),
+	)
+}
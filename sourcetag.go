@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sourceURL is the page a track was downloaded from, so it can be found again
+// and re-fetched at a higher quality later
+func sourceURL(id string) string {
+	return "https://music.youtube.com/watch?v=" + id
+}
+
+// sourceTagArgs returns the ffmpeg options that record where a track came
+// from and which gomusic made the file
+func sourceTagArgs(id string) []string {
+	tool := "gomusic " + appVersion
+	return []string{
+		"-metadata", "comment=" + sourceURL(id) + " (downloaded with " + tool + ")",
+		"-metadata", "encoded_by=" + tool,
+	}
+}
+
+// tagSourceURL adds the WOAS (official audio source webpage) frame to the
+// ID3v2 tag of an MP3 file. ffmpeg can only write text frames, so the frame
+// is inserted into the tag it wrote. Other formats keep just the comment.
+func tagSourceURL(path, id string) error {
+	if !strings.EqualFold(filepath.Ext(path), ".mp3") {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) < 10 || string(data[:3]) != "ID3" {
+		return fmt.Errorf("%s has no ID3v2 tag", path)
+	}
+	version, flags := data[3], data[5]
+	if version != 3 && version != 4 {
+		return fmt.Errorf("%s: ID3v2.%d tags are not supported", path, version)
+	}
+	// Unsynchronised tags, extended headers and footers are never written by ffmpeg
+	if flags&0xd0 != 0 {
+		return fmt.Errorf("%s: unsupported ID3v2 tag flags %#x", path, flags)
+	}
+
+	url := []byte(sourceURL(id))
+	frame := make([]byte, 10, 10+len(url))
+	copy(frame, "WOAS")
+	if version == 4 {
+		putSyncsafe(frame[4:8], len(url))
+	} else {
+		binary.BigEndian.PutUint32(frame[4:8], uint32(len(url)))
+	}
+	frame = append(frame, url...)
+
+	header := append([]byte(nil), data[:10]...)
+	putSyncsafe(header[6:10], syncsafe(data[6:10])+len(frame))
+
+	tmp := path + ".tag"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	for _, part := range [][]byte{header, frame, data[10:]} {
+		if _, err := f.Write(part); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// syncsafe decodes an ID3v2 size stored 7 bits per byte
+func syncsafe(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+// putSyncsafe encodes n as an ID3v2 size 7 bits per byte
+func putSyncsafe(b []byte, n int) {
+	b[0] = byte(n >> 21 & 0x7f)
+	b[1] = byte(n >> 14 & 0x7f)
+	b[2] = byte(n >> 7 & 0x7f)
+	b[3] = byte(n & 0x7f)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	bolt "go.etcd.io/bbolt"
+)
+
+// maxBundleEntry caps the size of one file taken from a state bundle
+const maxBundleEntry = 256 << 20
+
+// Folders of a state bundle: the config file and the files of the data directory
+const (
+	bundleConfigDir = "config"
+	bundleDataDir   = "data"
+)
+
+// runExportState implements `gomusic export-state FILE.tar.gz`: the config,
+// saved state, history and download archive are bundled for a backup or
+// another machine
+func runExportState(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gomusic export-state FILE.tar.gz")
+		return 2
+	}
+	n, err := exportState(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d files to %s\n", n, args[0])
+	return 0
+}
+
+// exportState writes the bundle to dest and returns how many files it holds
+func exportState(dest string) (int, error) {
+	var files [][2]string // Bundle name, path
+	if configFile, err := configPath(); err == nil {
+		if _, err := os.Stat(configFile); err == nil {
+			files = append(files, [2]string{bundleConfigDir + "/" + configFileName, configFile})
+		}
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if e.Type().IsRegular() && !strings.HasSuffix(e.Name(), ".tmp") && !strings.HasSuffix(e.Name(), ".bak") {
+			files = append(files, [2]string{bundleDataDir + "/" + e.Name(), filepath.Join(dir, e.Name())})
+		}
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("nothing to export: no config or saved state yet")
+	}
+
+	tmp := dest + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp)
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		if err := addTarFile(tw, file[0], file[1]); err != nil {
+			f.Close()
+			return 0, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	return len(files), os.Rename(tmp, dest)
+}
+
+// addTarFile stores the file at path in the bundle as name. The state store
+// is copied in a transaction, so a gomusic writing to it meanwhile can't tear it.
+func addTarFile(tw *tar.Writer, name, path string) error {
+	var data []byte
+	var err error
+	if filepath.Base(path) == stateFile {
+		data, err = snapshotState()
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// runImportState implements `gomusic import-state FILE.tar.gz`: the files of
+// a bundle made by export-state replace the config and saved state, keeping
+// the replaced files as .bak copies
+func runImportState(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gomusic import-state FILE.tar.gz")
+		return 2
+	}
+	release, ok := claimCommand("import-state")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	imported, err := importState(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, path := range imported {
+		fmt.Fprintf(os.Stderr, "Imported %s\n", path)
+	}
+	return 0
+}
+
+// importState reads the whole bundle at src before writing anything, so a
+// damaged bundle changes nothing, and returns the paths it wrote. The state
+// store stays locked while the files are swapped.
+func importState(src string) ([]string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: not a state bundle: %w", src, err)
+	}
+	configFile, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	type bundleFile struct {
+		path string
+		data []byte
+	}
+	var files []bundleFile
+	var store *bolt.DB // The bundled state store, copied into the live one
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src, err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		target, err := bundleTarget(h.Name, configFile, dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src, err)
+		}
+		if h.Size > maxBundleEntry {
+			return nil, fmt.Errorf("%s: %s is too large", src, h.Name)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxBundleEntry))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src, err)
+		}
+		if target == configFile {
+			var c config
+			if _, err := toml.Decode(string(data), &c); err != nil {
+				return nil, fmt.Errorf("%s: the bundled config is invalid: %w", src, err)
+			}
+			if err := c.validate(); err != nil {
+				return nil, fmt.Errorf("%s: the bundled config is invalid: %w", src, err)
+			}
+		}
+		if target == filepath.Join(dir, stateFile) && store == nil {
+			db, closeCopy, err := openStateCopy(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: the bundled state is invalid: %w", src, err)
+			}
+			defer closeCopy()
+			store = db
+			continue
+		}
+		files = append(files, bundleFile{target, data})
+	}
+	if len(files) == 0 && store == nil {
+		return nil, fmt.Errorf("%s: the bundle is empty", src)
+	}
+
+	var imported []string
+	err = withState(true, func(tx *bolt.Tx) error {
+		for _, file := range files {
+			if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+				return err
+			}
+			if _, err := os.Stat(file.path); err == nil {
+				if err := os.Rename(file.path, file.path+".bak"); err != nil {
+					return err
+				}
+			}
+			if err := os.WriteFile(file.path, file.data, 0644); err != nil {
+				return err
+			}
+			imported = append(imported, file.path)
+		}
+		if store == nil {
+			return nil
+		}
+		path := filepath.Join(dir, stateFile)
+		if err := replaceState(tx, store, path+".bak"); err != nil {
+			return err
+		}
+		imported = append(imported, path)
+		return nil
+	})
+	return imported, err
+}
+
+// bundleTarget maps a bundle entry to the file it restores, refusing entries
+// export-state doesn't write
+func bundleTarget(name, configFile, dir string) (string, error) {
+	folder, base := path.Split(path.Clean(name))
+	switch {
+	case folder == bundleConfigDir+"/" && base == configFileName:
+		return configFile, nil
+	case folder == bundleDataDir+"/" && base != "" && base != "." && base != "..":
+		return filepath.Join(dir, base), nil
+	}
+	return "", fmt.Errorf("unexpected file %q in the bundle", name)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// session collects download statistics for the lifetime of the process
+var session = &sessionStats{started: time.Now()}
+
+// sessionStats is updated from download goroutines and read by the UI
+type sessionStats struct {
+	mu       sync.Mutex
+	started  time.Time
+	tracks   int
+	albums   int
+	failures int
+	bytes    int64
+	active   time.Duration // Time spent transferring, used for the average speed
+}
+
+// sessionRecord is a snapshot of the statistics, as stored in the history
+type sessionRecord struct {
+	Type     string    `json:"type"`
+	Started  time.Time `json:"started"`
+	Ended    time.Time `json:"ended"`
+	Tracks   int       `json:"tracks"`
+	Albums   int       `json:"albums"`
+	Failures int       `json:"failures"`
+	Bytes    int64     `json:"bytes"`
+	Seconds  float64   `json:"download_seconds"`
+}
+
+func (s *sessionStats) addTransfer(n int64, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytes += n
+	s.active += d
+}
+
+func (s *sessionStats) trackDone() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracks++
+}
+
+func (s *sessionStats) albumDone() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.albums++
+}
+
+func (s *sessionStats) failed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures++
+}
+
+func (s *sessionStats) snapshot() sessionRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sessionRecord{
+		Type:     "session",
+		Started:  s.started,
+		Ended:    time.Now(),
+		Tracks:   s.tracks,
+		Albums:   s.albums,
+		Failures: s.failures,
+		Bytes:    s.bytes,
+		Seconds:  s.active.Seconds(),
+	}
+}
+
+// empty reports whether nothing was downloaded or attempted this session
+func (r sessionRecord) empty() bool {
+	return r.Tracks == 0 && r.Failures == 0 && r.Bytes == 0
+}
+
+// lines formats the statistics for the stats screen and the exit summary
+func (r sessionRecord) lines() []string {
+	speed := "n/a"
+	if r.Seconds > 0 {
+		speed = formatBytes(int64(float64(r.Bytes)/r.Seconds)) + "/s"
+	}
+	return []string{
+		fmt.Sprintf("Tracks downloaded: %d", r.Tracks),
+		fmt.Sprintf("Albums downloaded: %d", r.Albums),
+		fmt.Sprintf("Data transferred:  %s", formatBytes(r.Bytes)),
+		fmt.Sprintf("Average speed:     %s", speed),
+		fmt.Sprintf("Failures:          %d", r.Failures),
+	}
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "4.2 MiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
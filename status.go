@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// statusStaleAfter is how old a status file may get before gomusic is assumed gone
+const statusStaleAfter = 10 * time.Second
+
+// nowPlayingStatus is what the status file holds for tmux, polybar and waybar
+type nowPlayingStatus struct {
+	State    string `json:"state"` // "playing", "paused" or "stopped"
+	Title    string `json:"title,omitempty"`
+	Artist   string `json:"artist,omitempty"`
+	Album    string `json:"album,omitempty"`
+	Position int    `json:"position"` // Seconds
+	Duration int    `json:"duration"` // Seconds, 0 if unknown
+	PID      int    `json:"pid"`
+	Updated  int64  `json:"updated"` // Unix time
+}
+
+// statusPath returns the status file, in XDG_RUNTIME_DIR when there is one
+func statusPath() string {
+	base := os.Getenv("XDG_RUNTIME_DIR")
+	if base == "" {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, fmt.Sprintf("gomusic-%d", os.Getuid()), "status.json")
+}
+
+// lastStatus is the status last written, so unchanged state isn't rewritten
+var lastStatus nowPlayingStatus
+
+// currentStatus describes what the model is playing
+func (m model) currentStatus() nowPlayingStatus {
+	s := nowPlayingStatus{State: "stopped", PID: os.Getpid()}
+	if m.playback.playingSong == "" {
+		return s
+	}
+	s.State = "playing"
+	if m.playback.isPaused {
+		s.State = "paused"
+	}
+	s.Title = m.selected.title
+	s.Artist = m.selected.author
+	s.Album = m.selected.album
+	s.Position = int(m.playback.position.Seconds())
+	if w := m.playback.waveform; w != nil {
+		s.Duration = int(w.Duration.Seconds())
+	} else {
+		s.Duration = m.selected.duration
+	}
+	return s
+}
+
+// writeStatus updates the status file when what's playing or the position
+// changed, and refreshes it now and then so readers can tell it's current
+func (m model) writeStatus() {
+	s := m.currentStatus()
+	s.Updated = lastStatus.Updated
+	if s == lastStatus && time.Since(time.Unix(s.Updated, 0)) < statusStaleAfter/2 {
+		return
+	}
+	s.Updated = time.Now().Unix()
+	lastStatus = s
+
+	path := statusPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	// Replace atomically so readers never see half a file
+	tmp := path + ".tmp"
+	if os.WriteFile(tmp, data, 0644) == nil {
+		os.Rename(tmp, path)
+	}
+}
+
+// removeStatus deletes the status file when gomusic exits
+func removeStatus() {
+	os.Remove(statusPath())
+}
+
+// summary is the one-line form printed by gomusic status
+func (s nowPlayingStatus) summary() string {
+	if s.State == "stopped" || s.Title == "" {
+		return "Not playing"
+	}
+	icon := "▶"
+	if s.State == "paused" {
+		icon = "⏸"
+	}
+	line := icon + " " + s.Title
+	if s.Artist != "" {
+		line = fmt.Sprintf("%s %s – %s", icon, s.Artist, s.Title)
+	}
+	pos := formatDuration(time.Duration(s.Position) * time.Second)
+	if s.Duration > 0 {
+		return fmt.Sprintf("%s [%s/%s]", line, pos, formatDuration(time.Duration(s.Duration)*time.Second))
+	}
+	return fmt.Sprintf("%s [%s]", line, pos)
+}
+
+// runStatus prints what a running gomusic is playing, as one line or as JSON
+func runStatus(args []string) int {
+	asJSON := false
+	for _, arg := range args {
+		switch arg {
+		case "--json":
+			asJSON = true
+		default:
+			fmt.Fprintln(os.Stderr, "usage: gomusic status [--json]")
+			return 2
+		}
+	}
+
+	s := nowPlayingStatus{State: "stopped"}
+	if data, err := os.ReadFile(statusPath()); err == nil {
+		json.Unmarshal(data, &s)
+	}
+	// A crashed gomusic leaves its last state behind
+	if time.Since(time.Unix(s.Updated, 0)) > statusStaleAfter {
+		s = nowPlayingStatus{State: "stopped"}
+	}
+
+	if asJSON {
+		data, _ := json.Marshal(s)
+		fmt.Println(string(data))
+		return 0
+	}
+	fmt.Println(s.summary())
+	return 0
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// dataDir returns the directory holding gomusic's persistent state, creating it if needed
+func dataDir() (string, error) {
+	base := os.Getenv("XDG_DATA_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+	dir := filepath.Join(base, "gomusic")
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// cacheDir returns a subdirectory of the user cache directory for gomusic, creating it if needed
+func cacheDir(sub string) (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, "gomusic", sub)
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// stateFile is the embedded database holding every piece of saved state,
+// so a feature keeps its state without a file of its own
+const stateFile = "state.db"
+
+// stateOpenTimeout is how long to wait for another gomusic to finish with
+// the state store before giving up
+const stateOpenTimeout = 5 * time.Second
+
+// Buckets of the state store
+var (
+	metaBucket    = []byte("meta")    // "version": the number of stateMigrations applied
+	stateBucket   = []byte("state")   // A JSON value per key, e.g. volumeKey
+	archiveBucket = []byte("archive") // Video ID of every track downloaded, to the time it was
+	historyBucket = []byte("history") // A JSON sessionRecord per session, keyed by sequence
+)
+
+var versionKey = []byte("version")
+
+// stateMigrations bring the state of an older gomusic up to date, in order.
+// Each returns the files it made obsolete, removed once its changes are committed.
+var stateMigrations = []func(dir string, tx *bolt.Tx) ([]string, error){
+	importStateFiles, // 1: the JSON file per feature of earlier versions
+	importStateLogs,  // 2: the download archive and session history logs
+	importStateJSON,  // 3: state.json, the single JSON file that followed them
+}
+
+// stateMu serializes access to the state store within the process; across
+// processes the store locks its file while open
+var stateMu sync.Mutex
+
+// legacyStateKeys are the state entries that were files of their own before
+// the state store, named after the key with .json added
+var legacyStateKeys = []string{albumDownloadKey, pausedDownloadsKey, volumeKey}
+
+// importStateFiles moves the state files of earlier versions into the state store
+func importStateFiles(dir string, tx *bolt.Tx) ([]string, error) {
+	var obsolete []string
+	for _, key := range legacyStateKeys {
+		path := filepath.Join(dir, key+".json")
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if json.Valid(data) {
+			if err := tx.Bucket(stateBucket).Put([]byte(key), data); err != nil {
+				return nil, err
+			}
+		}
+		obsolete = append(obsolete, path)
+	}
+	return obsolete, nil
+}
+
+// importStateLogs moves the download archive, in yt-dlp's archive format, and
+// the JSON lines of the session history into the state store
+func importStateLogs(dir string, tx *bolt.Tx) ([]string, error) {
+	var obsolete []string
+	path := filepath.Join(dir, legacyArchiveFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		var ids []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "youtube" {
+				ids = append(ids, fields[1])
+			}
+		}
+		if err := archiveIDs(tx, ids, time.Time{}); err != nil {
+			return nil, err
+		}
+		obsolete = append(obsolete, path)
+	}
+
+	path = filepath.Join(dir, legacyHistoryFile)
+	data, err = os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && json.Valid([]byte(line)) {
+				if err := putHistory(tx, []byte(line)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		obsolete = append(obsolete, path)
+	}
+	return obsolete, nil
+}
+
+// importStateJSON moves the entries of state.json into the state store. Its
+// "archive" and "history" entries hold the download archive and session
+// history; the others are state entries as they are.
+func importStateJSON(dir string, tx *bolt.Tx) ([]string, error) {
+	path := filepath.Join(dir, "state.json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Entries map[string]json.RawMessage `json:"entries"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("state.json: %w", err)
+	}
+	for key, value := range doc.Entries {
+		switch key {
+		case "archive":
+			var ids []string
+			if err := json.Unmarshal(value, &ids); err != nil {
+				return nil, fmt.Errorf("state.json: %w", err)
+			}
+			err = archiveIDs(tx, ids, time.Time{})
+		case "history":
+			var sessions []json.RawMessage
+			if err := json.Unmarshal(value, &sessions); err != nil {
+				return nil, fmt.Errorf("state.json: %w", err)
+			}
+			for _, session := range sessions {
+				if err = putHistory(tx, session); err != nil {
+					break
+				}
+			}
+		default:
+			err = tx.Bucket(stateBucket).Put([]byte(key), value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []string{path}, nil
+}
+
+// openState opens the state store, migrating it first if it is from an older
+// version. The caller holds stateMu and closes the store when done; until
+// then other processes wait for it.
+func openState() (*bolt.DB, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(dir, stateFile), 0644, &bolt.Options{Timeout: stateOpenTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("%s is in use by another gomusic", stateFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var version int
+	db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(metaBucket); b != nil {
+			version, _ = strconv.Atoi(string(b.Get(versionKey)))
+		}
+		return nil
+	})
+	switch {
+	case version == len(stateMigrations):
+		return db, nil
+	case version > len(stateMigrations):
+		db.Close()
+		return nil, fmt.Errorf("%s was written by a newer gomusic; update to use it", stateFile)
+	}
+
+	var obsolete []string
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{metaBucket, stateBucket, archiveBucket, historyBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		for ; version < len(stateMigrations); version++ {
+			files, err := stateMigrations[version](dir, tx)
+			if err != nil {
+				return fmt.Errorf("migrating %s to version %d: %w", stateFile, version+1, err)
+			}
+			obsolete = append(obsolete, files...)
+		}
+		return tx.Bucket(metaBucket).Put(versionKey, []byte(strconv.Itoa(version)))
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, path := range obsolete {
+		os.Remove(path)
+	}
+	return db, nil
+}
+
+// withState runs fn in a transaction on the state store, a read-write one
+// if writable. A write is committed only if fn returns nil.
+func withState(writable bool, fn func(tx *bolt.Tx) error) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	db, err := openState()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if writable {
+		return db.Update(fn)
+	}
+	return db.View(fn)
+}
+
+// loadState reads the state entry key into v, leaving v as it is when there is none
+func loadState(key string, v any) error {
+	return withState(false, func(tx *bolt.Tx) error {
+		data := tx.Bucket(stateBucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, v)
+	})
+}
+
+// saveState stores v as the state entry key
+func saveState(key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return withState(true, func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).Put([]byte(key), data)
+	})
+}
+
+// updateState reads the state entry key into v, lets change modify it and
+// stores the result, all in one transaction
+func updateState(key string, v any, change func()) error {
+	return withState(true, func(tx *bolt.Tx) error {
+		b := tx.Bucket(stateBucket)
+		if data := b.Get([]byte(key)); data != nil {
+			if err := json.Unmarshal(data, v); err != nil {
+				return err
+			}
+		}
+		change()
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), data)
+	})
+}
+
+// removeState deletes the state entry key. A missing entry is not an error.
+func removeState(key string) error {
+	return withState(true, func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).Delete([]byte(key))
+	})
+}
+
+// appendHistory adds v to the session history
+func appendHistory(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return withState(true, func(tx *bolt.Tx) error {
+		return putHistory(tx, data)
+	})
+}
+
+// putHistory adds the JSON session record data to the history, after the others
+func putHistory(tx *bolt.Tx, data []byte) error {
+	b := tx.Bucket(historyBucket)
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	return b.Put(binary.BigEndian.AppendUint64(nil, seq), data)
+}
+
+// archiveIDs adds ids to the download archive as downloaded at when, zero if unknown
+func archiveIDs(tx *bolt.Tx, ids []string, when time.Time) error {
+	var stamp []byte
+	if !when.IsZero() {
+		stamp = []byte(when.UTC().Format(time.RFC3339))
+	}
+	b := tx.Bucket(archiveBucket)
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		if err := b.Put([]byte(id), stamp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotState returns a consistent copy of the state store's file
+func snapshotState() ([]byte, error) {
+	var buf bytes.Buffer
+	err := withState(false, func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(&buf)
+		return err
+	})
+	return buf.Bytes(), err
+}
+
+// openStateCopy opens data, a copy of the state store as made by
+// snapshotState, from a temporary file that closing it removes
+func openStateCopy(data []byte) (db *bolt.DB, closeCopy func(), err error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.CreateTemp(dir, "state-*.tmp")
+	if err != nil {
+		return nil, nil, err
+	}
+	path := f.Name()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		db, err = bolt.Open(path, 0644, &bolt.Options{ReadOnly: true, Timeout: stateOpenTimeout})
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, nil, err
+	}
+	closeCopy = func() {
+		db.Close()
+		os.Remove(path)
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if meta == nil {
+			return fmt.Errorf("not a gomusic %s", stateFile)
+		}
+		if version, _ := strconv.Atoi(string(meta.Get(versionKey))); version > len(stateMigrations) {
+			return fmt.Errorf("%s was written by a newer gomusic; update to use it", stateFile)
+		}
+		return nil
+	})
+	if err != nil {
+		closeCopy()
+		return nil, nil, err
+	}
+	return db, closeCopy, nil
+}
+
+// replaceState swaps the contents of the state store in tx for those of src,
+// saving the old contents to backup first. The store's file stays in place,
+// so a gomusic waiting for it reads the new contents rather than a replaced file.
+// An older src is migrated the next time the store is opened.
+func replaceState(tx *bolt.Tx, src *bolt.DB, backup string) error {
+	var buf bytes.Buffer
+	if _, err := tx.WriteTo(&buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(backup, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	var names [][]byte
+	tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		names = append(names, bytes.Clone(name))
+		return nil
+	})
+	for _, name := range names {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+	return src.View(func(stx *bolt.Tx) error {
+		return stx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			dst, err := tx.CreateBucket(bytes.Clone(name))
+			if err != nil {
+				return err
+			}
+			if err := dst.SetSequence(b.Sequence()); err != nil { // History keys go on from it
+				return err
+			}
+			return b.ForEach(func(k, v []byte) error {
+				if v == nil {
+					return nil // The store has no nested buckets
+				}
+				return dst.Put(bytes.Clone(k), bytes.Clone(v))
+			})
+		})
+	})
+}
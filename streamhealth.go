@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// streamBufferSize is how much encoded audio is read ahead of the decoder
+// (about 16 seconds of 128 kbps MP3)
+const streamBufferSize = 256 * 1024
+
+// streamBuffer reads ahead from the ffmpeg pipe so the decoder is fed from
+// memory, tracking fill level and underruns for the health indicator
+type streamBuffer struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	data      []byte
+	err       error
+	started   bool // Set once the first data arrived; empty reads before that are startup, not underruns
+	underruns int
+}
+
+func newStreamBuffer(src io.Reader) *streamBuffer {
+	b := &streamBuffer{}
+	b.cond = sync.NewCond(&b.mu)
+	go b.fill(src)
+	return b
+}
+
+func (b *streamBuffer) fill(src io.Reader) {
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(chunk)
+		b.mu.Lock()
+		for len(b.data)+n > streamBufferSize && b.err == nil {
+			b.cond.Wait()
+		}
+		b.data = append(b.data, chunk[:n]...)
+		if n > 0 {
+			b.started = true
+		}
+		if err != nil {
+			b.err = err
+		}
+		b.cond.Broadcast()
+		b.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (b *streamBuffer) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.data) == 0 && b.err == nil && b.started {
+		b.underruns++
+	}
+	for len(b.data) == 0 && b.err == nil {
+		b.cond.Wait()
+	}
+	if len(b.data) == 0 {
+		return 0, b.err
+	}
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+	b.cond.Broadcast()
+	return n, nil
+}
+
+// Close stops the buffer; pending and future reads return io.EOF
+func (b *streamBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err == nil {
+		b.err = io.EOF
+	}
+	b.cond.Broadcast()
+	return nil
+}
+
+// stats returns the fill level between 0 and 1 and the number of underruns so far
+func (b *streamBuffer) stats() (float64, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return float64(len(b.data)) / streamBufferSize, b.underruns
+}
+
+// streamHealth collects buffer and network events for the current stream
+type streamHealth struct {
+	buffer     *streamBuffer
+	reconnects atomic.Int32
+}
+
+// watchFFmpegLog counts reconnect attempts reported on ffmpeg's stderr
+func (h *streamHealth) watchFFmpegLog(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		if strings.Contains(strings.ToLower(scanner.Text()), "reconnect") {
+			h.reconnects.Add(1)
+		}
+	}
+}
+
+// render formats the indicator shown under the now-playing title
+func (h *streamHealth) render() string {
+	fill, underruns := h.buffer.stats()
+	const width = 8
+	filled := int(fill*width + 0.5)
+	if filled > width {
+		filled = width
+	}
+	bar := strings.Repeat("▰", filled) + strings.Repeat("▱", width-filled)
+	return fmt.Sprintf("Buffer %s %3.0f%%  •  Underruns: %d  •  Reconnects: %d",
+		bar, fill*100, underruns, h.reconnects.Load())
+}
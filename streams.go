@@ -0,0 +1,164 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kkdai/youtube/v2"
+)
+
+// streamsMsg delivers the audio streams of the track the stream picker is open for
+type streamsMsg struct {
+	id       string
+	formats  youtube.FormatList
+	duration time.Duration
+	err      error
+}
+
+// fetchStreams lists the audio streams of track id, best sounding first
+func fetchStreams(ctx context.Context, id string) tea.Cmd {
+	return func() tea.Msg {
+		client := youtube.Client{}
+		video, err := client.GetVideoContext(ctx, id)
+		if err != nil {
+			return streamsMsg{id: id, err: err}
+		}
+		formats := video.Formats.Type("audio")
+		if len(formats) == 0 {
+			return streamsMsg{id: id, err: fmt.Errorf("no audio format found")}
+		}
+		slices.SortStableFunc(formats, func(a, b youtube.Format) int {
+			return cmp.Compare(streamScore(&b), streamScore(&a))
+		})
+		return streamsMsg{id: id, formats: formats, duration: video.Duration}
+	}
+}
+
+// startDownload downloads item, first through the options prompt or the
+// stream picker when the config asks for them
+func (m *model) startDownload(item songItem) tea.Cmd {
+	// Options picked for an earlier download never carry over
+	m.dlOptions = downloadOptions{}
+	if cfg.Download.Prompt {
+		return m.openDownloadOptions(item)
+	}
+	if cfg.Download.PickStream {
+		return m.openStreamPicker(item)
+	}
+	m.selected = item
+	m.state = stateDownloading
+	go m.runDownloadConvert(downloadJob.start())
+	return nil
+}
+
+// openStreamPicker lists the audio streams of item to download from, with
+// m.dlOptions already holding the rest of the download's options
+func (m *model) openStreamPicker(item songItem) tea.Cmd {
+	m.streamItem = item
+	m.streamReturn = m.state
+	m.streamFormats = nil
+	m.streamDuration = 0
+	m.streamCursor = 0
+	m.streamErr = nil
+	m.state = statePickStream
+	return tea.Batch(m.spinner.Tick, fetchStreams(searchJob.start(), item.id))
+}
+
+// applyStreams shows the fetched streams with the one the download would
+// pick by itself selected
+func (m *model) applyStreams(msg streamsMsg) {
+	if m.state != statePickStream || msg.id != m.streamItem.id {
+		return
+	}
+	m.streamFormats, m.streamDuration, m.streamErr = msg.formats, msg.duration, msg.err
+	if best := bestAudioFormat(msg.formats, cfg.Download.StreamQuality); best != nil {
+		m.streamCursor = slices.IndexFunc(msg.formats, func(f youtube.Format) bool { return f.ItagNo == best.ItagNo })
+	}
+}
+
+func (m model) updateStreamPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q":
+		searchJob.stop()
+		m.state = m.streamReturn
+	case "up", "k":
+		if n := len(m.streamFormats); n > 0 {
+			m.streamCursor = (m.streamCursor - 1 + n) % n
+		}
+	case "down", "j":
+		if n := len(m.streamFormats); n > 0 {
+			m.streamCursor = (m.streamCursor + 1) % n
+		}
+	case "enter":
+		if len(m.streamFormats) == 0 {
+			return m, nil
+		}
+		m.dlOptions.itag = m.streamFormats[m.streamCursor].ItagNo
+		m.selected = m.streamItem
+		m.state = stateDownloading
+		go m.runDownloadConvert(downloadJob.start())
+	}
+	return m, nil
+}
+
+// streamSize is the size of stream f, estimated from its bitrate when
+// YouTube leaves it out
+func streamSize(f *youtube.Format, duration time.Duration) string {
+	if f.ContentLength > 0 {
+		return formatBytes(f.ContentLength)
+	}
+	if bitrate := formatAudioInfo(f).bitrate; bitrate > 0 && duration > 0 {
+		return "~" + formatBytes(int64(duration.Seconds()*float64(bitrate)/8))
+	}
+	return "?"
+}
+
+func (m model) viewStreamPicker() string {
+	var body string
+	switch {
+	case m.streamErr != nil:
+		body = errorStyle.Render("Loading the streams failed: " + m.streamErr.Error())
+	case m.streamFormats == nil:
+		body = m.spinner.View() + " Loading streams..."
+	default:
+		def := bestAudioFormat(m.streamFormats, cfg.Download.StreamQuality)
+		rows := []string{helpStyle.Render(fmt.Sprintf("  %-5s  %-10s  %9s  %9s  %-8s  %s", "Itag", "Codec", "Bitrate", "Rate", "Channels", "Size"))}
+		for i := range m.streamFormats {
+			f := &m.streamFormats[i]
+			info := formatAudioInfo(f)
+			bitrate, rate := "?", "?"
+			if info.bitrate > 0 {
+				bitrate = strconv.Itoa(info.bitrate/1000) + " kbps"
+			}
+			if info.sampleRate > 0 {
+				rate = fmt.Sprintf("%.1f kHz", float64(info.sampleRate)/1000)
+			}
+			row := fmt.Sprintf("%-5d  %-10s  %9s  %9s  %-8s  %s", f.ItagNo, info.codec, bitrate, rate, info.channels, streamSize(f, m.streamDuration))
+			if f.AudioTrack != nil {
+				row += "  " + f.AudioTrack.DisplayName
+			}
+			if f.ItagNo == def.ItagNo {
+				row += helpStyle.Render("  (default)")
+			}
+			if i == m.streamCursor {
+				rows = append(rows, "> "+statusStyle.Render(row))
+			} else {
+				rows = append(rows, "  "+row)
+			}
+		}
+		body = strings.Join(rows, "\n  ")
+	}
+
+	return fmt.Sprintf("\n  %s\n  %s\n\n  %s\n\n  %s\n",
+		titleStyle.Render("Pick Audio Stream"),
+		helpStyle.Render(m.streamItem.title+" - "+m.streamItem.author),
+		body,
+		helpStyle.Render("↑/↓: Select  •  ENTER: Download  •  ESC: Cancel"),
+	)
+}
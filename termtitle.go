@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Update handles msg and then keeps the terminal title and status file in
+// step with playback
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	if nm, ok := next.(model); ok {
+		nm.writeStatus()
+		if title := nm.syncTerminalTitle(); title != nil {
+			cmd = tea.Batch(cmd, title)
+		}
+	}
+	return next, cmd
+}
+
+// nowPlayingTitle is the terminal title for the current track, empty when idle
+func (m model) nowPlayingTitle() string {
+	if !cfg.Playback.TerminalTitle || m.playback.playingSong == "" {
+		return ""
+	}
+	if m.selected.author == "" {
+		return "♪ " + m.selected.title
+	}
+	return fmt.Sprintf("♪ %s – %s", m.selected.author, m.selected.title)
+}
+
+// syncTerminalTitle sets the window title (OSC 2) when the track changes and
+// clears it when playback stops, so tmux and window managers can show it
+func (m model) syncTerminalTitle() tea.Cmd {
+	title := m.nowPlayingTitle()
+	if title == m.playback.windowTitle {
+		return nil
+	}
+	m.playback.windowTitle = title
+	return tea.SetWindowTitle(title)
+}
+
+// clearTerminalTitle resets a title left behind when gomusic quits mid-track
+func clearTerminalTitle(w io.Writer, m *model) {
+	if m.playback.windowTitle != "" {
+		fmt.Fprint(w, "\x1b]2;\x07")
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// rgb is a color as 0-255 channels
+type rgb [3]float64
+
+// hex formats the color for lipgloss
+func (c rgb) hex() lipgloss.Color {
+	return lipgloss.Color(fmt.Sprintf("#%02X%02X%02X", int(c[0]), int(c[1]), int(c[2])))
+}
+
+// luma is the perceived brightness of the color, 0 to 1
+func (c rgb) luma() float64 {
+	return (0.299*c[0] + 0.587*c[1] + 0.114*c[2]) / 255
+}
+
+// lighten mixes the color with white until it is at least as bright as luma
+func (c rgb) lighten(luma float64) rgb {
+	l := c.luma()
+	if l >= luma {
+		return c
+	}
+	t := (luma - l) / (1 - l)
+	return rgb{c[0] + (255-c[0])*t, c[1] + (255-c[1])*t, c[2] + (255-c[2])*t}
+}
+
+// darken scales the color down until it is at most as bright as luma
+func (c rgb) darken(luma float64) rgb {
+	l := c.luma()
+	if l <= luma {
+		return c
+	}
+	f := luma / l
+	return rgb{c[0] * f, c[1] * f, c[2] * f}
+}
+
+// scale multiplies every channel by f
+func (c rgb) scale(f float64) rgb {
+	return rgb{c[0] * f, c[1] * f, c[2] * f}
+}
+
+// mix returns the color t of the way from c to d
+func (c rgb) mix(d rgb, t float64) rgb {
+	return rgb{c[0] + (d[0]-c[0])*t, c[1] + (d[1]-c[1])*t, c[2] + (d[2]-c[2])*t}
+}
+
+// distance is how far apart two colors are
+func (c rgb) distance(d rgb) float64 {
+	return math.Sqrt((c[0]-d[0])*(c[0]-d[0]) + (c[1]-d[1])*(c[1]-d[1]) + (c[2]-d[2])*(c[2]-d[2]))
+}
+
+// parseHex reads a "#RRGGBB" color
+func parseHex(s string) (rgb, error) {
+	n, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(s) != 7 || s[0] != '#' {
+		return rgb{}, fmt.Errorf("invalid color %q, use #RRGGBB", s)
+	}
+	return rgb{float64(n >> 16), float64(n >> 8 & 0xFF), float64(n & 0xFF)}, nil
+}
+
+// themeConfig overrides the colors of the interface
+type themeConfig struct {
+	Title     string `toml:"title,omitempty"`     // Title background, "#F456D3" by default; "#RRGGBB" or an ANSI color number
+	Status    string `toml:"status,omitempty"`    // Status and selection text, "#04B575"
+	Error     string `toml:"error,omitempty"`     // Errors, "#EF4444"
+	Help      string `toml:"help,omitempty"`      // Help lines and secondary text, "#626262"
+	Highlight string `toml:"highlight,omitempty"` // Current lyric and played progress, "#00FFFF"; "#RRGGBB" only
+}
+
+func (c themeConfig) validate() error {
+	for _, color := range []struct{ name, value string }{
+		{"title", c.Title}, {"status", c.Status}, {"error", c.Error}, {"help", c.Help},
+	} {
+		if color.value == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(color.value); err == nil && n >= 0 && n <= 255 {
+			continue
+		}
+		if _, err := parseHex(color.value); err != nil {
+			return fmt.Errorf("theme: %s: invalid color %q, use #RRGGBB or an ANSI color number 0 to 255", color.name, color.value)
+		}
+	}
+	if c.Highlight != "" {
+		if _, err := parseHex(c.Highlight); err != nil {
+			return fmt.Errorf("theme: highlight: %v", err)
+		}
+	}
+	return nil
+}
+
+// defaultHighlight is the current lyric's color without a cover theme, see applyTheme
+var defaultHighlight = rgb{0, 0xFF, 0xFF}
+
+// applyTheme puts the theme colors into effect
+func applyTheme(c themeConfig) {
+	color := func(value, def string) lipgloss.Color {
+		if value == "" {
+			return lipgloss.Color(def)
+		}
+		return lipgloss.Color(value)
+	}
+	titleStyle = titleStyle.Background(color(c.Title, "#F456D3"))
+	statusStyle = statusStyle.Foreground(color(c.Status, "#04B575"))
+	errorStyle = errorStyle.Foreground(color(c.Error, "#EF4444"))
+	helpStyle = helpStyle.Foreground(color(c.Help, "#626262"))
+	defaultHighlight = rgb{0, 0xFF, 0xFF}
+	if h, err := parseHex(c.Highlight); err == nil {
+		defaultHighlight = h
+	}
+}
+
+// coverTheme holds the accent colors of the playing screen taken from the
+// track's cover
+type coverTheme struct {
+	title     rgb // Title background, dark enough for white text
+	highlight rgb // Current lyric, bright enough to read on a dark terminal
+	from, to  rgb // Ends of the progress gradient
+}
+
+// coverThemeMsg delivers the theme of the cover of the track with key
+type coverThemeMsg struct {
+	key   string
+	theme *coverTheme
+}
+
+// loadCoverTheme works out the theme of the cover at coverPath off the UI goroutine
+func loadCoverTheme(key, coverPath string) tea.Cmd {
+	if !cfg.Playback.CoverTheme {
+		return nil
+	}
+	return func() tea.Msg {
+		f, err := os.Open(coverPath)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		img, _, err := image.Decode(f)
+		if err != nil {
+			return nil
+		}
+		primary, secondary := dominantColors(img)
+		return coverThemeMsg{key: key, theme: &coverTheme{
+			title:     primary.darken(0.35),
+			highlight: primary.lighten(0.65),
+			from:      primary.lighten(0.5),
+			to:        secondary.lighten(0.5),
+		}}
+	}
+}
+
+// dominantColors returns the most common color of img, favouring saturated
+// ones over greys, and the most common one clearly different from it
+func dominantColors(img image.Image) (primary, secondary rgb) {
+	type bucket struct {
+		weight float64
+		sum    rgb
+	}
+	buckets := map[[3]int]*bucket{}
+	b := img.Bounds()
+	step := max(1, max(b.Dx(), b.Dy())/64) // Sampling a 64x64 grid is plenty
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			c := rgb{float64(r >> 8), float64(g >> 8), float64(bl >> 8)}
+			hi := math.Max(c[0], math.Max(c[1], c[2]))
+			lo := math.Min(c[0], math.Min(c[1], c[2]))
+			weight := 1 + 4*(hi-lo)/255 // Saturation
+			if hi < 40 || lo > 220 {
+				weight = 0.1 // Near black or white says little about the cover
+			}
+			key := [3]int{int(c[0]) >> 5, int(c[1]) >> 5, int(c[2]) >> 5}
+			bk := buckets[key]
+			if bk == nil {
+				bk = &bucket{}
+				buckets[key] = bk
+			}
+			bk.weight += weight
+			for i := range c {
+				bk.sum[i] += c[i] * weight
+			}
+		}
+	}
+
+	average := func(bk *bucket) rgb { return bk.sum.scale(1 / bk.weight) }
+	var best *bucket
+	for _, bk := range buckets {
+		if best == nil || bk.weight > best.weight {
+			best = bk
+		}
+	}
+	if best == nil {
+		return defaultHighlight, defaultHighlight.lighten(0.8)
+	}
+	primary = average(best)
+	var second *bucket
+	for _, bk := range buckets {
+		if average(bk).distance(primary) > 100 && (second == nil || bk.weight > second.weight) {
+			second = bk
+		}
+	}
+	if second == nil {
+		return primary, primary.lighten(0.8)
+	}
+	return primary, average(second)
+}
+
+// applyCoverTheme takes the theme if it belongs to the current track
+func (m *model) applyCoverTheme(msg coverThemeMsg) {
+	if msg.key == m.playback.trackKey {
+		m.playback.theme = msg.theme
+	}
+}
+
+// nowPlayingStyle is the title style of the playing screen
+func (m *model) nowPlayingStyle() lipgloss.Style {
+	if t := m.playback.theme; t != nil {
+		return titleStyle.Background(t.title.hex())
+	}
+	return titleStyle
+}
+
+// renderGradient draws s in the progress gradient of the theme, or in the
+// default progress color without one
+func (m *model) renderGradient(s string) string {
+	t := m.playback.theme
+	if t == nil {
+		return lipgloss.NewStyle().Foreground(defaultHighlight.hex()).Render(s)
+	}
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		f := 0.0
+		if len(runes) > 1 {
+			f = float64(i) / float64(len(runes)-1)
+		}
+		b.WriteString(lipgloss.NewStyle().Foreground(t.from.mix(t.to, f).hex()).Render(string(r)))
+	}
+	return b.String()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// titleNoise lists the words uploaders add to song titles, by language.
+// cleanString strips them before lyric lookups and result matching.
+var titleNoise = map[string][]string{
+	"en": {
+		"official music video", "official video", "official audio",
+		"music video", "lyric video", "lyrics", "official",
+		"video", "audio", "full song", "hd", "4k", "720p", "1080p",
+	},
+	"es": {"videoclip oficial", "video oficial", "audio oficial", "vídeo oficial", "video con letra", "con letra", "letra", "oficial"},
+	"pt": {"clipe oficial", "vídeo oficial", "áudio oficial", "videoclipe", "com letra", "letra"},
+	"fr": {"clip officiel", "vidéo officielle", "audio officiel", "paroles", "officielle", "officiel"},
+	"de": {"offizielles musikvideo", "offizielles video", "offizielles audio", "songtext", "offiziell"},
+	"it": {"video ufficiale", "audio ufficiale", "videoclip ufficiale", "testo", "ufficiale"},
+	"ru": {"официальный клип", "официальное видео", "премьера клипа", "клип", "текст песни"},
+	"ja": {"ミュージックビデオ", "オフィシャル", "公式", "歌詞付き", "歌詞", "MV", "PV"},
+	"ko": {"뮤직비디오", "공식", "가사", "M/V", "MV"},
+	"zh": {"官方MV", "官方版", "官方", "动态歌词", "歌词版", "歌词", "MV"},
+}
+
+// titleCleaningConfig picks the languages whose patterns cleanString uses
+// and adds the user's own
+type titleCleaningConfig struct {
+	Languages []string `toml:"languages,omitempty"` // Language codes from titleNoise, empty for English only
+	Extra     []string `toml:"extra,omitempty"`     // Further phrases to strip, in any language
+}
+
+// noisePatterns is the compiled form of the active cleaning rules
+var noisePatterns = compileTitleNoise(titleCleaningConfig{})
+
+func (c titleCleaningConfig) validate() error {
+	for _, lang := range c.Languages {
+		if _, ok := titleNoise[lang]; !ok {
+			return fmt.Errorf("title_cleaning: unknown language %q", lang)
+		}
+	}
+	return nil
+}
+
+// defaultTitleLanguages are the languages cleaned when none are configured.
+// The others are opt-in, as their words ("letra", "testo", "clip") are often
+// part of real titles.
+var defaultTitleLanguages = []string{"en"}
+
+// compileTitleNoise builds one matcher per phrase, longest first so
+// "official video" goes before "official" can split it. Phrases are matched
+// case-insensitively and, where they start or end in a Latin letter or digit,
+// only as whole words so "hd" leaves "Shdw" alone.
+func compileTitleNoise(c titleCleaningConfig) []*regexp.Regexp {
+	languages := c.Languages
+	if len(languages) == 0 {
+		languages = defaultTitleLanguages
+	}
+	seen := map[string]bool{}
+	var phrases []string
+	for _, lang := range languages {
+		for _, p := range titleNoise[lang] {
+			if !seen[strings.ToLower(p)] {
+				seen[strings.ToLower(p)] = true
+				phrases = append(phrases, p)
+			}
+		}
+	}
+	for _, p := range c.Extra {
+		if p = strings.TrimSpace(p); p != "" && !seen[strings.ToLower(p)] {
+			seen[strings.ToLower(p)] = true
+			phrases = append(phrases, p)
+		}
+	}
+	sort.SliceStable(phrases, func(i, j int) bool {
+		return len([]rune(phrases[i])) > len([]rune(phrases[j]))
+	})
+
+	var patterns []*regexp.Regexp
+	for _, p := range phrases {
+		expr := regexp.QuoteMeta(p)
+		runes := []rune(p)
+		if isASCIIWordRune(runes[0]) {
+			expr = `\b` + expr
+		}
+		if isASCIIWordRune(runes[len(runes)-1]) {
+			expr += `\b`
+		}
+		patterns = append(patterns, regexp.MustCompile("(?i)"+expr))
+	}
+	return patterns
+}
+
+func isASCIIWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// applyTitleCleaning switches cleanString to the configured rules
+func applyTitleCleaning(c titleCleaningConfig) {
+	noisePatterns = compileTitleNoise(c)
+}
+
+// stripTitleNoise removes the configured noise phrases from s
+func stripTitleNoise(s string) string {
+	for _, re := range noisePatterns {
+		s = re.ReplaceAllString(s, "")
+	}
+	return s
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/raitonoberu/ytmusic"
+)
+
+// radioTracksMsg delivers the radio playlist started from the track with seed
+type radioTracksMsg struct {
+	seed   string
+	tracks []songItem
+	err    error
+}
+
+// endsWithMenu reports whether the track that just ended gets the follow-up
+// menu: a single streamed track, with nothing queued after it
+func (m *model) endsWithMenu() bool {
+	return m.state == statePlaying && m.queue.len() < 2 && m.selected.path == "" && m.selected.id != ""
+}
+
+// nextResult returns the playable search result after the ended track and its index
+func (m *model) nextResult() (songItem, int, bool) {
+	items := m.list.Items()
+	for i, it := range items {
+		if item, ok := it.(songItem); !ok || item.id != m.selected.id {
+			continue
+		}
+		for j := i + 1; j < len(items); j++ {
+			if next, ok := items[j].(songItem); ok && !next.isAlbum && len(next.id) >= 10 {
+				return next, j, true
+			}
+		}
+		break
+	}
+	return songItem{}, 0, false
+}
+
+// fetchRadio looks up the YouTube Music radio playlist of seed, minus seed itself
+func fetchRadio(ctx context.Context, seed string) tea.Cmd {
+	return func() tea.Msg {
+		radio, err := withContext(ctx, func() ([]*ytmusic.TrackItem, error) {
+			return ytmusic.GetWatchPlaylist(seed)
+		})
+		msg := radioTracksMsg{seed: seed, err: err}
+		for _, t := range radio {
+			if item := convertYTMusicTrack(t); item.id != "" && item.id != seed {
+				msg.tracks = append(msg.tracks, item)
+			}
+		}
+		if err == nil && len(msg.tracks) == 0 {
+			msg.err = fmt.Errorf("no radio tracks found")
+		}
+		return msg
+	}
+}
+
+// applyRadio queues and starts the radio playlist if the menu is still waiting for it
+func (m *model) applyRadio(msg radioTracksMsg) tea.Cmd {
+	if m.state != stateTrackEnded || !m.radioLoading || msg.seed != m.selected.id {
+		return nil
+	}
+	m.radioLoading = false
+	if msg.err != nil {
+		m.notice = "Couldn't start radio: " + msg.err.Error()
+		return nil
+	}
+	m.queue.set(msg.tracks, 0)
+	return m.startPlayback(msg.tracks[0])
+}
+
+func (m model) updateTrackEnded(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.notice = ""
+	switch msg.String() {
+	case "r":
+		m.radioLoading = false
+		m.queue.set([]songItem{m.selected}, 0)
+		return m, m.startPlayback(m.selected)
+	case "d":
+		if !m.requireNetwork("downloading") {
+			return m, nil
+		}
+		m.radioLoading = false
+		return m, m.startDownload(m.selected)
+	case "n":
+		next, index, ok := m.nextResult()
+		if !ok {
+			m.notice = "No more results after this track"
+			return m, nil
+		}
+		m.radioLoading = false
+		m.list.Select(index)
+		m.queue.set([]songItem{next}, 0)
+		return m, m.startPlayback(next)
+	case "a":
+		if m.radioLoading || !m.requireNetwork("starting radio") {
+			return m, nil
+		}
+		m.radioLoading = true
+		return m, tea.Batch(m.spinner.Tick, fetchRadio(searchJob.start(), m.selected.id))
+	case "esc", "enter":
+		searchJob.stop()
+		m.radioLoading = false
+		m.browseBack()
+	case "q":
+		m.quitting = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m model) viewTrackEnded() string {
+	options := "R: Replay  •  D: Download  •  "
+	if _, _, ok := m.nextResult(); ok {
+		options += "N: Next Result  •  "
+	}
+	options += "A: Radio  •  ESC: Back to List"
+	status := ""
+	if m.radioLoading {
+		status = "\n\n  " + m.spinner.View() + " Starting radio..."
+	}
+	return fmt.Sprintf("\n  %s\n  %s%s\n\n  %s%s\n",
+		titleStyle.Render("Finished playing"),
+		statusStyle.Render(m.selected.title+" - "+m.selected.author),
+		status,
+		helpStyle.Render(options),
+		m.renderNotice(),
+	)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// tracklistStamp finds the times of a tracklist pasted on one line, since the
+// options prompt turns its line breaks into spaces
+var tracklistStamp = regexp.MustCompile(`(?:^|\s)[\[(]?(?:\d{1,2}:)?\d{1,2}:\d{2}[\])]?(?:\s|$)`)
+
+// tracklistNumber is the "2." numbering the next song of a one-line tracklist
+// leaves at the end of the title before it
+var tracklistNumber = regexp.MustCompile(`\s+\d+[.)]\s*$`)
+
+// parseTracklist reads a tracklist pasted into the download options, like
+// "00:00 Song A / 03:45 Song B" or one "Song A - 0:00" per line. The songs
+// must be in order; audio before the first one is left out.
+func parseTracklist(text string) ([]chapter, error) {
+	var entries []string
+	if strings.Contains(text, "\n") || strings.Contains(text, " / ") {
+		entries = strings.FieldsFunc(strings.ReplaceAll(text, " / ", "\n"), func(r rune) bool { return r == '\n' })
+	} else {
+		stamps := tracklistStamp.FindAllStringIndex(text, -1)
+		for i, s := range stamps {
+			end := len(text)
+			if i+1 < len(stamps) {
+				end = stamps[i+1][0]
+			}
+			entries = append(entries, tracklistNumber.ReplaceAllString(text[s[0]:end], ""))
+		}
+	}
+
+	var tracks []chapter
+	for _, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		c, ok := parseChapterLine(entry)
+		if !ok {
+			return nil, fmt.Errorf("tracklist: no time in %q", strings.TrimSpace(entry))
+		}
+		if c.title == "" {
+			c.title = fmt.Sprintf("Track %d", len(tracks)+1)
+		}
+		if len(tracks) > 0 && c.start <= tracks[len(tracks)-1].start {
+			return nil, fmt.Errorf("tracklist: %s (%s) doesn't start after the song before it", c.title, formatDuration(c.start))
+		}
+		tracks = append(tracks, c)
+	}
+	if len(tracks) < 2 {
+		return nil, fmt.Errorf("tracklist needs at least two timed songs, like 0:00 Song A / 3:45 Song B")
+	}
+	return tracks, nil
+}
+
+// splitTracklist saves every song of the tracklist as a tagged track of its
+// own, in a folder named after the video, instead of one file for the video
+func (m *model) splitTracklist(ctx context.Context, opts downloadOptions, video *youtube.Video, tempAudio, tempThumb string, hasCover bool) {
+	albumName := video.Title
+	if opts.fileName != "" {
+		albumName = opts.fileName
+	}
+	dir := opts.folder
+	if dir == "" {
+		dir = libraryDir()
+	}
+	dir = safeJoin(dir, albumName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		m.downloadFailed(err)
+		return
+	}
+
+	tracks, total := opts.tracklist, video.Duration
+	// The songs come from the [metadata] chain like album tracks: the shared
+	// tags once, through the first song, the title and artist per song
+	song := func(i int) songItem {
+		end := total
+		if i+1 < len(tracks) {
+			end = tracks[i+1].start
+		}
+		return songItem{id: m.selected.id, title: tracks[i].title, author: video.Author, duration: int((end - tracks[i].start).Seconds())}
+	}
+	albumBase := trackMetadata{Album: albumName, Year: releaseYear(m.selected.year, video), Art: m.selected.thumb}
+	if !hasCover {
+		albumBase.Art = ""
+	}
+	album, cover := m.resolveAlbumMetadata(ctx, song(0), albumBase, tempThumb)
+	hasCover = album.Art != ""
+	var saved int
+	var failed []string
+	for i, t := range tracks {
+		end := total
+		if i+1 < len(tracks) {
+			end = tracks[i+1].start
+		}
+		meta := resolveMetadata(ctx, song(i), trackMetadata{Title: t.title, Artist: video.Author}, trackFields)
+		path := safeJoin(dir, fmt.Sprintf("%02d - %s%s", i+1, t.title, opts.container().ext))
+		args := []string{"-y",
+			"-ss", fmt.Sprintf("%.3f", t.start.Seconds()),
+			"-t", fmt.Sprintf("%.3f", (end - t.start).Seconds()),
+			"-i", tempAudio,
+		}
+		if hasCover {
+			args = append(args, "-i", cover)
+		}
+		args = append(args, opts.mapArgs(hasCover)...)
+		args = append(args, opts.codecArgs()...)
+		args = append(args,
+			"-metadata", "title="+meta.Title,
+			"-metadata", "artist="+meta.Artist,
+			"-metadata", "album="+album.Album,
+			"-metadata", "album_artist="+video.Author,
+			"-metadata", fmt.Sprintf("track=%d/%d", i+1, len(tracks)),
+		)
+		args = append(args, sourceTagArgs(m.selected.id)...)
+		if album.Year != "" {
+			args = append(args, "-metadata", "date="+album.Year)
+		}
+		args = append(args, path)
+
+		if err := exec.CommandContext(ctx, "ffmpeg", args...).Run(); err != nil {
+			if downloadCancelled(ctx, m.selected.id, tempAudio, path) {
+				return
+			}
+			failed = append(failed, fmt.Sprintf("%02d - %s (%v)", i+1, t.title, err))
+			continue
+		}
+		if err := tagSourceURL(path, m.selected.id); err != nil {
+			fmt.Fprintf(os.Stderr, "Error tagging source URL: %v\n", err)
+		}
+		if err := verifyAudio(path, end-t.start); err != nil {
+			failed = append(failed, fmt.Sprintf("%02d - %s (%v)", i+1, t.title, err))
+			continue
+		}
+		saved++
+	}
+	if saved == 0 {
+		m.downloadFailed(fmt.Errorf("splitting the tracklist failed: %s", strings.Join(failed, "; ")))
+		return
+	}
+
+	os.Remove(tempAudio)
+	removePausedDownload(m.selected.id)
+	session.trackDone()
+	recordDownload(m.selected.id)
+	summary := fmt.Sprintf("%d tracks split from the tracklist", saved)
+	if len(failed) > 0 {
+		summary += fmt.Sprintf("\n  %s %d track(s) failed:\n    %s",
+			errorStyle.Render("Warning:"), len(failed), strings.Join(failed, "\n    "))
+	}
+	m.program.Send(doneMsg{path: dir, detail: summary})
+}
+
+// tracklistEnd checks that every song of the tracklist starts before the end
+// of a video of length total
+func tracklistEnd(tracks []chapter, total time.Duration) error {
+	if last := tracks[len(tracks)-1]; total > 0 && last.start >= total {
+		return fmt.Errorf("tracklist: %s starts at %s, after the end of the video (%s)",
+			last.title, formatDuration(last.start), formatDuration(total))
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// transferConfig names the device downloads are sent to
+type transferConfig struct {
+	Target     string `toml:"target,omitempty"`      // Mounted device folder (MTP, SD card) or an rsync destination like "host:Music"
+	OnConflict string `toml:"on_conflict,omitempty"` // "skip" (default), "overwrite" or "rename" when the target already has a file
+}
+
+func (c transferConfig) validate() error {
+	switch c.OnConflict {
+	case "", "skip", "overwrite", "rename":
+	default:
+		return fmt.Errorf("transfer: on_conflict must be skip, overwrite or rename, got %q", c.OnConflict)
+	}
+	if c.OnConflict == "rename" && c.remote() {
+		return fmt.Errorf("transfer: on_conflict = rename needs a mounted target, rsync can only skip or overwrite")
+	}
+	return nil
+}
+
+// remote reports whether the target is an rsync destination such as
+// "phone:Music" or "user@host:/music" rather than a local folder
+func (c transferConfig) remote() bool {
+	host, _, found := strings.Cut(c.Target, ":")
+	return found && host != "" && !strings.ContainsAny(host, `/\`) && filepath.VolumeName(c.Target) == ""
+}
+
+var transferJob jobScope // Copies of downloads to the configured device
+
+// transferProgressMsg reports how far sending name to the device has got
+type transferProgressMsg struct {
+	name     string
+	fraction float64
+}
+
+// transferDoneMsg reports the end of sending name to the device
+type transferDoneMsg struct {
+	name   string
+	result transferResult
+	err    error
+}
+
+// transferResult counts what happened to each file sent
+type transferResult struct {
+	copied, skipped, renamed int
+}
+
+func (r transferResult) String() string {
+	s := fmt.Sprintf("%d copied", r.copied)
+	if r.skipped > 0 {
+		s += fmt.Sprintf(", %d already there", r.skipped)
+	}
+	if r.renamed > 0 {
+		s += fmt.Sprintf(", %d renamed", r.renamed)
+	}
+	return s
+}
+
+// sendToDevice copies the file or folder at path to the configured target in
+// the background, reporting progress as it goes
+func sendToDevice(ctx context.Context, sender msgSender, path string) {
+	name := filepath.Base(path)
+	lastPct := -1
+	report := func(fraction float64) {
+		// Only whole percent steps, so big copies don't flood the UI
+		if pct := int(fraction * 100); pct != lastPct {
+			lastPct = pct
+			sender.Send(transferProgressMsg{name: name, fraction: fraction})
+		}
+	}
+	var result transferResult
+	var err error
+	if cfg.Transfer.remote() {
+		err = rsyncTo(ctx, path, cfg.Transfer, report)
+	} else {
+		result, err = copyTo(ctx, path, cfg.Transfer, report)
+	}
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	sender.Send(transferDoneMsg{name: name, result: result, err: err})
+}
+
+// copyTo copies path into the target folder, keeping a folder's layout
+func copyTo(ctx context.Context, path string, c transferConfig, report func(float64)) (transferResult, error) {
+	var result transferResult
+	if _, err := os.Stat(c.Target); err != nil {
+		return result, fmt.Errorf("target %s is not available, is the device mounted? (%v)", c.Target, err)
+	}
+
+	type file struct {
+		src, dst string
+		size     int64
+	}
+	var files []file
+	var total int64
+	base := filepath.Dir(filepath.Clean(path))
+	err := filepath.WalkDir(path, func(src string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, src)
+		if err != nil {
+			return err
+		}
+		files = append(files, file{src, filepath.Join(c.Target, rel), info.Size()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	var done int64
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		dst := f.dst
+		if _, err := os.Stat(dst); err == nil {
+			switch c.OnConflict {
+			case "overwrite":
+			case "rename":
+				dst = freeName(dst)
+				result.renamed++
+			default:
+				result.skipped++
+				done += f.size
+				report(float64(done) / float64(max(total, 1)))
+				continue
+			}
+		}
+		err := copyFile(ctx, f.src, dst, func(n int64) {
+			report(float64(done+n) / float64(max(total, 1)))
+		})
+		if err != nil {
+			return result, err
+		}
+		done += f.size
+		result.copied++
+	}
+	report(1)
+	return result, nil
+}
+
+// freeName returns path with " (2)", " (3)"... added before the extension,
+// whichever isn't taken yet
+func freeName(path string) string {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", stem, i, ext)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// copyFile copies src to dst through a partial file, so an unplugged device
+// never keeps a truncated track under its real name
+func copyFile(ctx context.Context, src, dst string, progress func(written int64)) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	buf := make([]byte, 256*1024)
+	var written int64
+	for {
+		if err := ctx.Err(); err != nil {
+			out.Close()
+			return err
+		}
+		n, rerr := in.Read(buf)
+		if n > 0 {
+			if _, err := out.Write(buf[:n]); err != nil {
+				out.Close()
+				return err
+			}
+			written += int64(n)
+			progress(written)
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			out.Close()
+			return rerr
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// rsyncTo sends path to an rsync destination, over ssh for remote hosts
+func rsyncTo(ctx context.Context, path string, c transferConfig, report func(float64)) error {
+	args := []string{"-rt", "--partial", "--info=progress2", "--no-inc-recursive"}
+	if c.OnConflict != "overwrite" {
+		args = append(args, "--ignore-existing")
+	}
+	args = append(args, filepath.Clean(path), c.Target)
+	cmd := exec.CommandContext(ctx, "rsync", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("rsync: %v", err)
+	}
+
+	// progress2 rewrites one line with \r: "  1,234,567  42%  1.2MB/s  0:00:03"
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(scanCR)
+	for scanner.Scan() {
+		for _, field := range strings.Fields(scanner.Text()) {
+			if pct, ok := strings.CutSuffix(field, "%"); ok {
+				if n, err := strconv.Atoi(pct); err == nil {
+					report(float64(n) / 100)
+				}
+			}
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("rsync: %s", msg)
+		}
+		return fmt.Errorf("rsync: %v", err)
+	}
+	return nil
+}
+
+// scanCR splits output on both \r and \n
+func scanCR(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// startTransfer sends path to the device from the TUI
+func (m *model) startTransfer(path string) {
+	if cfg.Transfer.Target == "" {
+		m.transferStatus = "Set transfer.target in the settings (Ctrl+T) to send to a device"
+		return
+	}
+	m.transferStatus = fmt.Sprintf("Sending %s to %s...", filepath.Base(path), cfg.Transfer.Target)
+	go sendToDevice(transferJob.start(), m.program, path)
+}
+
+// applyTransfer shows the progress or outcome of a transfer
+func (m *model) applyTransfer(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case transferProgressMsg:
+		m.transferStatus = fmt.Sprintf("Sending %s to %s... %d%%", msg.name, cfg.Transfer.Target, int(msg.fraction*100))
+	case transferDoneMsg:
+		switch {
+		case msg.err != nil:
+			m.transferStatus = fmt.Sprintf("Sending %s failed: %v", msg.name, msg.err)
+		case cfg.Transfer.remote():
+			m.transferStatus = fmt.Sprintf("Sent %s to %s", msg.name, cfg.Transfer.Target)
+		default:
+			m.transferStatus = fmt.Sprintf("Sent %s to %s (%s)", msg.name, cfg.Transfer.Target, msg.result)
+		}
+	}
+}
+
+// runSend implements `gomusic send PATH...`: copy downloaded files or album
+// folders to the configured device
+func runSend(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: gomusic send FILE_OR_ALBUM_FOLDER...")
+		return 2
+	}
+	if cfg.Transfer.Target == "" {
+		fmt.Fprintln(os.Stderr, "No device configured: set target in the [transfer] section of config.toml")
+		return 2
+	}
+	release, ok := claimCommand("send")
+	if !ok {
+		return 1
+	}
+	defer release()
+	status := 0
+	for _, path := range args {
+		r := &transferReporter{lastPct: -1}
+		sendToDevice(appCtx, r, path)
+		if r.err != nil {
+			fmt.Fprintf(os.Stderr, "\r%s: %v\n", path, r.err)
+			status = 1
+			continue
+		}
+		if cfg.Transfer.remote() {
+			fmt.Fprintf(os.Stderr, "\r%s: sent        \n", path)
+		} else {
+			fmt.Fprintf(os.Stderr, "\r%s: %s        \n", path, r.result)
+		}
+	}
+	return status
+}
+
+// transferReporter prints transfer progress to stderr for `gomusic send`
+type transferReporter struct {
+	lastPct int
+	result  transferResult
+	err     error
+}
+
+func (r *transferReporter) Send(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case transferProgressMsg:
+		if pct := int(msg.fraction * 100); pct != r.lastPct {
+			fmt.Fprintf(os.Stderr, "\rSending %s... %3d%%", msg.name, pct)
+			r.lastPct = pct
+		}
+	case transferDoneMsg:
+		r.result, r.err = msg.result, msg.err
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kkdai/youtube/v2"
 )
 
 // --- Types ---
@@ -28,6 +29,20 @@ const (
 	stateError
 	stateDownloadingAlbum
 	stateViewingAlbumTracks
+	stateLyricsSearch
+	stateLyricsResults
+	stateVerifyFailed
+	stateStats
+	stateLibrary
+	stateDownloadOptions
+	stateRelatedAlbums
+	stateFilteredTracks
+	stateSettings
+	stateConfirmAlbum
+	stateLibrarySearch
+	stateTrackEnded
+	statePickStream
+	stateFillGaps
 )
 
 type LyricLine struct {
@@ -47,16 +62,27 @@ type songItem struct {
 	id         string
 	title      string
 	author     string
+	album      string
+	year       string // Release year, when known
+	category   string // Release type for albums (Album, EP, Single)
 	thumb      string
 	lyrics     []LyricLine
 	isAlbum    bool
 	trackCount int // For albums, number of tracks
+	duration   int    // Track length in seconds, 0 if unknown
+	path       string // Local file for library playback, empty for YouTube tracks
+	artistIDs  []string // YT Music channel IDs of the artists, when known
+	albumID    string   // YT Music browse ID of the track's album, when known
+	video      bool     // A video upload rather than a YouTube Music song entry
 }
 
 func (i songItem) Title() string {
 	if i.isAlbum {
 		return "📀 " + i.title
 	}
+	if i.owned() {
+		return i.title + " ✓"
+	}
 	// For tree view, check if title already has indentation
 	if strings.HasPrefix(i.title, "  ") || strings.HasPrefix(i.title, "│  ") {
 		return i.title
@@ -66,25 +92,64 @@ func (i songItem) Title() string {
 func (i songItem) Description() string {
 	if i.isAlbum {
 		if i.trackCount > 0 {
-			return fmt.Sprintf("%s (Album • %d tracks)", i.author, i.trackCount)
+			return fmt.Sprintf("%s (Album • %d tracks)%s", i.author, i.trackCount, renderBadges(i.badges()))
 		}
-		return i.author + " (Album)"
+		return i.author + " (Album)" + renderBadges(i.badges())
 	}
-	return i.author
+	return i.author + renderBadges(i.badges())
 }
 func (i songItem) FilterValue() string { return i.title }
 
+// isPlaylist reports whether the item is a playlist, which is listed and
+// downloaded like an album but whose tracks keep their own albums
+func (i songItem) isPlaylist() bool { return i.isAlbum && i.category == "Playlist" }
+
+// lyricCandidate is an LRCLIB search result offered in the manual lyrics search
+type lyricCandidate struct {
+	result lrclibResponse
+}
+
+func (c lyricCandidate) Title() string {
+	return fmt.Sprintf("%s - %s", c.result.ArtistName, c.result.TrackName)
+}
+func (c lyricCandidate) Description() string {
+	return formatDuration(time.Duration(c.result.Duration * float64(time.Second)))
+}
+func (c lyricCandidate) FilterValue() string { return c.result.TrackName }
+
+// msgSender delivers messages from background work; *tea.Program in the TUI
+// and a plain console reporter in CLI mode
+type msgSender interface {
+	Send(msg tea.Msg)
+}
+
 type playbackState struct {
 	playingSong       string
 	isPaused          bool
-	player            any // *beep.Ctrl when !noplayback
-	cmd               any // *exec.Cmd to kill the stream
 	lyrics            []LyricLine
+	lyricsUnsynced    bool // lyrics have no timestamps and are shown without a highlight
 	currentLyricIndex int
-	albumCover        string // ASCII art representation of album cover
-	coverPath         string // Path to cached cover image
-	kittyImage        string // Kitty graphics protocol sequence for actual image
-	resizedCoverPath  string // Path to resized cover for Kitty display
+	albumCover        string        // ASCII art representation of album cover
+	artCols, artRows  int           // Size albumCover is drawn at, 0 before it is
+	coverPath         string        // Path to cached cover image
+	kittyImage        string        // Kitty graphics protocol sequence for actual image
+	resizedCoverPath  string        // Path to resized cover for Kitty display
+	health            *streamHealth // Buffer and reconnect stats, nil without a live stream
+	audioInfo         string        // Codec, bitrate, sample rate and channels of the source
+	exclusive         bool          // mpv plays the track bit-perfect at a fixed volume
+	position          time.Duration // Refreshed on every lyric tick
+	trackKey          string        // Track the state belongs to, see waveformKey
+	waveform          *waveform     // Peak envelope under the progress line, nil until analysed
+	pulse             float64       // Art and lyric brightness for the pulse effect, 0 before the first tick
+	windowTitle       string        // Terminal title last set for the playing track
+	ticking           bool          // A lyricTickMsg is scheduled
+	session           uint64        // Playback session of the current track, see newPlaybackSession
+	info              *trackInfo    // Info panel text, nil until looked up
+	infoErr           error         // Why the info lookup failed
+	infoKey           string        // Track the info lookup was started for
+	quietCap          float64       // Quiet hours cap the level was lowered to, 1 if it wasn't
+	level             int           // Volume of the current track in percent, see startVolume
+	theme             *coverTheme   // Accent colors from the cover, nil for the default look
 }
 
 type model struct {
@@ -95,35 +160,145 @@ type model struct {
 	spinner      spinner.Model
 	err          error
 	fileName     string
+	savedDetail  string // Track count and warnings shown under a finished album download
+	albumGaps    []albumGap // Tracks of the finished album download without a file
+	gapCursor    int
 	quitting     bool
 	width        int
 	height       int
 	selected     songItem
-	program      *tea.Program
-	searchFilter searchFilter // Current search filter
+	program      msgSender
+	searchFilter searchFilter   // Current search filter
+	lengthFilter bool           // Hide tracks outside the search.min_length to max_length range
+	showInfo     bool           // Show the track info panel instead of the lyrics while playing
+	radioLoading bool           // The track ended menu is waiting for a radio playlist
+	volume       volumeLevel    // Level of the volume keys, kept across runs
+	searchKey    searchKey      // Search the result list shows
+	searches     []cachedSearch // Recent result lists, most recent first
 
 	// Album download state
-	albumTracks   []songItem
+	albumTracks      []songItem
+	albumExcluded    map[string]bool // Track IDs switched off for the album download
+	confirmTracks    []songItem      // Tracks waiting on the album download confirmation
+	downloadTracks   []songItem      // Tracks of the album download being run, as confirmed
+	albumZip         bool            // Package the album download as a zip once it finishes
+	albumFormat      string          // Format chosen on the confirmation, empty for download.format
+	albumPassthrough bool            // Keep YouTube's Opus or AAC audio of album tracks as it is
+
+	transferStatus string // Progress or outcome of the last send to device
+	watchStatus    string // Outcome of the last import from a watch folder
 	albumProgress struct {
 		current int
 		total   int
 		title   string
 	}
+	// Download pause/resume state
+	download        *downloadControl
+	pausedDownloads []pausedDownload // Paused downloads available to resume
+	albumResume     *albumDownload   // Album download interrupted by a crash, nil if none
+	queueImport     string           // Queue file given with --queue, played on startup
+	handoff         *handoffRequest  // Track given with gomusic open, played or downloaded on startup
 	// Album viewing state
 	currentAlbum   songItem   // The album being viewed
 	albumTrackList list.Model // List of tracks in the album
 
+	// Manual lyrics search state
+	lyricsInput     textinput.Model
+	lyricsList      list.Model
+	lyricsSearching bool
+	lyricsErr       error
+
+	// Playback queue for auto-advance
+	queue playQueue
+
+	// Local library of downloaded albums
+	libraryList    list.Model
+	libraryLoading bool
+	libraryErr     error
+	libraryIndex   []libraryEntry  // Tracks of every album, built by the scan
+	librarySearch  textinput.Model // Query of the library track search
+	libraryMatches []libraryEntry
+	libraryCursor  int
+
+	// Download options prompt
+	optionInputs  []textinput.Model
+	optionsFocus  int
+	optionsItem   songItem
+	optionsReturn state // Screen to go back to on cancel
+	optionsErr    error
+	optionsStatus string
+	dlOptions     downloadOptions // Options for the download being run
+	cooldownUntil time.Time       // Set while album downloads wait out throttling
+
+	// Details pane of the highlighted search result, see browse.go
+	browseKey     string // ID of the result the pane is for
+	browseCover   string
+	browseTracks  []songItem
+	browseErr     error
+	browseLoading bool
+
+	// Audio stream picker
+	streamItem     songItem
+	streamReturn   state              // Screen to go back to on cancel
+	streamFormats  youtube.FormatList // Nil while loading
+	streamDuration time.Duration
+	streamCursor   int
+	streamErr      error
+
+	// Albums and artists pinned to the search screen, see pins.go
+	pins           []pin
+	pinsFocused    bool // The pin row has the keys instead of the text field
+	pinCursor      int
+	albumFromInput bool // The album view goes back to the search screen
+
+	// Related albums tabs of the album view
+	relatedTab     int
+	relatedLists   [relatedTabCount]list.Model
+	relatedFor     string // Album ID the tabs were loaded for
+	relatedLoading bool
+	relatedErr     error
+
+	// Tracks dropped by album deduplication, restorable from the review screen
+	filteredTracks []filteredTrack
+	filteredList   list.Model
+
+	// Settings screen
+	settingsCursor  int
+	settingsEditing bool
+	settingsInput   textinput.Model
+	settingsErr     error
+	settingsStatus  string
+
+	// One-line message for keys that could not run, cleared on the next key
+	notice string
+
 	// Shared playback state (pointer ensures updates are seen by all receivers)
 	playback *playbackState
 }
 
 // --- Messages ---
 
-type searchResultsMsg []songItem
+type searchResultsMsg struct {
+	key   searchKey
+	items []songItem
+}
 type errMsg error
 type downloadProgressMsg float64
+type downloadPausedMsg struct {
+	offset int64
+}
 type convertMsg struct{}
-type doneMsg string
+
+// doneMsg reports a finished download
+type doneMsg struct {
+	path   string // The saved file, or the album folder
+	detail string // Track count and warnings of an album download
+	gaps   []albumGap
+}
+type verifyFailedMsg struct {
+	fileName string
+	err      error
+}
 type metadataFetchedMsg struct {
 	id     string
 	title  string
@@ -133,17 +308,57 @@ type playMsg struct {
 	title  string
 	author string
 }
-type lyricsFetchedMsg []LyricLine
-type noLyricsMsg struct{}
+type lyricsFetchedMsg struct {
+	session  uint64 // Playback session the lyrics were looked up for
+	lines    []LyricLine
+	unsynced bool // The lines have no timestamps
+}
+type noLyricsMsg struct{ session uint64 }
 type lyricTickMsg time.Time
 type stopMsg struct{}
-type albumTracksFetchedMsg []songItem
+type albumTracksFetchedMsg struct {
+	tracks   []songItem
+	filtered []filteredTrack // Duplicates and alternate versions left out
+}
 type albumTrackProgressMsg struct {
 	current int
 	total   int
 	title   string
 }
 
-type imageReadyMsg struct {
-	imagePath string
+type lyricsCandidatesMsg struct {
+	results []lrclibResponse
+	err     error
+}
+type libraryScannedMsg struct {
+	albums []libraryAlbum
+	index  []libraryEntry // Every track, for the library search
+	err    error
+}
+type localAlbumMsg struct {
+	tracks []songItem
+	start  int // Track to start playing at
+	err    error
+}
+
+type relatedAlbumsMsg struct {
+	albumID  string
+	byArtist []songItem
+	related  []songItem
+	err      error
+}
+
+// cooldownMsg reports that downloads are paused until YouTube stops throttling
+type cooldownMsg struct {
+	until time.Time
+}
+type cooldownTickMsg struct{}
+type previewDoneMsg struct {
+	err error
+}
+
+// trackAdvancedMsg reports that gapless playback moved on to the next queued track
+type trackAdvancedMsg struct {
+	item    songItem
+	session uint64 // Playback session of the new track
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDurationDrift is how far a converted file's duration may differ from the source
+const maxDurationDrift = 3 * time.Second
+
+// verifyError describes a converted file that is truncated or fails to decode
+type verifyError struct {
+	path   string
+	reason string
+}
+
+func (e *verifyError) Error() string {
+	return fmt.Sprintf("%s failed verification: %s", e.path, e.reason)
+}
+
+// probeDuration returns the container duration reported by ffprobe
+func probeDuration(path string) (time.Duration, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	).Output()
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// verifyAudio runs a full ffmpeg decode pass over path and compares the decoded
+// duration against expected (skipped when expected is zero)
+func verifyAudio(path string, expected time.Duration) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", "-v", "error", "-i", path, "-f", "null", "-")
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return &verifyError{path: path, reason: "decode failed: " + firstLine(stderr.String(), err.Error())}
+	}
+	if stderr.Len() > 0 {
+		return &verifyError{path: path, reason: "stream errors: " + firstLine(stderr.String(), "")}
+	}
+
+	if expected <= 0 {
+		return nil
+	}
+	actual, err := probeDuration(path)
+	if err != nil {
+		return &verifyError{path: path, reason: "could not read duration"}
+	}
+	drift := actual - expected
+	if drift < 0 {
+		drift = -drift
+	}
+	if drift > maxDurationDrift {
+		return &verifyError{path: path, reason: fmt.Sprintf("duration %s, expected %s (truncated?)",
+			formatDuration(actual), formatDuration(expected))}
+	}
+	return nil
+}
+
+// firstLine returns the first non-empty line of s, or fallback
+func firstLine(s, fallback string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return fallback
+}
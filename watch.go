@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultWatchInterval is how often watched folders are checked for new files
+const defaultWatchInterval = 10 * time.Second
+
+// watchConfig lists folders whose new audio files are moved into the library
+type watchConfig struct {
+	Folders  []string      `toml:"folders,omitempty"`  // Checked for new audio files, e.g. "~/Downloads"
+	Interval time.Duration `toml:"interval,omitzero"`  // Time between checks, 10s if 0
+	Template string        `toml:"template,omitempty"` // Path in the library built from the tags, e.g. "{album}/{track} - {title}"; keeps the file name if empty
+	Enrich   bool          `toml:"enrich,omitempty"`   // Fill in a missing artist and title from an "Artist - Title" file name
+}
+
+// watchTemplateFields are the tags a watch template can use
+var watchTemplateFields = []string{"artist", "album_artist", "album", "title", "track"}
+
+func (c watchConfig) validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("watch: interval can't be negative")
+	}
+	if c.Interval > 0 && c.Interval < time.Second {
+		return fmt.Errorf("watch: interval must be at least 1s, got %s", c.Interval)
+	}
+	if c.Template == "" {
+		return nil
+	}
+	return validateTemplate("watch", c.Template, watchTemplateFields)
+}
+
+// interval returns the time between checks
+func (c watchConfig) interval() time.Duration {
+	if c.Interval == 0 {
+		return defaultWatchInterval
+	}
+	return c.Interval
+}
+
+var watchJob jobScope // The watch folder poller
+
+// watchImportedMsg reports a file moved from a watch folder into the library
+type watchImportedMsg struct {
+	src, dst string
+	err      error
+}
+
+// watchFile is what a watched file looked like at the last check
+type watchFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// watchFolders checks the configured folders every interval until ctx is
+// cancelled. A file is imported once its size and time stop changing between
+// two checks, so downloads still being written are left alone. Files already
+// there at the start are only imported when existing is set.
+func watchFolders(ctx context.Context, sender msgSender, existing bool) {
+	seen := map[string]watchFile{}
+	imported := map[string]bool{}
+	first := true
+	ticker := time.NewTicker(cfg.Watch.interval())
+	defer ticker.Stop()
+	for {
+		current := map[string]watchFile{}
+		for _, folder := range cfg.Watch.Folders {
+			entries, err := os.ReadDir(expandHome(folder))
+			if err != nil {
+				continue // An unmounted or missing folder may come back later
+			}
+			for _, e := range entries {
+				if e.IsDir() || !isAudioFile(e.Name()) {
+					continue
+				}
+				info, err := e.Info()
+				if err != nil {
+					continue
+				}
+				path := filepath.Join(expandHome(folder), e.Name())
+				current[path] = watchFile{info.Size(), info.ModTime()}
+			}
+		}
+		for path, f := range current {
+			if imported[path] {
+				continue
+			}
+			if first && !existing {
+				imported[path] = true
+				continue
+			}
+			if prev, ok := seen[path]; !ok || prev != f {
+				continue
+			}
+			dst, err := importWatchedFile(ctx, path)
+			if ctx.Err() != nil {
+				return
+			}
+			// A failed file is not retried until it changes
+			imported[path] = true
+			sender.Send(watchImportedMsg{src: path, dst: dst, err: err})
+		}
+		for path := range imported {
+			if _, ok := current[path]; !ok {
+				delete(imported, path)
+			}
+		}
+		seen, first = current, false
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// importWatchedFile reads the tags of path and moves it to its place in the library
+func importWatchedFile(ctx context.Context, path string) (string, error) {
+	tags, err := readID3Tags(path)
+	if err != nil || tags["title"] == "" {
+		if tags, _, err = probeTags(path); err != nil {
+			return "", fmt.Errorf("reading tags: %v", err)
+		}
+	}
+	if cfg.Watch.Enrich && (tags["artist"] == "" || tags["title"] == "") {
+		if err := enrichFromName(ctx, path, tags); err != nil {
+			return "", fmt.Errorf("tagging: %v", err)
+		}
+	}
+
+	dst := filepath.Join(libraryDir(), watchDestination(filepath.Base(path), tags))
+	if _, err := os.Stat(dst); err == nil {
+		dst = freeName(dst)
+	}
+	if err := moveFile(ctx, path, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// watchDestination renders the library path of a file with the given tags.
+// Without a template the file keeps its name inside its album's folder;
+// files without an album tag go to "Singles".
+func watchDestination(name string, tags map[string]string) string {
+	values := map[string]string{
+		"artist":       tags["artist"],
+		"album_artist": tags["album_artist"],
+		"album":        tags["album"],
+		"title":        tags["title"],
+	}
+	if values["album"] == "" {
+		values["album"] = "Singles"
+	}
+	if values["album_artist"] == "" {
+		values["album_artist"] = values["artist"]
+	}
+	if values["title"] == "" {
+		values["title"] = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	// "3/12" and "03" both become "03"
+	if n, err := strconv.Atoi(strings.TrimSpace(strings.Split(tags["track"], "/")[0])); err == nil && n > 0 {
+		values["track"] = fmt.Sprintf("%02d", n)
+	}
+
+	if cfg.Watch.Template == "" {
+		return filepath.Join(sanitizeFilename(values["album"]), sanitizeFilename(name))
+	}
+	return expandTemplate(cfg.Watch.Template, values) + strings.ToLower(filepath.Ext(name))
+}
+
+// enrichFromName fills the missing artist and title of path from an
+// "Artist - Title" file name and writes them into the file
+func enrichFromName(ctx context.Context, path string, tags map[string]string) error {
+	stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	artist, title, ok := strings.Cut(stem, " - ")
+	if !ok {
+		return nil
+	}
+	var args []string
+	if tags["artist"] == "" {
+		tags["artist"] = strings.TrimSpace(artist)
+		args = append(args, "-metadata", "artist="+tags["artist"])
+	}
+	if tags["title"] == "" {
+		tags["title"] = strings.TrimSpace(title)
+		args = append(args, "-metadata", "title="+tags["title"])
+	}
+
+	tmp := path + ".tag" + filepath.Ext(path)
+	defer os.Remove(tmp)
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		append(append([]string{"-y", "-loglevel", "error", "-i", path, "-map", "0", "-codec", "copy"}, args...), tmp)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return os.Rename(tmp, path)
+}
+
+// moveFile moves src to dst, copying across file systems
+func moveFile(ctx context.Context, src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyFile(ctx, src, dst, func(int64) {}); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// startWatching starts the watch folder poller for the TUI, if folders are set
+func startWatching(sender msgSender) {
+	if len(cfg.Watch.Folders) == 0 {
+		return
+	}
+	go watchFolders(watchJob.start(), sender, false)
+}
+
+// applyWatchImport shows what happened to an imported file and refreshes an open library
+func (m *model) applyWatchImport(msg watchImportedMsg) tea.Cmd {
+	if msg.err != nil {
+		m.watchStatus = fmt.Sprintf("Importing %s failed: %v", filepath.Base(msg.src), msg.err)
+		return nil
+	}
+	m.watchStatus = fmt.Sprintf("Imported %s into %s", filepath.Base(msg.src), filepath.Dir(msg.dst))
+	if m.state == stateLibrary && !m.libraryLoading {
+		m.libraryLoading = true
+		return scanLibrary(libraryDir())
+	}
+	return nil
+}
+
+// runWatch implements `gomusic watch`: import what the watch folders hold
+// now, then keep watching until interrupted
+func runWatch(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: gomusic watch")
+		return 2
+	}
+	if len(cfg.Watch.Folders) == 0 {
+		fmt.Fprintln(os.Stderr, "No folders to watch: set folders in the [watch] section of config.toml")
+		return 2
+	}
+	release, ok := claimCommand("watch")
+	if !ok {
+		return 1
+	}
+	defer release()
+	fmt.Fprintf(os.Stderr, "Watching %s (Ctrl+C to stop)\n", strings.Join(cfg.Watch.Folders, ", "))
+	watchFolders(appCtx, watchReporter{}, true)
+	return 0
+}
+
+// watchReporter prints imports to stderr for `gomusic watch`
+type watchReporter struct{}
+
+func (watchReporter) Send(msg tea.Msg) {
+	if msg, ok := msg.(watchImportedMsg); ok {
+		if msg.err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", msg.src, msg.err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s -> %s\n", msg.src, msg.dst)
+		}
+	}
+}
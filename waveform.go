@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/faiface/beep"
+)
+
+const (
+	waveformRate    = 1000 // Samples per second analysed; plenty for peak levels
+	waveformBuckets = 300  // Peaks kept per track, resampled to the screen width
+)
+
+var waveformLevels = []rune("▁▂▃▄▅▆▇█")
+
+// waveformUnknown stands in for the part of a stream not played yet
+const waveformUnknown = '·'
+
+// waveform is a coarse peak envelope of a whole track
+type waveform struct {
+	Duration time.Duration `json:"duration"`
+	Peaks    []float64     `json:"peaks"`           // 0-1, evenly spread over Duration
+	Heard    []bool        `json:"heard,omitempty"` // Peaks recorded so far, nil once all are known
+}
+
+// waveformMsg delivers the waveform for the track with the given key
+type waveformMsg struct {
+	key  string
+	wave *waveform
+}
+
+// waveformKey identifies a track's waveform in the cache
+func waveformKey(item songItem) string {
+	if item.path != "" {
+		return localCoverKey(item.path)
+	}
+	return item.id
+}
+
+// waveformPath returns where the waveform with the given key is cached
+func waveformPath(key string) (string, error) {
+	dir, err := cacheDir("waveforms")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(key)+".json"), nil
+}
+
+// saveWaveform caches w under key
+func saveWaveform(key string, w *waveform) {
+	path, err := waveformPath(key)
+	if err != nil {
+		return
+	}
+	if data, err := json.Marshal(w); err == nil {
+		os.WriteFile(path, data, 0644)
+	}
+}
+
+// loadWaveform returns the cached waveform for item, analysing local files
+// the first time they are played. Streams get theirs from the playback's own
+// decoded audio, see waveformRecorder. Failures and a cancelled ctx just
+// leave the progress bar without one.
+func loadWaveform(ctx context.Context, item songItem) tea.Cmd {
+	key := waveformKey(item)
+	if key == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		path, err := waveformPath(key)
+		if err != nil {
+			return nil
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			var w waveform
+			if json.Unmarshal(data, &w) == nil {
+				return waveformMsg{key: key, wave: &w}
+			}
+		}
+		if item.path == "" {
+			return nil
+		}
+
+		w, err := analyseWaveform(ctx, item.path)
+		if err != nil {
+			return nil
+		}
+		saveWaveform(key, w)
+		return waveformMsg{key: key, wave: w}
+	}
+}
+
+// analyseWaveform decodes the file at path to low-rate mono with ffmpeg and reduces it to peaks
+func analyseWaveform(ctx context.Context, path string) (*waveform, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-loglevel", "error",
+		"-i", path,
+		"-vn", "-ac", "1", "-ar", fmt.Sprint(waveformRate),
+		"-f", "s16le", "pipe:1",
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	defer cmd.Wait()
+
+	// Peak per 50ms first, since the length isn't known until the end
+	const chunk = waveformRate / 20
+	var chunks []float64
+	buf := make([]byte, chunk*2)
+	samples := 0
+	for {
+		n, err := io.ReadFull(stdout, buf)
+		peak := 0.0
+		for i := 0; i+1 < n; i += 2 {
+			peak = math.Max(peak, math.Abs(float64(int16(binary.LittleEndian.Uint16(buf[i:])))/32768))
+		}
+		if n >= 2 {
+			chunks = append(chunks, peak)
+			samples += n / 2
+		}
+		if err != nil {
+			break
+		}
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no audio decoded")
+	}
+
+	return &waveform{
+		Duration: time.Duration(samples) * time.Second / waveformRate,
+		Peaks:    normalizePeaks(resamplePeaks(chunks, waveformBuckets)),
+	}, nil
+}
+
+// waveformRecorder passes a stream's decoded audio through to the speaker,
+// keeping the peak of every 50ms on the way, so a streamed track's waveform
+// needs no second download and decode. Seeking is passed through; the parts
+// skipped or not reached yet are shown as unknown until they are played.
+type waveformRecorder struct {
+	beep.StreamSeeker
+	mu       sync.Mutex
+	per      int       // Samples per chunk
+	chunks   []float64 // Peak per 50ms of the track
+	heard    []bool    // Chunks played
+	heardNum int
+	duration time.Duration
+}
+
+// waveformHeard is the share of a track that must be played for its
+// recorded waveform to be cached
+const waveformHeard = 0.95
+
+func newWaveformRecorder(s beep.StreamSeeker, rate beep.SampleRate, duration time.Duration) *waveformRecorder {
+	n := max(1, int(duration/(50*time.Millisecond))+1)
+	return &waveformRecorder{
+		StreamSeeker: s,
+		per:          max(1, rate.N(50*time.Millisecond)),
+		chunks:       make([]float64, n),
+		heard:        make([]bool, n),
+		duration:     duration,
+	}
+}
+
+func (r *waveformRecorder) Stream(samples [][2]float64) (int, bool) {
+	pos := r.Position()
+	n, ok := r.StreamSeeker.Stream(samples)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, s := range samples[:n] {
+		c := min((pos+i)/r.per, len(r.chunks)-1)
+		r.chunks[c] = math.Max(r.chunks[c], math.Abs(s[0]+s[1])/2)
+		if !r.heard[c] {
+			r.heard[c] = true
+			r.heardNum++
+		}
+	}
+	return n, ok
+}
+
+// snapshot returns the waveform recorded so far, and whether enough of the
+// track was played for it to be complete
+func (r *waveformRecorder) snapshot() (*waveform, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w := &waveform{Duration: r.duration, Peaks: normalizePeaks(resamplePeaks(r.chunks, waveformBuckets))}
+	complete := float64(r.heardNum) >= waveformHeard*float64(len(r.chunks))
+	if !complete {
+		w.Heard = resampleHeard(r.heard, waveformBuckets)
+	}
+	return w, complete
+}
+
+// report sends the recorded waveform of the track with the given key every
+// few seconds until ctx is done, then caches it if the track was played through
+func (r *waveformRecorder) report(ctx context.Context, sender msgSender, key string) {
+	ticker := time.NewTicker(3 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if w, complete := r.snapshot(); complete {
+				saveWaveform(key, w)
+			}
+			return
+		}
+		w, _ := r.snapshot()
+		sender.Send(waveformMsg{key: key, wave: w})
+	}
+}
+
+// resamplePeaks reduces or stretches peaks to n values, keeping the loudest in each span
+func resamplePeaks(peaks []float64, n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		from := i * len(peaks) / n
+		to := max((i+1)*len(peaks)/n, from+1)
+		for _, p := range peaks[from:min(to, len(peaks))] {
+			out[i] = math.Max(out[i], p)
+		}
+	}
+	return out
+}
+
+// resampleHeard reduces or stretches heard to n values, each true if any of its span was heard
+func resampleHeard(heard []bool, n int) []bool {
+	out := make([]bool, n)
+	for i := range out {
+		from := i * len(heard) / n
+		to := max((i+1)*len(heard)/n, from+1)
+		out[i] = slices.Contains(heard[from:min(to, len(heard))], true)
+	}
+	return out
+}
+
+// normalizePeaks scales peaks so the loudest reaches 1
+func normalizePeaks(peaks []float64) []float64 {
+	top := 0.0
+	for _, p := range peaks {
+		top = math.Max(top, p)
+	}
+	if top == 0 {
+		return peaks
+	}
+	for i := range peaks {
+		peaks[i] /= top
+	}
+	return peaks
+}
+
+// renderPlaybackProgress shows elapsed and total time over the track's
+// waveform, with the played part highlighted and the part of a stream not
+// played yet dotted
+func (m *model) renderPlaybackProgress() string {
+	w := m.playback.waveform
+	if w == nil || w.Duration <= 0 {
+		if m.playback.position <= 0 {
+			return ""
+		}
+		return "\n" + helpStyle.Render(formatDuration(m.playback.position))
+	}
+
+	width := 50
+	if m.width > 0 {
+		width = max(10, min(width, m.width-8))
+	}
+	played := int(float64(width) * float64(m.playback.position) / float64(w.Duration))
+
+	var heard []bool
+	if w.Heard != nil {
+		heard = resampleHeard(w.Heard, width)
+	}
+	var done, rest strings.Builder
+	for i, p := range resamplePeaks(w.Peaks, width) {
+		level := waveformLevels[int(p*float64(len(waveformLevels)-1)+0.5)]
+		if heard != nil && !heard[i] {
+			level = waveformUnknown
+		}
+		if i < played {
+			done.WriteRune(level)
+		} else {
+			rest.WriteRune(level)
+		}
+	}
+	return fmt.Sprintf("\n%s\n%s%s",
+		helpStyle.Render(fmt.Sprintf("%s / %s  •  %s/%s: Seek", formatDuration(m.playback.position), formatDuration(w.Duration), keyLabel("seek_back"), keyLabel("seek_forward"))),
+		m.renderGradient(done.String()),
+		helpStyle.Render(rest.String()),
+	)
+}
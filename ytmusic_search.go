@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -8,8 +9,10 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-// searchYTMusic performs a YouTube Music search using the dedicated library
-func searchYTMusic(query string, filter searchFilter) tea.Cmd {
+// searchYTMusic performs a YouTube Music search using the dedicated library.
+// With lengthFilter, tracks outside the configured length range are left out.
+// Nothing is reported once ctx is cancelled.
+func searchYTMusic(ctx context.Context, query string, filter searchFilter, lengthFilter bool) tea.Cmd {
 	return func() tea.Msg {
 		var items []songItem
 
@@ -18,7 +21,10 @@ func searchYTMusic(query string, filter searchFilter) tea.Cmd {
 		case filterAll:
 			// Search everything
 			searchClient := ytmusic.Search(query)
-			result, err := searchClient.Next()
+			result, err := withContext(ctx, searchClient.Next)
+			if ctx.Err() != nil {
+				return nil
+			}
 			if err != nil {
 				return errMsg(fmt.Errorf("YouTube Music search failed: %v", err))
 			}
@@ -27,7 +33,10 @@ func searchYTMusic(query string, filter searchFilter) tea.Cmd {
 		case filterSongs:
 			// Search only tracks
 			searchClient := ytmusic.TrackSearch(query)
-			result, err := searchClient.Next()
+			result, err := withContext(ctx, searchClient.Next)
+			if ctx.Err() != nil {
+				return nil
+			}
 			if err != nil {
 				return errMsg(fmt.Errorf("YouTube Music track search failed: %v", err))
 			}
@@ -43,7 +52,10 @@ func searchYTMusic(query string, filter searchFilter) tea.Cmd {
 		case filterAlbums:
 			// Search only albums
 			searchClient := ytmusic.AlbumSearch(query)
-			result, err := searchClient.Next()
+			result, err := withContext(ctx, searchClient.Next)
+			if ctx.Err() != nil {
+				return nil
+			}
 			if err != nil {
 				return errMsg(fmt.Errorf("YouTube Music album search failed: %v", err))
 			}
@@ -52,7 +64,10 @@ func searchYTMusic(query string, filter searchFilter) tea.Cmd {
 			}
 		}
 
-		return searchResultsMsg(items)
+		if lengthFilter {
+			items = filterLength(items)
+		}
+		return searchResultsMsg{key: newSearchKey(query, filter, lengthFilter), items: items}
 	}
 }
 
@@ -70,6 +85,13 @@ func convertYTMusicResults(result *ytmusic.SearchResult) []songItem {
 		}
 	}
 
+	// Add video uploads
+	for _, video := range result.Videos {
+		if len(video.VideoID) >= 10 {
+			items = append(items, convertYTMusicVideo(video))
+		}
+	}
+
 	// Add albums
 	for _, album := range result.Albums {
 		items = append(items, convertYTMusicAlbum(album))
@@ -80,7 +102,7 @@ func convertYTMusicResults(result *ytmusic.SearchResult) []songItem {
 		items = append(items, convertYTMusicPlaylist(playlist))
 	}
 
-	return items
+	return groupResults(items)
 }
 
 // convertYTMusicTrack converts a YouTube Music track to songItem
@@ -105,9 +127,26 @@ func convertYTMusicTrack(track *ytmusic.TrackItem) songItem {
 		id:         videoID, // YouTube Music uses VideoID internally for tracks
 		title:      title,
 		author:     artistStr,
+		album:      track.Album.Name,
 		thumb:      thumb,
 		isAlbum:    false,
 		trackCount: 0,
+		duration:   track.Duration,
+		artistIDs:  getArtistIDs(track.Artists),
+		albumID:    track.Album.ID,
+	}
+}
+
+// convertYTMusicVideo converts a YouTube Music video upload to songItem
+func convertYTMusicVideo(video *ytmusic.VideoItem) songItem {
+	return songItem{
+		id:        video.VideoID,
+		title:     video.Title,
+		author:    strings.Join(getArtistNames(video.Artists), ", "),
+		thumb:     getBestThumbnail(video.Thumbnails),
+		duration:  video.Duration,
+		artistIDs: getArtistIDs(video.Artists),
+		video:     true,
 	}
 }
 
@@ -129,9 +168,12 @@ func convertYTMusicAlbum(album *ytmusic.AlbumItem) songItem {
 		id:         album.BrowseID,
 		title:      title,
 		author:     artistStr,
+		year:       album.Year,
+		category:   album.Type,
 		thumb:      thumb,
 		isAlbum:    true,
 		trackCount: 0, // We'll try to get this when browsing the album
+		artistIDs:  getArtistIDs(album.Artists),
 	}
 }
 
@@ -144,6 +186,7 @@ func convertYTMusicPlaylist(playlist *ytmusic.PlaylistItem) songItem {
 		id:         playlist.BrowseID,
 		title:      playlist.Title,
 		author:     playlist.Author,
+		category:   "Playlist",
 		thumb:      thumb,
 		isAlbum:    true, // Treat playlists as albums
 		trackCount: 0,    // Parse from ItemCount if needed
@@ -182,41 +225,66 @@ func getBestThumbnail(thumbnails []ytmusic.Thumbnail) string {
 }
 
 // fetchYTMusicAlbumTracks fetches tracks from a YouTube Music album
-func fetchYTMusicAlbumTracks(browseID string) tea.Cmd {
+func fetchYTMusicAlbumTracks(ctx context.Context, browseID string) tea.Cmd {
 	return func() tea.Msg {
-		// Strategy 1: Try to find tracks by searching for the album
-		// We'll need to get the album info first, then search for tracks from that album
-		
-		// Since we don't have direct album browsing, we'll use a workaround:
-		// Search for tracks and filter by the album ID/name
-		
-		// For now, let's try to get a watch playlist from any track in the album
-		// This is a limitation of the current library - it doesn't support direct album browsing
-		
-		// Alternative approach: Search for the album name and get tracks
-		return searchAlbumTracksByBrowseID(browseID)
+		return searchAlbumTracksByBrowseID(ctx, browseID)
 	}
 }
 
-// searchAlbumTracksByBrowseID attempts to find album tracks using various strategies
-func searchAlbumTracksByBrowseID(browseID string) tea.Msg {
-	// Strategy 1: If we have stored album info, search for tracks from that album
-	// This is a workaround since the library doesn't support direct album track listing
-	
-	// For now, we'll return a helpful error message suggesting the user search for individual tracks
-	return errMsg(fmt.Errorf("album track browsing requires additional implementation - try searching for individual songs from this album instead"))
+// searchAlbumTracksByBrowseID lists the album's tracks from its YouTube Music
+// page, in album order
+func searchAlbumTracksByBrowseID(ctx context.Context, browseID string) tea.Msg {
+	_, tracks, err := browseAlbum(ctx, browseID)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return errMsg(fmt.Errorf("YouTube Music album lookup failed: %v", err))
+	}
+	return albumTracksFetchedMsg{tracks: tracks}
 }
 
-// Enhanced album search that also finds tracks within albums
-func searchAlbumWithTracks(albumTitle, artistName string) tea.Cmd {
+// searchPlaylistTracksByBrowseID lists every track of the playlist, in order
+func searchPlaylistTracksByBrowseID(ctx context.Context, browseID string) tea.Msg {
+	_, tracks, err := browsePlaylist(ctx, browseID)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return errMsg(fmt.Errorf("YouTube Music playlist lookup failed: %v", err))
+	}
+	return albumTracksFetchedMsg{tracks: tracks}
+}
+
+// Enhanced album search that also finds tracks within albums. Tracks are
+// matched to the album by browse and artist IDs where YT Music provides them.
+// Nothing is reported once ctx is cancelled.
+func searchAlbumWithTracks(ctx context.Context, album songItem) tea.Cmd {
 	return func() tea.Msg {
+		// The album page has the exact tracklist; searching is the fallback
+		// for pages that can't be read. Playlists have no other source.
+		if album.isPlaylist() {
+			return searchPlaylistTracksByBrowseID(ctx, album.id)
+		}
+		if strings.HasPrefix(album.id, "MPREb_") {
+			msg := searchAlbumTracksByBrowseID(ctx, album.id)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if _, ok := msg.(albumTracksFetchedMsg); ok {
+				return msg
+			}
+		}
+
 		// Clean up the album title (remove emoji and extra formatting)
-		cleanTitle := strings.TrimPrefix(albumTitle, "📀 ")
+		cleanTitle := strings.TrimPrefix(album.title, "📀 ")
 		cleanTitle = strings.TrimSpace(cleanTitle)
+		if album.year != "" {
+			cleanTitle = strings.TrimSpace(strings.TrimSuffix(cleanTitle, "("+album.year+")"))
+		}
+		artistName := primaryArtist(album.author)
 		
 		var tracks []songItem
-		albumNameLower := strings.ToLower(cleanTitle)
-		artistNameLower := strings.ToLower(artistName)
 		
 		// Strategy 1: Search for tracks with album and artist
 		searchQueries := []string{
@@ -228,26 +296,17 @@ func searchAlbumWithTracks(albumTitle, artistName string) tea.Cmd {
 		
 		for _, searchQuery := range searchQueries {
 			searchClient := ytmusic.TrackSearch(searchQuery)
-			result, err := searchClient.Next()
+			result, err := withContext(ctx, searchClient.Next)
+			if ctx.Err() != nil {
+				return nil
+			}
 			if err != nil {
 				continue // Try next query
 			}
 			
 			for _, track := range result.Tracks {
-				// Filter tracks that belong to the specified album
-				trackAlbumLower := strings.ToLower(track.Album.Name)
-				trackArtistLower := strings.ToLower(strings.Join(getArtistNames(track.Artists), " "))
-				
-				// Check if the track's album matches our target album
-				albumMatch := strings.Contains(trackAlbumLower, albumNameLower) || 
-							 strings.Contains(albumNameLower, trackAlbumLower) ||
-							 trackAlbumLower == albumNameLower
-				
-				// Also check if artist matches
-				artistMatch := strings.Contains(trackArtistLower, artistNameLower) ||
-							  strings.Contains(artistNameLower, trackArtistLower)
-				
-				if albumMatch && artistMatch {
+				// Filter tracks that belong to the specified album and artist
+				if albumMatches(album, cleanTitle, track) && artistMatches(album, track) {
 					// Avoid duplicates and invalid tracks
 					isDuplicate := false
 					for _, existingTrack := range tracks {
@@ -274,23 +333,26 @@ func searchAlbumWithTracks(albumTitle, artistName string) tea.Cmd {
 		if len(tracks) == 0 {
 			for _, searchQuery := range searchQueries {
 				searchClient := ytmusic.TrackSearch(searchQuery)
-				result, err := searchClient.Next()
+				result, err := withContext(ctx, searchClient.Next)
+				if ctx.Err() != nil {
+					return nil
+				}
 				if err != nil || len(result.Tracks) == 0 {
 					continue
 				}
 				
 				// Try to get related tracks using GetWatchPlaylist
-				watchTracks, err := ytmusic.GetWatchPlaylist(result.Tracks[0].VideoID) // Get related tracks
+				watchTracks, err := withContext(ctx, func() ([]*ytmusic.TrackItem, error) {
+					return ytmusic.GetWatchPlaylist(result.Tracks[0].VideoID) // Get related tracks
+				})
+				if ctx.Err() != nil {
+					return nil
+				}
 				if err == nil && len(watchTracks) > 0 {
 					for _, track := range watchTracks {
 						// Filter for tracks from the same album or artist
-						trackAlbumLower := strings.ToLower(track.Album.Name)
-						trackArtistLower := strings.ToLower(strings.Join(getArtistNames(track.Artists), " "))
-						
-						albumMatch := strings.Contains(trackAlbumLower, albumNameLower) || 
-									 strings.Contains(albumNameLower, trackAlbumLower)
-						artistMatch := strings.Contains(trackArtistLower, artistNameLower) ||
-									  strings.Contains(artistNameLower, trackArtistLower)
+						albumMatch := albumMatches(album, cleanTitle, track)
+						artistMatch := artistMatches(album, track)
 						
 						if albumMatch || (artistMatch && len(tracks) < 10) { // Be more lenient for artist matches
 							// Avoid duplicates and invalid tracks
@@ -319,6 +381,8 @@ func searchAlbumWithTracks(albumTitle, artistName string) tea.Cmd {
 			return errMsg(fmt.Errorf("no tracks found for album: %s by %s - try searching for individual songs", cleanTitle, artistName))
 		}
 
-		return albumTracksFetchedMsg(tracks)
+		// Drop repeated uploads and live/remix versions picked up by the searches
+		tracks, filtered := dedupeTracks(tracks, albumVersionKind(cleanTitle))
+		return albumTracksFetchedMsg{tracks: tracks, filtered: filtered}
 	}
 }
\ No newline at end of file
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// zipAlbum packages a finished album folder into a zip next to it for moving
+// to other devices. The zip holds the tracks in a folder of the album's name
+// with the cover and an M3U playlist of the tracks in order.
+func zipAlbum(ctx context.Context, albumDir, albumName, coverPath string) (string, error) {
+	entries, err := os.ReadDir(albumDir)
+	if err != nil {
+		return "", err
+	}
+	var tracks []string
+	for _, e := range entries {
+		if _, ok := downloadFormats[strings.TrimPrefix(filepath.Ext(e.Name()), ".")]; ok && !e.IsDir() {
+			tracks = append(tracks, e.Name())
+		}
+	}
+	if len(tracks) == 0 {
+		return "", fmt.Errorf("no tracks in %s", albumDir)
+	}
+	// Track files start with their number, so name order is album order
+	sort.Strings(tracks)
+
+	zipPath := filepath.Clean(albumDir) + ".zip"
+	tmp := zipPath + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+
+	base := filepath.Base(filepath.Clean(albumDir))
+	zw := zip.NewWriter(f)
+	for _, name := range tracks {
+		if err := ctx.Err(); err != nil {
+			f.Close()
+			return "", err
+		}
+		if err := addZipFile(zw, base+"/"+name, filepath.Join(albumDir, name)); err != nil {
+			f.Close()
+			return "", err
+		}
+	}
+	if coverPath != "" {
+		if err := addZipFile(zw, base+"/cover"+filepath.Ext(coverPath), coverPath); err != nil {
+			f.Close()
+			return "", err
+		}
+	}
+	playlist, err := zw.Create(base + "/" + sanitizeFilename(albumName+".m3u"))
+	if err == nil {
+		_, err = io.WriteString(playlist, "#EXTM3U\n#PLAYLIST:"+albumName+"\n"+strings.Join(tracks, "\n")+"\n")
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return zipPath, os.Rename(tmp, zipPath)
+}
+
+// addZipFile copies the file at path into zw as name. Audio and images are
+// compressed already, so they are stored as is.
+func addZipFile(zw *zip.Writer, name, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Store
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}